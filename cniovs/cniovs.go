@@ -28,36 +28,66 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"net"
 	"os"
-	"os/user"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 
-	"golang.org/x/sys/unix"
-
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 
 	"github.com/intel/userspace-cni-network-plugin/logging"
 	"github.com/intel/userspace-cni-network-plugin/pkg/configdata"
+	"github.com/intel/userspace-cni-network-plugin/pkg/ipresult"
+	"github.com/intel/userspace-cni-network-plugin/pkg/shareddir"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/intel/userspace-cni-network-plugin/usrspcni"
 )
 
 // Constants
 const (
 	defaultBridge               = "br0"
-	DefaultHostVhostuserBaseDir = "/var/lib/vhost_sockets/"
+	DefaultHostVhostuserBaseDir = shareddir.DefaultHostVhostuserBaseDir
+
+	// OpenFlow tables of a bridge. The first filters what the pods send with
+	// the anti-spoofing rules, the second forwards the traffic let through.
+	filterTable  = 0
+	forwardTable = 1
+
+	// Priority of the OpenFlow rules of an "interface" NetType, above the
+	// NORMAL rule of the forward table
+	l3FlowPriority = 100
+
+	// Priorities of the anti-spoofing OpenFlow rules of a vhost port. The
+	// traffic of the pod addresses goes on to the forward table, anything
+	// else the pod sends is dropped.
+	antiSpoofAllowPriority = 90
+	antiSpoofDropPriority  = 80
+
+	// Highest usable 802.1Q VLAN Id
+	maxVlanId = 4094
 )
 
 // Types
 type CniOvs struct {
 }
 
+// OpenFlow rule, as passed to "ovs-ofctl add-flow" once joined
+type ovsFlow struct {
+	match   string
+	actions string
+}
+
+func init() {
+	usrspcni.Register("ovs-dpdk", CniOvs{})
+}
+
 // API Functions
 func (cniOvs CniOvs) AddOnHost(conf *types.NetConf,
 	args *skel.CmdArgs,
@@ -79,12 +109,30 @@ func (cniOvs CniOvs) AddOnHost(conf *types.NetConf,
 		conf.HostConf.BridgeConf.BridgeName = defaultBridge
 	}
 
+	//
+	// Validate the Local Network before touching OvS, so a bad NetType
+	// leaves nothing behind to clean up.
+	//
+	if conf.HostConf.NetType != "bridge" && conf.HostConf.NetType != "interface" && conf.HostConf.NetType != "" {
+		err = errors.New("ERROR: Unknown HostConf.NetType:" + conf.HostConf.NetType)
+	} else if conf.OvsConf.AntiSpoof && conf.HostConf.NetType == "" {
+		// The flows of a controller managed bridge are unknown
+		err = errors.New("ERROR: OvsConf.AntiSpoof needs NetType bridge or interface")
+	} else {
+		err = validateVlans(&conf.HostConf.BridgeConf)
+	}
+	if err != nil {
+		logging.Debugf("AddOnHost(ovs): %v", err)
+		return err
+	}
+
 	//
 	// Create bridge before creating Interface
 	//
-	err = addLocalNetworkBridge(conf, args, &data)
+	bridgeCreated, err := addLocalNetworkBridge(conf, args, ipResult, &data)
 	if err != nil {
 		logging.Debugf("AddOnHost(ovs): %v", err)
+		undoLocalNetworkBridge(conf, args, &data, bridgeCreated)
 		return err
 	}
 
@@ -98,6 +146,7 @@ func (cniOvs CniOvs) AddOnHost(conf *types.NetConf,
 	}
 	if err != nil {
 		logging.Debugf("AddOnHost(ovs): %v", err)
+		undoLocalNetworkBridge(conf, args, &data, bridgeCreated)
 		return err
 	}
 
@@ -106,22 +155,53 @@ func (cniOvs CniOvs) AddOnHost(conf *types.NetConf,
 	//
 
 	//
-	// Add Interface to Local Network
+	// Add L3 Network if supplied
 	//
 	if conf.HostConf.NetType == "interface" {
-		err = errors.New("ERROR: HostConf.NetType \"interface\" not supported.")
-	} else if conf.HostConf.NetType != "bridge" && conf.HostConf.NetType != "" {
-		err = errors.New("ERROR: Unknown HostConf.NetType:" + conf.HostConf.NetType)
+		err = addLocalNetworkInterface(conf, args, ipResult, &data)
+		if err != nil {
+			logging.Debugf("AddOnHost(ovs): %v", err)
+			undoLocalDeviceVhost(conf, args, sharedDir, &data)
+			undoLocalNetworkBridge(conf, args, &data, bridgeCreated)
+			return err
+		}
 	}
-	if err != nil {
-		logging.Debugf("AddOnHost(ovs): %v", err)
-		return err
+
+	//
+	// Restrict what the pod sends if requested
+	//
+	if conf.OvsConf.AntiSpoof {
+		err = addLocalNetworkAntiSpoof(conf, args, ipResult, &data)
+		if err != nil {
+			logging.Debugf("AddOnHost(ovs): %v", err)
+			if delErr := delLocalNetworkInterface(conf, args, &data); delErr != nil {
+				logging.Debugf("AddOnHost(ovs): Unable to undo flows of port %s - %v", data.Vhostname, delErr)
+			}
+			undoLocalDeviceVhost(conf, args, sharedDir, &data)
+			undoLocalNetworkBridge(conf, args, &data, bridgeCreated)
+			return err
+		}
 	}
 
 	//
 	// Save Config - Save Create Data for Delete
 	//
+	data.NetName = conf.Name
+	data.ContainerID = args.ContainerID
+	data.IfName = args.IfName
+	data.SharedDir = sharedDir
 	err = SaveConfig(conf, args, &data)
+	if err != nil {
+		logging.Debugf("AddOnHost(ovs): %v", err)
+		if delErr := delLocalNetworkAntiSpoof(conf, &data); delErr != nil {
+			logging.Debugf("AddOnHost(ovs): Unable to undo anti-spoofing flows of port %s - %v", data.Vhostname, delErr)
+		}
+		if delErr := delLocalNetworkInterface(conf, args, &data); delErr != nil {
+			logging.Debugf("AddOnHost(ovs): Unable to undo flows of port %s - %v", data.Vhostname, delErr)
+		}
+		undoLocalDeviceVhost(conf, args, sharedDir, &data)
+		undoLocalNetworkBridge(conf, args, &data, bridgeCreated)
+	}
 
 	return err
 }
@@ -163,6 +243,16 @@ func (cniOvs CniOvs) DelFromHost(conf *types.NetConf, args *skel.CmdArgs, shared
 	//
 	// Remove Interface from Local Network
 	//
+	err = delLocalNetworkAntiSpoof(conf, &data)
+	if err != nil {
+		logging.Debugf("DelFromHost(ovs): %v", err)
+		return err
+	}
+	err = delLocalNetworkInterface(conf, args, &data)
+	if err != nil {
+		logging.Debugf("DelFromHost(ovs): %v", err)
+		return err
+	}
 
 	//
 	// Delete Local Interface
@@ -199,90 +289,161 @@ func (cniOvs CniOvs) DelFromContainer(conf *types.NetConf, args *skel.CmdArgs, s
 	return nil
 }
 
-//
-// Utility Functions
-//
+func (cniOvs CniOvs) CheckOnHost(conf *types.NetConf, args *skel.CmdArgs, sharedDir string) error {
+	var data OvsSavedData
+	var err error
 
-func generateRandomMacAddress() string {
-	buf := make([]byte, 6)
-	if _, err := rand.Read(buf); err != nil {
-		return ""
+	logging.Infof("OVS CheckOnHost: ENTER - Container %s Iface %s", args.ContainerID[:12], args.IfName)
+
+	//
+	// Read Config - Unlike DelFromHost(), leave the saved data in place
+	//
+	err = ReadConfig(conf, args, &data)
+	if err != nil {
+		logging.Debugf("CheckOnHost(ovs): %v", err)
+		return cnitypes.NewError(cnitypes.ErrUnknownContainer, "OVS attachment not found", err.Error())
 	}
 
-	// Set the local bit and make sure not MC address
-	macAddr := fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
-		(buf[0]|0x2)&0xfe, buf[1], buf[2],
-		buf[3], buf[4], buf[5])
-	return macAddr
-}
+	if conf.HostConf.BridgeConf.BridgeName == "" {
+		conf.HostConf.BridgeConf.BridgeName = defaultBridge
+	}
+
+	if conf.HostConf.IfType != "vhostuser" {
+		return cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, "Unknown HostConf.IfType", conf.HostConf.IfType)
+	}
+
+	ovsCtrl, err := getOvsController(conf)
+	if err != nil {
+		return cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, "Unknown OvsConf.Backend", conf.OvsConf.Backend)
+	}
 
-func getShortSharedDir(sharedDir string) string {
-	// sun_path for unix domain socket has an array size of 108
-	// When the sharedDir path length is greater than 89 (108 - 19)
-	// 19 is the possible vhostuser socket file name length "/abcdefghijkl-net99" (1 + 12 + 1 + 3 + 2)
-	// FIXME: why shareddir is shortened only in case that it contains "empty-dir"?
-	if len(sharedDir) >= 89 && strings.Contains(sharedDir, "empty-dir") {
-		// Format - /var/lib/kubelet/pods/<podID>/volumes/kubernetes.io~empty-dir/shared-dir
-		parts := strings.Split(sharedDir, "/")
-		// FIXME: it's not safe; can we assure that shareDir with "empty-dir" will always have at least 5 dirs?
-		podID := parts[5]
-		newSharedDir := filepath.Join(DefaultHostVhostuserBaseDir, podID)
-		logging.Infof("getShortSharedDir: Short shared directory: %s", newSharedDir)
-		return newSharedDir
-	}
-	return sharedDir
+	//
+	// Verify the port is still attached to the expected bridge
+	//
+	bridgeName, err := ovsCtrl.getPortBridge(data.Vhostname)
+	if err != nil {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("OVS port %s not found", data.Vhostname), err.Error())
+	}
+	if bridgeName != conf.HostConf.BridgeConf.BridgeName {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("OVS port %s is on bridge %s, expected %s", data.Vhostname, bridgeName, conf.HostConf.BridgeConf.BridgeName), "")
+	}
+
+	//
+	// Verify the socketfile is still in the shared directory. In client
+	// mode the socketfile is created by the container application, so it
+	// may legitimately not exist yet.
+	//
+	if conf.HostConf.VhostConf.Mode != "client" {
+		socketPath := filepath.Join(shareddir.GetShortSharedDir(sharedDir), data.Vhostname)
+		if _, err = os.Stat(socketPath); err != nil {
+			return cnitypes.NewError(cnitypes.ErrInternal,
+				fmt.Sprintf("OVS socketfile %s not found", socketPath), err.Error())
+		}
+	}
 
+	return nil
 }
 
-func createSharedDir(sharedDir, oldSharedDir string) error {
-	var err error
+func (cniOvs CniOvs) GarbageCollect(conf *types.NetConf, validAttachments []cnitypes.GCAttachment) error {
+	var gcErr error
 
-	_, err = os.Stat(sharedDir)
-	if os.IsNotExist(err) {
-		err = os.MkdirAll(sharedDir, 0750)
-		if err != nil {
-			_ = logging.Errorf("createSharedDir: Failed to create dir (%s): %v", sharedDir, err)
-			return err
+	logging.Infof("OVS GarbageCollect: ENTER - Network %s", conf.Name)
+
+	dataList, err := ListConfig(conf)
+	if err != nil {
+		logging.Debugf("GarbageCollect(ovs): %v", err)
+		return err
+	}
+
+	for _, data := range dataList {
+		if usrspcni.IsValidAttachment(validAttachments, data.ContainerID, data.IfName) {
+			continue
 		}
 
-		if strings.Contains(sharedDir, DefaultHostVhostuserBaseDir) {
-			logging.Debugf("createSharedDir: Mount from %s to %s", oldSharedDir, sharedDir)
-			err = unix.Mount(oldSharedDir, sharedDir, "", unix.MS_BIND, "")
-			if err != nil {
-				_ = logging.Errorf("createSharedDir: Failed to bind mount: %s", err)
-				return err
-			}
+		logging.Infof("OVS GarbageCollect: Deleting stale attachment - Container %s Iface %s", data.ContainerID[:12], data.IfName)
+		args := &skel.CmdArgs{ContainerID: data.ContainerID, IfName: data.IfName}
+
+		// Remove the port, socketfile and saved data, and the bridge if empty
+		if err = cniOvs.DelFromHost(conf, args, data.SharedDir); err != nil {
+			_ = logging.Errorf("GarbageCollect(ovs): Container %s Iface %s - %v", data.ContainerID[:12], data.IfName, err)
+			gcErr = err
+			continue
 		}
-		return nil
 
+		// Remove configuration data written to the shared directory, and the
+		// directory itself if empty
+		_ = configdata.FileCleanup("", filepath.Join(data.SharedDir, configdata.GetRemoteConfigFileName(args)))
+		_ = cniOvs.DelFromContainer(conf, args, data.SharedDir, nil)
 	}
-	return err
+
+	return gcErr
 }
 
-func setSharedDirGroup(sharedDir string, group string) error {
-	groupInfo, err := user.LookupGroup(group)
+func (cniOvs CniOvs) Status(conf *types.NetConf) error {
+	var data OvsSavedData
+
+	logging.Infof("OVS Status: ENTER - Network %s", conf.Name)
+
+	ovsCtrl, err := getOvsController(conf)
 	if err != nil {
-		return err
+		return cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, "Unknown OvsConf.Backend", conf.OvsConf.Backend)
 	}
 
-	logging.Debugf("setSharedDirGroup: group %s's gid is %s", group, groupInfo.Gid)
-	gid, err := strconv.Atoi(groupInfo.Gid)
-	if err != nil {
-		return err
+	//
+	// Verify ovsdb-server can be reached
+	//
+	if err := ovsCtrl.checkOvsdbConnection(); err != nil {
+		logging.Debugf("Status(ovs): %v", err)
+		return cnitypes.NewError(types.ErrPluginNotAvailable, "Unable to connect to OVS database", err.Error())
 	}
 
-	err = os.Chown(DefaultHostVhostuserBaseDir, -1, gid)
-	if err != nil {
-		return err
+	//
+	// Verify the bridge interfaces will be added to exists, or can be created
+	//
+	if conf.HostConf.BridgeConf.BridgeName == "" {
+		conf.HostConf.BridgeConf.BridgeName = defaultBridge
+	}
+	if _, err := addLocalNetworkBridge(conf, nil, nil, &data); err != nil {
+		logging.Debugf("Status(ovs): %v", err)
+		return cnitypes.NewError(types.ErrPluginNotAvailable,
+			fmt.Sprintf("Unable to create OVS bridge %s", conf.HostConf.BridgeConf.BridgeName), err.Error())
 	}
 
-	err = os.Chown(sharedDir, -1, gid)
-	if err != nil {
-		return err
+	return nil
+}
+
+//
+// Utility Functions
+//
+
+// validateVlans() - Verify the VLAN Ids of the port are in the 802.1Q range.
+func validateVlans(bridgeConf *types.BridgeConf) error {
+	if bridgeConf.VlanId < 0 || bridgeConf.VlanId > maxVlanId {
+		return fmt.Errorf("ERROR: Invalid BridgeConf.VlanId:%d", bridgeConf.VlanId)
+	}
+	for _, vlan := range bridgeConf.Trunks {
+		if vlan < 1 || vlan > maxVlanId {
+			return fmt.Errorf("ERROR: Invalid BridgeConf.Trunks VLAN Id:%d", vlan)
+		}
 	}
 	return nil
 }
 
+func generateRandomMacAddress() string {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+
+	// Set the local bit and make sure not MC address
+	macAddr := fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
+		(buf[0]|0x2)&0xfe, buf[1], buf[2],
+		buf[3], buf[4], buf[5])
+	return macAddr
+}
+
 func addLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDir string, data *OvsSavedData) error {
 	var err error
 	var vhostName string
@@ -291,8 +452,8 @@ func addLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDi
 		conf.HostConf.VhostConf.Socketfile = fmt.Sprintf("%s-%s", args.ContainerID[:12], args.IfName)
 	}
 
-	sharedDir := getShortSharedDir(actualSharedDir)
-	err = createSharedDir(sharedDir, actualSharedDir)
+	sharedDir := shareddir.GetShortSharedDir(actualSharedDir)
+	err = shareddir.CreateSharedDir(sharedDir, actualSharedDir)
 	if err != nil {
 		_ = logging.Errorf("addLocalDeviceVhost: Failed to create shared dir: %v", err)
 		return err
@@ -300,7 +461,7 @@ func addLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDi
 
 	group := conf.HostConf.VhostConf.Group
 	if group != "" {
-		err = setSharedDirGroup(sharedDir, group)
+		err = shareddir.SetSharedDirGroup(sharedDir, group)
 		if err != nil {
 			_ = logging.Errorf("addLocalDeviceVhost: Failed to set shared dir group: %v", err)
 			return err
@@ -313,19 +474,32 @@ func addLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDi
 		clientMode = true
 	}
 
+	ovsCtrl, err := getOvsController(conf)
+	if err != nil {
+		return err
+	}
+
 	// ovs-vsctl add-port
-	if vhostName, err = createVhostPort(sharedDir,
+	if vhostName, err = ovsCtrl.createVhostPort(sharedDir,
 		conf.HostConf.VhostConf.Socketfile,
 		clientMode,
-		conf.HostConf.BridgeConf.BridgeName); err == nil {
-		if vhostPortMac, err := getVhostPortMac(vhostName); err == nil {
+		conf.HostConf.BridgeConf.BridgeName,
+		conf.HostConf.BridgeConf.VlanId,
+		conf.HostConf.BridgeConf.Trunks); err == nil {
+		if vhostPortMac, err := ovsCtrl.getVhostPortMac(vhostName); err == nil {
 			data.VhostMac = vhostPortMac
 		} else {
+			// Don't leave a half configured port behind
+			if delErr := ovsCtrl.deleteVhostPort(vhostName, conf.HostConf.BridgeConf.BridgeName); delErr != nil {
+				logging.Debugf("addLocalDeviceVhost: Unable to undo port %s - %v", vhostName, delErr)
+			}
 			return err
 		}
 
 		data.Vhostname = vhostName
 		data.IfMac = generateRandomMacAddress()
+		data.VlanId = conf.HostConf.BridgeConf.VlanId
+		data.Trunks = conf.HostConf.BridgeConf.Trunks
 	} else {
 		return err
 	}
@@ -333,34 +507,31 @@ func addLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDi
 	return err
 }
 
+// undoLocalDeviceVhost() - Roll back addLocalDeviceVhost().
+func undoLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDir string, data *OvsSavedData) {
+	if err := delLocalDeviceVhost(conf, args, actualSharedDir, data); err != nil {
+		logging.Debugf("undoLocalDeviceVhost(): Unable to delete port %s - %v", data.Vhostname, err)
+	}
+}
+
 func delLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDir string, data *OvsSavedData) error {
-	sharedDir := getShortSharedDir(actualSharedDir)
+	sharedDir := shareddir.GetShortSharedDir(actualSharedDir)
+
+	ovsCtrl, err := getOvsController(conf)
+	if err != nil {
+		return err
+	}
 
 	// ovs-vsctl --if-exists del-port
-	err := deleteVhostPort(data.Vhostname, conf.HostConf.BridgeConf.BridgeName)
+	err = ovsCtrl.deleteVhostPort(data.Vhostname, conf.HostConf.BridgeConf.BridgeName)
 	if err != nil {
 		_ = logging.Errorf("delLocalDeviceVhost: Failed to delete port: %v", err)
 		return err
 	}
 
 	// Check if sharedDir is a mount dir of EmptyDir
-	if strings.Contains(sharedDir, DefaultHostVhostuserBaseDir) {
-		logging.Debugf("delLocalDeviceVhost: Unmount shared directory: %v", sharedDir)
-		_, err = os.Stat(sharedDir)
-		if os.IsNotExist(err) {
-			_ = logging.Errorf("delLocalDeviceVhost: shared directory %s does not exist to unmount", sharedDir)
-			return nil
-		}
-		err = unix.Unmount(sharedDir, 0)
-		if err != nil {
-			_ = logging.Errorf("Failed to unmount dir: %v", err)
-			return err
-		}
-		err = os.Remove(sharedDir)
-		if err != nil {
-			_ = logging.Errorf("Failed to remove dir: %v", err)
-			return err
-		}
+	if shareddir.IsShortSharedDir(sharedDir) {
+		return shareddir.RemoveShortSharedDir(sharedDir)
 	} else {
 		folder, err := os.Open(sharedDir)
 		if err != nil {
@@ -410,38 +581,366 @@ func delLocalDeviceVhost(conf *types.NetConf, args *skel.CmdArgs, actualSharedDi
 	return nil
 }
 
-func addLocalNetworkBridge(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) error {
-	var err error
+func addLocalNetworkBridge(conf *types.NetConf, args *skel.CmdArgs, ipResult *current.Result, data *OvsSavedData) (bool, error) {
+	var created bool
 
-	if found := findBridge(conf.HostConf.BridgeConf.BridgeName); !found {
+	ovsCtrl, err := getOvsController(conf)
+	if err != nil {
+		return false, err
+	}
+
+	if found := ovsCtrl.findBridge(conf.HostConf.BridgeConf.BridgeName); !found {
 		logging.Debugf("addLocalNetworkBridge(): Bridge %s not found, creating", conf.HostConf.BridgeConf.BridgeName)
-		err = createBridge(conf.HostConf.BridgeConf.BridgeName)
+		err = ovsCtrl.createBridge(conf.HostConf.BridgeConf.BridgeName)
 
 		if err == nil {
-			// Bridge is always created because it is required for interface.
-			// If bridge type was actually called out, then set the
-			// bridge up as L2 bridge. Otherwise, a controller is
-			// responsible for writing flows to OvS.
-			if conf.HostConf.NetType == "bridge" {
-				err = configL2Bridge(conf.HostConf.BridgeConf.BridgeName)
-			}
+			created = true
 		}
 	} else {
 		logging.Debugf("addLocalNetworkBridge(): Bridge %s exists, skip creating", conf.HostConf.BridgeConf.BridgeName)
 	}
 
-	return err
+	// Bridge is always created because it is required for interface. If
+	// bridge or interface type was actually called out, then set up the
+	// tables of the bridge, also on a bridge created before. Otherwise, a
+	// controller is responsible for writing flows to OvS.
+	if err == nil && (conf.HostConf.NetType == "bridge" || conf.HostConf.NetType == "interface") {
+		err = configBridgeTables(conf.HostConf.BridgeConf.BridgeName)
+	}
+
+	// Status() creates the bridge without a pod, so the gateway is added by
+	// the first pod finding it missing
+	if err == nil && args != nil && conf.HostConf.NetType == "bridge" && conf.HostConf.BridgeConf.Gateway {
+		err = addLocalNetworkGateway(ovsCtrl, conf, args.IfName, ipResult)
+	}
+
+	return created, err
+}
+
+// configBridgeTables() - Pass the traffic of the filter table on to the
+//
+//	forward table, which switches it NORMAL unless a rule of a vhost port
+//	matches. Adding the rules again only replaces them.
+func configBridgeTables(bridgeName string) error {
+	for _, flow := range getBridgeTableFlows() {
+		err := addFlow(bridgeName, flow.match+",actions="+flow.actions)
+		if err != nil {
+			_ = logging.Errorf("configBridgeTables: Failed to add flow %s: %v", flow.match, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getBridgeTableFlows() - Build the lowest priority OpenFlow rules of the
+//
+//	bridge tables, the forward table first so no traffic is lost.
+func getBridgeTableFlows() []ovsFlow {
+	return []ovsFlow{
+		{match: fmt.Sprintf("table=%d,priority=0", forwardTable), actions: "NORMAL"},
+		{match: fmt.Sprintf("table=%d,priority=0", filterTable), actions: fmt.Sprintf("resubmit(,%d)", forwardTable)},
+	}
+}
+
+// addLocalNetworkGateway() - Give an L2 bridge an internal port with the
+//
+//	IPAM gateway addresses in the host namespace, so the pods on the bridge
+//	can reach the node, unless it already has one. The port is shared by
+//	the pods on the bridge and deleted with it.
+func addLocalNetworkGateway(ovsCtrl ovsController, conf *types.NetConf, ifName string, ipResult *current.Result) error {
+	bridgeName := conf.HostConf.BridgeConf.BridgeName
+	portName := getGatewayPortName(bridgeName)
+	if portBridge, err := ovsCtrl.getPortBridge(portName); err == nil {
+		if portBridge != bridgeName {
+			return fmt.Errorf("ERROR: Gateway port %s of bridge %s exists on bridge %s", portName, bridgeName, portBridge)
+		}
+		logging.Debugf("addLocalNetworkGateway(): Gateway port %s exists, skip creating", portName)
+		return nil
+	}
+
+	gateways := getGateways(ipResult, ifName)
+	if len(gateways) == 0 {
+		return fmt.Errorf("ERROR: BridgeConf.Gateway set but no IPAM gateway for %s", ifName)
+	}
+
+	err := ovsCtrl.createGatewayPort(portName, bridgeName)
+	if err != nil {
+		return err
+	}
+
+	err = configGatewayPort(portName, gateways)
+	if err != nil {
+		if delErr := ovsCtrl.deleteVhostPort(portName, bridgeName); delErr != nil {
+			logging.Debugf("addLocalNetworkGateway(): Unable to delete gateway port %s - %v", portName, delErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// getGatewayPortName() - Name of the gateway port of a bridge, short enough
+//
+//	for a Linux interface name. Bridge names too long to keep are replaced
+//	by a hash of the full name, so bridges sharing a prefix don't share the
+//	port.
+func getGatewayPortName(bridgeName string) string {
+	const maxBridgeNameLen = 12
+	if len(bridgeName) > maxBridgeNameLen {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(bridgeName))
+		return fmt.Sprintf("gw-%08x", hash.Sum32())
+	}
+	return bridgeName + "-gw"
+}
+
+// getGateways() - Return the IPv4 IPAM gateways of the named interface,
+//
+//	each in CIDR notation with the prefix length of its pod address.
+func getGateways(ipResult *current.Result, ifName string) []string {
+	var gateways []string
+
+	for _, gateway := range ipresult.GetGateways(ipResult, ifName) {
+		if gateway.IP.To4() == nil {
+			logging.Warningf("getGateways: Only IPv4 is supported, skipping %s", gateway.IP.String())
+			continue
+		}
+		gateways = append(gateways, (*net.IPNet)(&gateway).String())
+	}
+
+	return gateways
+}
+
+// undoLocalNetworkBridge() - Roll back addLocalNetworkBridge().
+//
+//	The bridge is only removed if this request created it and nothing else
+//	has been attached to it in the meantime.
+func undoLocalNetworkBridge(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData, created bool) {
+	if !created {
+		return
+	}
+	if err := delLocalNetworkBridge(conf, args, data); err != nil {
+		logging.Debugf("undoLocalNetworkBridge(): Unable to delete Bridge %s - %v", conf.HostConf.BridgeConf.BridgeName, err)
+	}
 }
 
 func delLocalNetworkBridge(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) error {
-	var err error
+	ovsCtrl, err := getOvsController(conf)
+	if err != nil {
+		return err
+	}
 
-	if containInterfaces := doesBridgeContainInterfaces(conf.HostConf.BridgeConf.BridgeName); !containInterfaces {
+	// The gateway port goes with the bridge
+	gatewayPort := getGatewayPortName(conf.HostConf.BridgeConf.BridgeName)
+	if containInterfaces := ovsCtrl.doesBridgeContainInterfaces(conf.HostConf.BridgeConf.BridgeName, gatewayPort); !containInterfaces {
 		logging.Debugf("delLocalNetworkBridge(): No interfaces found, deleting Bridge %s", conf.HostConf.BridgeConf.BridgeName)
-		err = deleteBridge(conf.HostConf.BridgeConf.BridgeName)
+		err = ovsCtrl.deleteBridge(conf.HostConf.BridgeConf.BridgeName)
 	} else {
 		logging.Debugf("delLocalNetworkBridge(): Interfaces found, skip deleting Bridge %s", conf.HostConf.BridgeConf.BridgeName)
 	}
 
 	return err
 }
+
+// addLocalNetworkInterface() - Route the IPAM addresses to the vhost port.
+//
+//	OvS answers the ARP requests of the container with a gateway MAC and
+//	forwards the IP packets addressed to the container to the vhost port,
+//	rewriting the MACs like a router would. The container must use the
+//	interface MAC returned in the result. Only IPv4 addresses are routed.
+func addLocalNetworkInterface(conf *types.NetConf, args *skel.CmdArgs, ipResult *current.Result, data *OvsSavedData) error {
+	ifIndex, ips := getInterfaceIPs(ipResult, args.IfName)
+	if ifIndex < 0 || len(ips) == 0 {
+		logging.Debugf("addLocalNetworkInterface(): No IP address for %s, no flows added", args.IfName)
+		return nil
+	}
+
+	gatewayMac := generateRandomMacAddress()
+	if gatewayMac == "" || data.IfMac == "" {
+		return errors.New("ERROR: Unable to generate MAC address")
+	}
+
+	for _, flow := range getL3InterfaceFlows(data.Vhostname, data.IfMac, gatewayMac, ips) {
+		err := addFlow(conf.HostConf.BridgeConf.BridgeName, flow.match+",actions="+flow.actions)
+		if err != nil {
+			_ = logging.Errorf("addLocalNetworkInterface: Failed to add flow %s: %v", flow.match, err)
+			if delErr := delLocalNetworkInterface(conf, args, data); delErr != nil {
+				logging.Debugf("addLocalNetworkInterface(): Unable to undo flows - %v", delErr)
+			}
+			return err
+		}
+		data.Flows = append(data.Flows, flow.match)
+	}
+
+	ipResult.Interfaces[ifIndex].Mac = data.IfMac
+
+	return nil
+}
+
+func delLocalNetworkInterface(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) error {
+	for len(data.Flows) != 0 {
+		match := data.Flows[len(data.Flows)-1]
+		if err := deleteFlow(conf.HostConf.BridgeConf.BridgeName, match); err != nil {
+			_ = logging.Errorf("delLocalNetworkInterface: Failed to delete flow %s: %v", match, err)
+			return err
+		}
+		data.Flows = data.Flows[:len(data.Flows)-1]
+	}
+
+	return nil
+}
+
+// getInterfaceIPs() - Return the result index of the named interface and
+//
+//	its IPv4 addresses. The index is -1 if the interface is not found.
+func getInterfaceIPs(ipResult *current.Result, ifName string) (int, []*current.IPConfig) {
+	var ips []*current.IPConfig
+
+	ifIndex, allIPs := ipresult.GetInterfaceIPs(ipResult, ifName)
+	for _, ipConfig := range allIPs {
+		if ipConfig.Address.IP.To4() == nil {
+			logging.Warningf("getInterfaceIPs: Only IPv4 is supported, skipping %s", ipConfig.Address.String())
+			continue
+		}
+		ips = append(ips, ipConfig)
+	}
+
+	return ifIndex, ips
+}
+
+// getL3InterfaceFlows() - Build the OpenFlow rules of an "interface" NetType.
+func getL3InterfaceFlows(portName string, ifMac string, gatewayMac string, ips []*current.IPConfig) []ovsFlow {
+	var flows []ovsFlow
+
+	for _, ipConfig := range ips {
+		addr := ipConfig.Address.IP.String()
+
+		// ARP responder: answer any request from the container with the
+		// gateway MAC, so all its traffic is sent to OvS
+		flows = append(flows, ovsFlow{
+			match: fmt.Sprintf("table=%d,priority=%d,arp,in_port=%s,arp_op=1,arp_spa=%s", forwardTable, l3FlowPriority, portName, addr),
+			actions: strings.Join([]string{
+				"move:NXM_OF_ETH_SRC[]->NXM_OF_ETH_DST[]",
+				"set_field:" + gatewayMac + "->eth_src",
+				"set_field:2->arp_op",
+				"move:NXM_NX_ARP_SHA[]->NXM_NX_ARP_THA[]",
+				"set_field:" + gatewayMac + "->arp_sha",
+				"move:NXM_OF_ARP_TPA[]->NXM_NX_REG0[]",
+				"move:NXM_OF_ARP_SPA[]->NXM_OF_ARP_TPA[]",
+				"move:NXM_NX_REG0[]->NXM_OF_ARP_SPA[]",
+				"in_port",
+			}, ","),
+		})
+
+		// IP forwarding: rewrite the MACs and send to the container
+		flows = append(flows, ovsFlow{
+			match: fmt.Sprintf("table=%d,priority=%d,ip,nw_dst=%s", forwardTable, l3FlowPriority, addr),
+			actions: strings.Join([]string{
+				"set_field:" + gatewayMac + "->eth_src",
+				"set_field:" + ifMac + "->eth_dst",
+				"dec_ttl",
+				"output:" + portName,
+			}, ","),
+		})
+	}
+
+	return flows
+}
+
+// addLocalNetworkAntiSpoof() - Only let the vhost port send from the MAC and
+//
+//	IPAM addresses of the pod, plus ARP/ND for them. Allowed traffic goes on
+//	to the forward table, so no rule there sees spoofed traffic. The rules
+//	carry a cookie derived from the container, so cmdDel() removes exactly
+//	them. The pod must use the interface MAC returned in the result.
+func addLocalNetworkAntiSpoof(conf *types.NetConf, args *skel.CmdArgs, ipResult *current.Result, data *OvsSavedData) error {
+	ifIndex, ips := ipresult.GetInterfaceIPs(ipResult, args.IfName)
+	if ifIndex < 0 {
+		return fmt.Errorf("ERROR: OvsConf.AntiSpoof set but no result for %s", args.IfName)
+	}
+	if data.IfMac == "" {
+		return errors.New("ERROR: Unable to generate MAC address")
+	}
+
+	data.FlowCookie = getFlowCookie(args.ContainerID, args.IfName)
+	for _, flow := range getAntiSpoofFlows(data.Vhostname, data.IfMac, ips) {
+		err := addFlow(conf.HostConf.BridgeConf.BridgeName, fmt.Sprintf("cookie=%#x,%s,actions=%s", data.FlowCookie, flow.match, flow.actions))
+		if err != nil {
+			_ = logging.Errorf("addLocalNetworkAntiSpoof: Failed to add flow %s: %v", flow.match, err)
+			if delErr := delLocalNetworkAntiSpoof(conf, data); delErr != nil {
+				logging.Debugf("addLocalNetworkAntiSpoof(): Unable to undo flows - %v", delErr)
+			}
+			return err
+		}
+	}
+
+	ipResult.Interfaces[ifIndex].Mac = data.IfMac
+
+	return nil
+}
+
+func delLocalNetworkAntiSpoof(conf *types.NetConf, data *OvsSavedData) error {
+	if data.FlowCookie == 0 {
+		return nil
+	}
+
+	if err := deleteCookieFlows(conf.HostConf.BridgeConf.BridgeName, data.FlowCookie); err != nil {
+		_ = logging.Errorf("delLocalNetworkAntiSpoof: Failed to delete flows with cookie %#x: %v", data.FlowCookie, err)
+		return err
+	}
+	data.FlowCookie = 0
+
+	return nil
+}
+
+// getFlowCookie() - OpenFlow cookie of the rules of an attachment, a hash of
+//
+//	the container ID and interface name.
+func getFlowCookie(containerID string, ifName string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(containerID + "-" + ifName))
+	return hash.Sum64()
+}
+
+// getAntiSpoofFlows() - Build the anti-spoofing OpenFlow rules of a vhost
+//
+//	port. Without an address, the pod may send anything from its MAC.
+func getAntiSpoofFlows(portName string, ifMac string, ips []*current.IPConfig) []ovsFlow {
+	var flows []ovsFlow
+	var hasIPv6 bool
+
+	allow := func(match string) {
+		flows = append(flows, ovsFlow{
+			match:   fmt.Sprintf("table=%d,priority=%d,in_port=%s,dl_src=%s%s", filterTable, antiSpoofAllowPriority, portName, ifMac, match),
+			actions: fmt.Sprintf("resubmit(,%d)", forwardTable),
+		})
+	}
+
+	for _, ipConfig := range ips {
+		addr := ipConfig.Address.IP.String()
+		if ipConfig.Address.IP.To4() != nil {
+			allow(",ip,nw_src=" + addr)
+			allow(",arp,arp_spa=" + addr + ",arp_sha=" + ifMac)
+		} else {
+			hasIPv6 = true
+			allow(",ipv6,ipv6_src=" + addr)
+			// Neighbor advertisements are also sent from the link-local address
+			allow(",icmp6,icmp_type=136,nd_target=" + addr)
+		}
+	}
+	if hasIPv6 {
+		// Neighbor solicitations, sent from any address of the pod
+		allow(",icmp6,icmp_type=135")
+	}
+	if len(ips) == 0 {
+		allow("")
+	}
+
+	flows = append(flows, ovsFlow{
+		match:   fmt.Sprintf("table=%d,priority=%d,in_port=%s", filterTable, antiSpoofDropPriority, portName),
+		actions: "drop",
+	})
+
+	return flows
+}
@@ -17,16 +17,17 @@ package cniovs
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
-	"os/user"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
-	"syscall"
 	"testing"
 
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+	"github.com/intel/userspace-cni-network-plugin/pkg/shareddir"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
 	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
 	"github.com/stretchr/testify/assert"
@@ -40,10 +41,12 @@ func TestAddOnHost(t *testing.T) {
 	ovs := CniOvs{}
 
 	testCases := []struct {
-		name    string
-		netConf *types.NetConf
-		fakeErr error
-		expErr  error
+		name       string
+		netConf    *types.NetConf
+		fakeOut    string
+		fakeErr    error
+		expErr     error
+		expLastCmd []string // last ovs-vsctl arguments, i.e. the rollback
 	}{
 		{
 			name:    "fail to create bridge",
@@ -57,20 +60,48 @@ func TestAddOnHost(t *testing.T) {
 			expErr:  errors.New("ERROR: Unknown HostConf.IfType:"),
 		},
 		{
-			name:    "fail due to wrong IfType",
-			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "badIfType", NetType: "bridge"}},
-			expErr:  errors.New("ERROR: Unknown HostConf.IfType:"),
+			name:       "fail due to wrong IfType and remove created bridge",
+			netConf:    &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "badIfType", NetType: "bridge"}},
+			expErr:     errors.New("ERROR: Unknown HostConf.IfType:"),
+			expLastCmd: []string{"del-br", "br0"},
 		},
 		{
-			name:    "fail due to NetType set to interface",
+			name:       "fail due to wrong IfType and keep existing bridge",
+			netConf:    &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "badIfType", NetType: "bridge"}},
+			fakeOut:    "br0",
+			expErr:     errors.New("ERROR: Unknown HostConf.IfType:"),
+			expLastCmd: []string{"add-flow", "br0", "table=0,priority=0,actions=resubmit(,1)"},
+		},
+		{
+			name:    "configure host interface without IP and store ovs data",
 			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "interface", VhostConf: types.VhostConf{Mode: "client"}}},
-			expErr:  errors.New("ERROR: HostConf.NetType"),
+			expErr:  nil,
 		},
 		{
 			name:    "fail due to NetType set to wrong value",
 			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "badNetType", VhostConf: types.VhostConf{Mode: "client"}}},
 			expErr:  errors.New("ERROR: Unknown HostConf.NetType:"),
 		},
+		{
+			name:    "fail due to AntiSpoof without NetType",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser"}, OvsConf: types.OvsConf{AntiSpoof: true}},
+			expErr:  errors.New("ERROR: OvsConf.AntiSpoof needs NetType bridge or interface"),
+		},
+		{
+			name:    "fail due to invalid VlanId",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", BridgeConf: types.BridgeConf{VlanId: 4095}}},
+			expErr:  errors.New("ERROR: Invalid BridgeConf.VlanId:4095"),
+		},
+		{
+			name:    "fail due to invalid trunk VLAN",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", BridgeConf: types.BridgeConf{Trunks: []int{10, 0}}}},
+			expErr:  errors.New("ERROR: Invalid BridgeConf.Trunks VLAN Id:0"),
+		},
+		{
+			name:    "configure host trunk port and store ovs data",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", BridgeConf: types.BridgeConf{VlanId: 10, Trunks: []int{20, 30}}}},
+			expErr:  nil,
+		},
 		{
 			name:    "configure host bridge and store ovs data",
 			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", VhostConf: types.VhostConf{Mode: "client"}}},
@@ -89,15 +120,21 @@ func TestAddOnHost(t *testing.T) {
 			pod := testdata.GetTestPod(sharedDir)
 			kubeClient := fake.NewSimpleClientset(pod)
 
-			SetExecCommand(&FakeExecCommand{Err: tc.fakeErr})
+			execCommand := &FakeExecCommand{Out: []byte(tc.fakeOut), Err: tc.fakeErr}
+			SetExecCommand(execCommand)
 			err := ovs.AddOnHost(tc.netConf, args, kubeClient, sharedDir, result)
 			SetDefaultExecCommand()
+			if tc.expLastCmd != nil {
+				assert.Equal(t, tc.expLastCmd, execCommand.Args, "Unexpected rollback")
+			}
 			if tc.expErr == nil {
 				assert.Equal(t, tc.expErr, err, "Unexpected result")
 				// on success there shall be saved ovs data
 				var data OvsSavedData
 				assert.NoError(t, LoadConfig(tc.netConf, args, &data))
 				assert.NotEmpty(t, data.Vhostname)
+				assert.Equal(t, tc.netConf.HostConf.BridgeConf.VlanId, data.VlanId, "Unexpected saved VLAN")
+				assert.Equal(t, tc.netConf.HostConf.BridgeConf.Trunks, data.Trunks, "Unexpected saved trunks")
 			} else {
 				require.Error(t, err, "Unexpected result")
 				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected result")
@@ -212,190 +249,93 @@ func TestDelFromHost(t *testing.T) {
 	}
 }
 
-func TestGenerateRandomMacAddress(t *testing.T) {
-	nr := 10
-	t.Run(fmt.Sprintf("generate %v random MAC addresses", nr), func(t *testing.T) {
-		macs := make(map[string]int)
-		mac_regex := regexp.MustCompile("^[0-9a-fA-F]{2}(:([0-9a-fA-F]){2}){5}$")
-		for i := 0; i < nr; i++ {
-			mac := generateRandomMacAddress()
-			ok := mac_regex.Match([]byte(mac))
-			require.True(t, ok, fmt.Sprintf("MAC is incorrect. MAC: %q", mac))
-			macs[mac]++
-		}
-		assert.Equal(t, nr, len(macs), fmt.Sprintf("MACs are not unique. MACs: %v", macs))
-	})
-}
+func TestCheckOnHost(t *testing.T) {
+	ovs := CniOvs{}
 
-func TestGetShortSharedDir(t *testing.T) {
 	testCases := []struct {
-		name      string
-		sharedDir string
-		expDir    string
+		name       string
+		netConf    *types.NetConf
+		socketFile bool
+		fakeOut    []byte
+		fakeErr    error
+		expErr     error
 	}{
 		{
-			name:      "return shared dir",
-			sharedDir: "shared-dir",
-			expDir:    "shared-dir",
-		},
-		{
-			name:      "return shared dir with path",
-			sharedDir: "/tmp/var/log/shared-dir",
-			expDir:    "/tmp/var/log/shared-dir",
-		},
-		{
-			name:      "return shared dir with length 102",
-			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/backup-dir/shared-dir",
-			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/backup-dir/shared-dir",
+			name:    "fail due to wrong IfType",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "badIfType"}},
+			expErr:  errors.New("Unknown HostConf.IfType"),
 		},
 		{
-			name:      "return shared dir with empty_dir and length 102",
-			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/empty_dir/shared-dir",
-			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/empty_dir/shared-dir",
+			name:    "fail when port does not exist",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser"}},
+			fakeErr: errors.New("no port named vhost0"),
+			expErr:  errors.New("OVS port vhost0 not found"),
 		},
 		{
-			name:      "return shared dir with empty-dir and length 88",
-			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.~empty-dir",
-			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.~empty-dir",
+			name:    "fail when server socket file is missing",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser"}},
+			fakeOut: []byte("br0"),
+			expErr:  errors.New("OVS socketfile"),
 		},
 		{
-			name:      "shorten shared dir with empty-dir and length 89",
-			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.i~empty-dir",
-			expDir:    "/var/lib/vhost_sockets/#UUID#",
+			name:       "check server port",
+			netConf:    &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser"}},
+			socketFile: true,
+			fakeOut:    []byte("br0"),
+			expErr:     nil,
 		},
 		{
-			name:      "shorten shared dir with empty-dir and length 101",
-			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io~empty-dir/shared-dir",
-			expDir:    "/var/lib/vhost_sockets/#UUID#",
+			name:    "check client port on given bridge",
+			netConf: &types.NetConf{HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", VhostConf: types.VhostConf{Mode: "client"}, BridgeConf: types.BridgeConf{BridgeName: "br1"}}},
+			fakeOut: []byte("br1"),
+			expErr:  nil,
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			id := string(uuid.NewUUID())
-			tc.sharedDir = strings.Replace(tc.sharedDir, "#UUID#", id, -1)
-			tc.expDir = strings.Replace(tc.expDir, "#UUID#", id, -1)
-			shortDir := getShortSharedDir(tc.sharedDir)
-			assert.Equal(t, tc.expDir, shortDir, "Unexpected result")
-		})
-	}
-}
+			args := testdata.GetTestArgs()
 
-func TestCreateSharedDir(t *testing.T) {
-	testCases := []struct {
-		name         string
-		sharedDir    string
-		oldSharedDir string
-		expErr       error
-	}{
-		{
-			name:         "shared dir exists",
-			sharedDir:    "#sharedDir#",
-			oldSharedDir: "#sharedDir#",
-			expErr:       nil,
-		},
-		{
-			name:         "fail to create shared dir",
-			sharedDir:    "/proc/broken-shared-dir",
-			oldSharedDir: "/proc/broken-shared-dir",
-			expErr:       errors.New("mkdir "),
-		},
-		{
-			name:         "shared dir in socket dir",
-			sharedDir:    "/var/lib/vhost_sockets/#sharedDirNoPath#",
-			oldSharedDir: "#sharedDir#",
-			expErr:       nil,
-		},
-		{
-			name:         "fail to mount old shared dir to socket dir",
-			sharedDir:    "/var/lib/vhost_sockets/#sharedDirNoPath#",
-			oldSharedDir: "/proc/broken-shared-dir",
-			expErr:       errors.New("no such file"),
-		},
-	}
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
 			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cniovs-")
-			sharedDirNoPath := strings.Split(sharedDir, "/")[2]
 			require.NoError(t, dirErr, "Can't create temporary directory")
-			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDir#", sharedDir, -1)
-			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDirNoPath#", sharedDirNoPath, -1)
-			tc.oldSharedDir = strings.Replace(tc.oldSharedDir, "#sharedDir#", sharedDir, -1)
 			defer os.RemoveAll(sharedDir)
+			if tc.socketFile {
+				require.NoError(t, os.WriteFile(path.Join(sharedDir, "vhost0"), []byte(""), 0644), "Can't create test file")
+			}
+
+			require.NoError(t, SaveConfig(tc.netConf, args, &OvsSavedData{Vhostname: "vhost0"}), "Can't save test data")
+			defer func() {
+				var data OvsSavedData
+				assert.NoError(t, LoadConfig(tc.netConf, args, &data))
+			}()
 
-			err := createSharedDir(tc.sharedDir, tc.oldSharedDir)
+			SetExecCommand(&FakeExecCommand{Out: tc.fakeOut, Err: tc.fakeErr})
+			err := ovs.CheckOnHost(tc.netConf, args, sharedDir)
+			SetDefaultExecCommand()
 			if tc.expErr == nil {
-				assert.Equal(t, tc.expErr, err, "Unexpected result")
+				assert.NoError(t, err, "Unexpected result")
 			} else {
 				require.Error(t, err, "Unexpected result")
 				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected result")
 			}
-
-			// cleanup
-			_ = unix.Unmount(tc.sharedDir, 0)
-			os.RemoveAll(tc.sharedDir)
-			os.RemoveAll(tc.oldSharedDir)
-
 		})
 	}
 }
 
-func TestSetSharedDirGroup(t *testing.T) {
-	testCases := []struct {
-		name      string
-		sharedDir string
-		group     string
-		expErr    string
-	}{
-		{
-			name:      "set group",
-			sharedDir: "#sharedDir#",
-			group:     "#group#",
-			expErr:    "",
-		},
-		{
-			name:      "fail to set bad group",
-			sharedDir: "#sharedDir#",
-			group:     "B@DGrO0P!",
-			expErr:    "^(group: unknown group|user: lookup groupname)",
-		},
-		{
-			name:      "fail to set group of broken shared dir",
-			sharedDir: "/proc/broken_shared_dir",
-			group:     "root",
-			expErr:    "^chown /proc/broken_shared_dir",
-		},
-	}
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cniovs-")
-			require.NoError(t, dirErr, "Can't create temporary directory")
-			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDir#", sharedDir, -1)
-			defer os.RemoveAll(sharedDir)
-
-			// read sharedDir original group to avoid system changes
-			if tc.group == "#group#" {
-				dirInfo, _ := os.Stat(sharedDir)
-				dirSys := dirInfo.Sys().(*syscall.Stat_t)
-				group, _ := user.LookupGroupId(string(rune(48 + dirSys.Gid)))
-				tc.group = group.Name
-			}
-
-			// create default socket dir if needed, so its group can be set
-			if _, err := os.Stat(DefaultHostVhostuserBaseDir); os.IsNotExist(err) {
-				require.NoError(t, os.MkdirAll(DefaultHostVhostuserBaseDir, 0700), "Can't create default socket dir")
-				defer os.RemoveAll(DefaultHostVhostuserBaseDir)
-			}
-
-			err := setSharedDirGroup(tc.sharedDir, tc.group)
-			if tc.expErr == "" {
-				assert.NoError(t, err, "Unexpected result")
-			} else {
-				require.Error(t, err, "Unexpected result")
-				assert.Regexp(t, tc.expErr, err.Error(), "Unexpected result")
-			}
-		})
-	}
+func TestGenerateRandomMacAddress(t *testing.T) {
+	nr := 10
+	t.Run(fmt.Sprintf("generate %v random MAC addresses", nr), func(t *testing.T) {
+		macs := make(map[string]int)
+		mac_regex := regexp.MustCompile("^[0-9a-fA-F]{2}(:([0-9a-fA-F]){2}){5}$")
+		for i := 0; i < nr; i++ {
+			mac := generateRandomMacAddress()
+			ok := mac_regex.Match([]byte(mac))
+			require.True(t, ok, fmt.Sprintf("MAC is incorrect. MAC: %q", mac))
+			macs[mac]++
+		}
+		assert.Equal(t, nr, len(macs), fmt.Sprintf("MACs are not unique. MACs: %v", macs))
+	})
 }
+
 func TestAddLocalDeviceVhost(t *testing.T) {
 	var data OvsSavedData
 
@@ -643,16 +583,16 @@ func TestDelLocalDeviceVhost(t *testing.T) {
 			if tc.sharedDir != "" {
 				tc.sharedDir = strings.Replace(tc.sharedDir, "#UUID#", string(uuid.NewUUID()), -1)
 				require.NoError(os.MkdirAll(tc.sharedDir, 0700), "Can't create old shared dir")
-				sharedDir = getShortSharedDir(tc.sharedDir)
+				sharedDir = shareddir.GetShortSharedDir(tc.sharedDir)
 				switch tc.brokenDir {
 				case "none":
 					// directory shall not exist - do nothing
 				case "unmount":
-					require.NoError(createSharedDir(sharedDir, tc.sharedDir), "Can't create new short shared dir")
+					require.NoError(shareddir.CreateSharedDir(sharedDir, tc.sharedDir), "Can't create new short shared dir")
 					require.NoError(unix.Unmount(sharedDir, 0), "Can't unmount shared dir")
 
 				default:
-					require.NoError(createSharedDir(sharedDir, tc.sharedDir), "Can't create new short shared dir")
+					require.NoError(shareddir.CreateSharedDir(sharedDir, tc.sharedDir), "Can't create new short shared dir")
 				}
 				// cleanup if needed
 				defer os.RemoveAll(tc.sharedDir)
@@ -747,7 +687,7 @@ func TestAddLocalNetworkBridge(t *testing.T) {
 			execCommand := &FakeExecCommand{Out: []byte(tc.fakeOut), Err: tc.fakeErr}
 
 			SetExecCommand(execCommand)
-			err := addLocalNetworkBridge(&types.NetConf{}, args, &data)
+			_, err := addLocalNetworkBridge(&types.NetConf{}, args, nil, &data)
 			SetDefaultExecCommand()
 
 			if tc.expErr == nil {
@@ -811,3 +751,440 @@ func TestDelLocalNetworkBridge(t *testing.T) {
 		})
 	}
 }
+
+func TestAddLocalNetworkInterface(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ips      []string
+		fakeErr  error
+		expErr   error
+		expFlows []string
+		expMac   bool
+	}{
+		{
+			name: "skip interface without IP",
+		},
+		{
+			name: "skip IPv6 address",
+			ips:  []string{"2001:db8::10/64"},
+		},
+		{
+			name: "add flows for IPv4 addresses",
+			ips:  []string{"192.168.1.10/24", "2001:db8::10/64", "10.0.0.10/8"},
+			expFlows: []string{
+				"table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=192.168.1.10",
+				"table=1,priority=100,ip,nw_dst=192.168.1.10",
+				"table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=10.0.0.10",
+				"table=1,priority=100,ip,nw_dst=10.0.0.10",
+			},
+			expMac: true,
+		},
+		{
+			name:    "fail to add flow",
+			ips:     []string{"192.168.1.10/24"},
+			fakeErr: errors.New("ofctl error"),
+			expErr:  errors.New("ofctl error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := testdata.GetTestArgs()
+			conf := &types.NetConf{HostConf: types.UserSpaceConf{BridgeConf: types.BridgeConf{BridgeName: "br0"}}}
+			data := OvsSavedData{Vhostname: "vhost0", IfMac: "02:00:00:00:00:01"}
+			result := &current.Result{Interfaces: []*current.Interface{{Name: "net0"}, {Name: args.IfName}}}
+			for _, ip := range tc.ips {
+				ipAddr, ipNet, err := net.ParseCIDR(ip)
+				require.NoError(t, err, "Can't parse IP address")
+				ipNet.IP = ipAddr
+				result.IPs = append(result.IPs, &current.IPConfig{Interface: current.Int(1), Address: *ipNet})
+			}
+			// Address of another interface shall be ignored
+			result.IPs = append(result.IPs, &current.IPConfig{Interface: current.Int(0), Address: net.IPNet{IP: net.IPv4(172, 16, 0, 1), Mask: net.CIDRMask(16, 32)}})
+
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			err := addLocalNetworkInterface(conf, args, result, &data)
+			SetDefaultExecCommand()
+
+			if tc.expErr == nil {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Equal(t, tc.expErr.Error(), err.Error(), "Unexpected result")
+			}
+			assert.Equal(t, tc.expFlows, data.Flows, "Unexpected saved flows")
+			if tc.expMac {
+				assert.Equal(t, data.IfMac, result.Interfaces[1].Mac, "Interface MAC not returned")
+				require.Len(t, execCommand.Args, 3, "Unexpected ovs command arguments")
+				assert.Equal(t, "ovs-ofctl", execCommand.Cmd, "Unexpected ovs command executed")
+				assert.Regexp(t, "^table=1,priority=100,ip,nw_dst=10.0.0.10,actions=set_field:[0-9a-f:]{17}->eth_src,set_field:02:00:00:00:00:01->eth_dst,dec_ttl,output:vhost0$",
+					execCommand.Args[2], "Unexpected flow")
+			} else {
+				assert.Empty(t, result.Interfaces[1].Mac, "Unexpected interface MAC")
+			}
+		})
+	}
+}
+
+func TestDelLocalNetworkInterface(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flows    []string
+		fakeErr  error
+		expErr   error
+		expArgs  []string
+		expFlows []string
+	}{
+		{
+			name: "skip interface without flows",
+		},
+		{
+			name:     "delete flows",
+			flows:    []string{"table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=192.168.1.10", "table=1,priority=100,ip,nw_dst=192.168.1.10"},
+			expArgs:  []string{"--strict", "del-flows", "br0", "table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=192.168.1.10"},
+			expFlows: []string{},
+		},
+		{
+			name:     "fail to delete flow",
+			flows:    []string{"table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=192.168.1.10", "table=1,priority=100,ip,nw_dst=192.168.1.10"},
+			fakeErr:  errors.New("ofctl error"),
+			expErr:   errors.New("ofctl error"),
+			expArgs:  []string{"--strict", "del-flows", "br0", "table=1,priority=100,ip,nw_dst=192.168.1.10"},
+			expFlows: []string{"table=1,priority=100,arp,in_port=vhost0,arp_op=1,arp_spa=192.168.1.10", "table=1,priority=100,ip,nw_dst=192.168.1.10"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := testdata.GetTestArgs()
+			conf := &types.NetConf{HostConf: types.UserSpaceConf{BridgeConf: types.BridgeConf{BridgeName: "br0"}}}
+			data := OvsSavedData{Vhostname: "vhost0", Flows: tc.flows}
+
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			err := delLocalNetworkInterface(conf, args, &data)
+			SetDefaultExecCommand()
+
+			if tc.expErr == nil {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Equal(t, tc.expErr.Error(), err.Error(), "Unexpected result")
+			}
+			assert.Equal(t, tc.expArgs, execCommand.Args, "Unexpected ovs command arguments")
+			if tc.expFlows != nil {
+				assert.Equal(t, tc.expFlows, data.Flows, "Unexpected remaining flows")
+			}
+		})
+	}
+}
+
+func TestGetAntiSpoofFlows(t *testing.T) {
+	mac := "02:00:00:00:00:01"
+	testCases := []struct {
+		name     string
+		ips      []string
+		expFlows []ovsFlow
+	}{
+		{
+			name: "allow MAC without IP",
+			expFlows: []ovsFlow{
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01", "resubmit(,1)"},
+				{"table=0,priority=80,in_port=vhost0", "drop"},
+			},
+		},
+		{
+			name: "allow IPv4 and IPv6 addresses",
+			ips:  []string{"192.168.1.10/24", "2001:db8::10/64"},
+			expFlows: []ovsFlow{
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01,ip,nw_src=192.168.1.10", "resubmit(,1)"},
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01,arp,arp_spa=192.168.1.10,arp_sha=02:00:00:00:00:01", "resubmit(,1)"},
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01,ipv6,ipv6_src=2001:db8::10", "resubmit(,1)"},
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01,icmp6,icmp_type=136,nd_target=2001:db8::10", "resubmit(,1)"},
+				{"table=0,priority=90,in_port=vhost0,dl_src=02:00:00:00:00:01,icmp6,icmp_type=135", "resubmit(,1)"},
+				{"table=0,priority=80,in_port=vhost0", "drop"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ips []*current.IPConfig
+			for _, ip := range tc.ips {
+				ipAddr, ipNet, err := net.ParseCIDR(ip)
+				require.NoError(t, err, "Can't parse IP address")
+				ipNet.IP = ipAddr
+				ips = append(ips, &current.IPConfig{Address: *ipNet})
+			}
+			assert.Equal(t, tc.expFlows, getAntiSpoofFlows("vhost0", mac, ips), "Unexpected flows")
+		})
+	}
+}
+
+func TestGetFlowCookie(t *testing.T) {
+	t.Run("derive cookie from container and interface", func(t *testing.T) {
+		cookie := getFlowCookie("0958c8871b32fc0ab8f5e1a8e9b4d1cb", "net1")
+		assert.NotZero(t, cookie, "Unexpected cookie")
+		assert.Equal(t, cookie, getFlowCookie("0958c8871b32fc0ab8f5e1a8e9b4d1cb", "net1"), "Cookie not stable")
+		assert.NotEqual(t, cookie, getFlowCookie("0958c8871b32fc0ab8f5e1a8e9b4d1cb", "net2"), "Cookie shared by interfaces")
+		assert.NotEqual(t, cookie, getFlowCookie("1a69d9982c43fd1bc9a6f2b9fac5e2dc", "net1"), "Cookie shared by containers")
+	})
+}
+
+func TestGetGatewayPortName(t *testing.T) {
+	t.Run("keep short bridge name", func(t *testing.T) {
+		assert.Equal(t, "br0-gw", getGatewayPortName("br0"), "Unexpected port name")
+		assert.Equal(t, "bridge-12345-gw", getGatewayPortName("bridge-12345"), "Unexpected port name")
+	})
+	t.Run("hash long bridge name", func(t *testing.T) {
+		portName := getGatewayPortName("bridge-network-a")
+		assert.Regexp(t, "^gw-[0-9a-f]{8}$", portName, "Unexpected port name")
+		assert.Equal(t, portName, getGatewayPortName("bridge-network-a"), "Port name not stable")
+		assert.NotEqual(t, portName, getGatewayPortName("bridge-network-b"), "Port name shared by bridges")
+	})
+}
+
+func TestGetGateways(t *testing.T) {
+	t.Run("return IPv4 gateways in CIDR notation", func(t *testing.T) {
+		ipResult := &current.Result{
+			Interfaces: []*current.Interface{{Name: "net1"}},
+			IPs: []*current.IPConfig{
+				{Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}, Gateway: net.ParseIP("192.168.1.1")},
+				{Address: net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)}, Gateway: net.ParseIP("fd00::1")},
+			},
+		}
+		assert.Equal(t, []string{"192.168.1.1/24"}, getGateways(ipResult, "net1"), "Unexpected gateways")
+	})
+}
+
+func TestAddLocalNetworkGateway(t *testing.T) {
+	testCases := []struct {
+		name       string
+		portBridge string
+		expErr     error
+	}{
+		{
+			name:       "skip existing gateway port",
+			portBridge: "br0",
+		},
+		{
+			name:       "fail with gateway port on other bridge",
+			portBridge: "br1",
+			expErr:     errors.New("ERROR: Gateway port br0-gw of bridge br0 exists on bridge br1"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &types.NetConf{HostConf: types.UserSpaceConf{BridgeConf: types.BridgeConf{BridgeName: "br0"}}}
+
+			execCommand := &FakeExecCommand{Out: []byte(tc.portBridge + "\n")}
+			SetExecCommand(execCommand)
+			err := addLocalNetworkGateway(vsctlController{}, conf, "net1", &current.Result{})
+			SetDefaultExecCommand()
+
+			if tc.expErr == nil {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Equal(t, tc.expErr.Error(), err.Error(), "Unexpected result")
+			}
+			assert.Equal(t, []string{"port-to-br", "br0-gw"}, execCommand.Args, "Unexpected ovs command arguments")
+		})
+	}
+}
+
+func TestAddDelLocalNetworkAntiSpoof(t *testing.T) {
+	testCases := []struct {
+		name      string
+		ifName    string
+		fakeErr   error
+		expErr    error
+		expCookie bool
+	}{
+		{
+			name:      "add anti-spoofing flows",
+			expCookie: true,
+		},
+		{
+			name:   "fail without result interface",
+			ifName: "net9",
+			expErr: errors.New("ERROR: OvsConf.AntiSpoof set but no result for net9"),
+		},
+		{
+			name:    "fail to add flow",
+			fakeErr: errors.New("ofctl error"),
+			expErr:  errors.New("ofctl error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := testdata.GetTestArgs()
+			conf := &types.NetConf{HostConf: types.UserSpaceConf{BridgeConf: types.BridgeConf{BridgeName: "br0"}}}
+			data := OvsSavedData{Vhostname: "vhost0", IfMac: "02:00:00:00:00:01"}
+			result := &current.Result{
+				Interfaces: []*current.Interface{{Name: args.IfName}},
+				IPs:        []*current.IPConfig{{Address: net.IPNet{IP: net.IPv4(192, 168, 1, 10), Mask: net.CIDRMask(24, 32)}}},
+			}
+
+			if tc.ifName != "" {
+				args.IfName = tc.ifName
+			}
+
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			err := addLocalNetworkAntiSpoof(conf, args, result, &data)
+			SetDefaultExecCommand()
+
+			if tc.expErr == nil {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Equal(t, tc.expErr.Error(), err.Error(), "Unexpected result")
+			}
+			if !tc.expCookie {
+				assert.Empty(t, result.Interfaces[0].Mac, "Unexpected interface MAC")
+				return
+			}
+			cookie := getFlowCookie(args.ContainerID, args.IfName)
+			assert.Equal(t, cookie, data.FlowCookie, "Cookie not saved")
+			assert.Equal(t, data.IfMac, result.Interfaces[0].Mac, "Interface MAC not returned")
+			assert.Equal(t, []string{"add-flow", "br0", fmt.Sprintf("cookie=%#x,table=0,priority=80,in_port=vhost0,actions=drop", cookie)},
+				execCommand.Args, "Unexpected ovs command arguments")
+
+			execCommand = &FakeExecCommand{}
+			SetExecCommand(execCommand)
+			err = delLocalNetworkAntiSpoof(conf, &data)
+			SetDefaultExecCommand()
+
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, []string{"del-flows", "br0", fmt.Sprintf("cookie=%#x/-1", cookie)}, execCommand.Args, "Unexpected ovs command arguments")
+			assert.Zero(t, data.FlowCookie, "Cookie not cleared")
+		})
+	}
+}
+
+func TestConfigBridgeTables(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fakeErr error
+		expArgs []string
+	}{
+		{
+			name:    "set up bridge tables",
+			expArgs: []string{"add-flow", "br0", "table=0,priority=0,actions=resubmit(,1)"},
+		},
+		{
+			name:    "fail to add forward table flow",
+			fakeErr: errors.New("Can't insert a flow"),
+			expArgs: []string{"add-flow", "br0", "table=1,priority=0,actions=NORMAL"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			err := configBridgeTables("br0")
+			SetDefaultExecCommand()
+			assert.Equal(t, tc.fakeErr, err, "Unexpected result")
+			assert.Equal(t, "ovs-ofctl", execCommand.Cmd, "Unexpected command executed")
+			assert.Equal(t, tc.expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
+
+func TestAntiSpoofPipeline(t *testing.T) {
+	l3Mac := "02:00:00:00:00:0a"
+	podMac := "02:00:00:00:00:0b"
+
+	// Bridge with an "interface" pod on vhost-l3 and an anti-spoofed pod
+	// on vhost-as, and a pod without anti-spoofing on vhost-open
+	flows := getBridgeTableFlows()
+	flows = append(flows, getL3InterfaceFlows("vhost-l3", l3Mac, "02:00:00:00:00:01", []*current.IPConfig{
+		{Address: net.IPNet{IP: net.IPv4(192, 168, 1, 10), Mask: net.CIDRMask(24, 32)}},
+	})...)
+	flows = append(flows, getAntiSpoofFlows("vhost-as", podMac, []*current.IPConfig{
+		{Address: net.IPNet{IP: net.IPv4(192, 168, 1, 20), Mask: net.CIDRMask(24, 32)}},
+	})...)
+
+	testCases := []struct {
+		name      string
+		packet    map[string]string
+		expAction string
+	}{
+		{
+			name:      "route packet from pod address to L3 pod",
+			packet:    map[string]string{"in_port": "vhost-as", "dl_src": podMac, "ip": "", "nw_src": "192.168.1.20", "nw_dst": "192.168.1.10"},
+			expAction: "output:vhost-l3",
+		},
+		{
+			name:      "drop packet with spoofed IP to L3 pod",
+			packet:    map[string]string{"in_port": "vhost-as", "dl_src": podMac, "ip": "", "nw_src": "192.168.1.99", "nw_dst": "192.168.1.10"},
+			expAction: "drop",
+		},
+		{
+			name:      "drop packet with spoofed MAC to L3 pod",
+			packet:    map[string]string{"in_port": "vhost-as", "dl_src": "02:00:00:00:00:99", "ip": "", "nw_src": "192.168.1.20", "nw_dst": "192.168.1.10"},
+			expAction: "drop",
+		},
+		{
+			name:      "drop ARP for spoofed IP",
+			packet:    map[string]string{"in_port": "vhost-as", "dl_src": podMac, "arp": "", "arp_op": "1", "arp_spa": "192.168.1.99", "arp_sha": podMac},
+			expAction: "drop",
+		},
+		{
+			name:      "switch ARP for pod address",
+			packet:    map[string]string{"in_port": "vhost-as", "dl_src": podMac, "arp": "", "arp_op": "1", "arp_spa": "192.168.1.20", "arp_sha": podMac},
+			expAction: "NORMAL",
+		},
+		{
+			name:      "route packet from pod without anti-spoofing",
+			packet:    map[string]string{"in_port": "vhost-open", "dl_src": "02:00:00:00:00:99", "ip": "", "nw_src": "192.168.1.99", "nw_dst": "192.168.1.10"},
+			expAction: "output:vhost-l3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expAction, runTestFlows(t, flows, tc.packet), "Unexpected action")
+		})
+	}
+}
+
+// runTestFlows() - Pass a packet through the OpenFlow tables like OvS, and
+//
+//	return the final action applied to it. The packet holds the fields and
+//	protocol keywords used in the matches of the flows.
+func runTestFlows(t *testing.T, flows []ovsFlow, packet map[string]string) string {
+	table := "0"
+	for hops := 0; hops < 8; hops++ {
+		var best *ovsFlow
+		bestPriority := -1
+		for i := range flows {
+			fields := map[string]string{"table": "0", "priority": "32768"}
+			matches := true
+			for _, field := range strings.Split(flows[i].match, ",") {
+				key, value, _ := strings.Cut(field, "=")
+				if key == "table" || key == "priority" {
+					fields[key] = value
+				} else if packetValue, ok := packet[key]; !ok || packetValue != value {
+					matches = false
+				}
+			}
+			priority, err := strconv.Atoi(fields["priority"])
+			require.NoError(t, err, "Invalid flow priority")
+			if matches && fields["table"] == table && priority > bestPriority {
+				best, bestPriority = &flows[i], priority
+			}
+		}
+		if best == nil {
+			return "drop"
+		}
+
+		if resubmit, found := strings.CutPrefix(best.actions, "resubmit(,"); found {
+			table = strings.TrimSuffix(resubmit, ")")
+			continue
+		}
+		actions := strings.Split(best.actions, ",")
+		return actions[len(actions)-1]
+	}
+
+	require.Fail(t, "Resubmit loop")
+	return ""
+}
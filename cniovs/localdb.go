@@ -46,6 +46,24 @@ type OvsSavedData struct {
 	Vhostname string `json:"vhostname"` // Vhost Port name
 	VhostMac  string `json:"vhostmac"`  // Vhost port MAC address
 	IfMac     string `json:"ifmac"`     // Interface Mac address
+
+	// VLAN configuration of the port
+	VlanId int   `json:"vlanId,omitempty"` // Access VLAN Id, or native VLAN Id of a trunk port
+	Trunks []int `json:"trunks,omitempty"` // VLAN Ids of a trunk port
+
+	// Match of each OpenFlow rule added for an "interface" NetType, so
+	// cmdDel() can remove exactly those rules with "del-flows --strict"
+	Flows []string `json:"flows,omitempty"`
+
+	// Cookie of the anti-spoofing OpenFlow rules of the vhost port, so
+	// cmdDel() can remove exactly those rules
+	FlowCookie uint64 `json:"flowCookie,omitempty"`
+
+	// Attachment identity, used by cmdGC() to find and delete stale attachments
+	NetName     string `json:"netName"`     // NetConf Name
+	ContainerID string `json:"containerId"` // From args.ContainerID
+	IfName      string `json:"ifName"`      // From args.IfName
+	SharedDir   string `json:"sharedDir"`   // Directory socketfiles were created in
 }
 
 //
@@ -89,14 +107,9 @@ func LoadConfig(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) err
 	path := filepath.Join(localDir, fileName)
 
 	if _, err := os.Stat(path); err == nil {
-		if dataBytes, err := os.ReadFile(path); err == nil {
-			if err = json.Unmarshal(dataBytes, data); err != nil {
-				return fmt.Errorf("ERROR: Failed to parse OVS saved data: %v", err)
-			}
-		} else {
-			return fmt.Errorf("ERROR: Failed to read OVS saved data: %v", err)
+		if err = readConfigFile(path, data); err != nil {
+			return err
 		}
-
 	} else {
 		path = ""
 	}
@@ -106,3 +119,61 @@ func LoadConfig(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) err
 
 	return nil
 }
+
+// ReadConfig() - Retrieve the data saved by SaveConfig() without deleting it.
+//
+//	Used by cmdCheck(), which must leave the saved data in place. Unlike
+//	LoadConfig(), a missing file is reported as an error.
+func ReadConfig(conf *types.NetConf, args *skel.CmdArgs, data *OvsSavedData) error {
+
+	fileName := fmt.Sprintf("local-%s-%s.json", args.ContainerID[:12], args.IfName)
+	path := filepath.Join(annotations.DefaultLocalCNIDir, fileName)
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("ERROR: No OVS saved data found: %v", err)
+	}
+
+	return readConfigFile(path, data)
+}
+
+// ListConfig() - Retrieve the saved data of every attachment of the given
+//
+//	network. Used by cmdGC() to find attachments that are no longer valid.
+//	Files that can't be parsed, or were saved before the attachment identity
+//	was recorded, are skipped.
+func ListConfig(conf *types.NetConf) ([]OvsSavedData, error) {
+	var dataList []OvsSavedData
+
+	paths, err := filepath.Glob(filepath.Join(annotations.DefaultLocalCNIDir, "local-*.json"))
+	if err != nil {
+		return dataList, err
+	}
+
+	for _, path := range paths {
+		var data OvsSavedData
+		if err = readConfigFile(path, &data); err != nil {
+			continue
+		}
+		if data.ContainerID != "" && data.NetName == conf.Name {
+			dataList = append(dataList, data)
+		}
+	}
+
+	return dataList, nil
+}
+
+//
+// Local Functions
+//
+
+func readConfigFile(path string, data *OvsSavedData) error {
+	if dataBytes, err := os.ReadFile(path); err == nil {
+		if err = json.Unmarshal(dataBytes, data); err != nil {
+			return fmt.Errorf("ERROR: Failed to parse OVS saved data: %v", err)
+		}
+	} else {
+		return fmt.Errorf("ERROR: Failed to read OVS saved data: %v", err)
+	}
+
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
 	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -124,3 +125,43 @@ func TestLoadConfig(t *testing.T) {
 
 	}
 }
+
+func TestReadConfig(t *testing.T) {
+	t.Run("read saved data and keep it", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+		saved := &OvsSavedData{Vhostname: "vhost0", VhostMac: "fe:ed:de:ad:be:ef"}
+
+		var data OvsSavedData
+		require.Error(t, ReadConfig(nil, args, &data), "Missing data shall be reported")
+
+		require.NoError(t, SaveConfig(nil, args, saved), "Unexpected error")
+		require.NoError(t, ReadConfig(nil, args, &data), "Can't read stored data")
+		assert.Equal(t, saved, &data, "Unexpected data retrieved")
+
+		// saved data shall still be available for delete
+		data = OvsSavedData{}
+		require.NoError(t, LoadConfig(nil, args, &data), "Can't load stored data")
+		assert.Equal(t, saved, &data, "Unexpected data retrieved")
+	})
+}
+
+func TestListConfig(t *testing.T) {
+	t.Run("list saved data of a network", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+		otherArgs := testdata.GetTestArgs()
+		conf := &types.NetConf{Name: "list-" + args.ContainerID[:12]}
+
+		saved := &OvsSavedData{Vhostname: "vhost0", NetName: conf.Name, ContainerID: args.ContainerID, IfName: args.IfName}
+		require.NoError(t, SaveConfig(conf, args, saved), "Unexpected error")
+		require.NoError(t, SaveConfig(conf, otherArgs, &OvsSavedData{Vhostname: "vhost1", NetName: "other-network", ContainerID: otherArgs.ContainerID}), "Unexpected error")
+		defer func() {
+			var data OvsSavedData
+			_ = LoadConfig(conf, args, &data)
+			_ = LoadConfig(conf, otherArgs, &data)
+		}()
+
+		dataList, err := ListConfig(conf)
+		require.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []OvsSavedData{*saved}, dataList, "Unexpected data retrieved")
+	})
+}
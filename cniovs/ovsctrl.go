@@ -15,16 +15,27 @@
 package cniovs
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/intel/userspace-cni-network-plugin/logging"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
 )
 
 const defaultOvSSocketDir = "/usr/local/var/run/openvswitch/"
 
+// Seconds ovs-vsctl waits for ovsdb-server before giving up when probing it.
+const ovsdbProbeTimeout = "5"
+
+// Port vlan_mode when both a tag and trunks are set: the tag is the native
+// VLAN, sent and received untagged.
+const nativeVlanMode = "native-untagged"
+
 /*
 OVS command execution handling and its public interface
 */
@@ -53,11 +64,85 @@ func execCommand(cmd string, args []string) ([]byte, error) {
 	return ovsCommand.execCommand(cmd, args)
 }
 
+/*
+Backends used to control OVS. The "vsctl" backend forks ovs-vsctl for each
+request, the "ovsdb" backend (see ovsdb.go) talks to ovsdb-server directly.
+Flows are always written with ovs-ofctl.
+*/
+
+type ovsController interface {
+	createVhostPort(sock_dir string, sock_name string, client bool, bridge_name string, vlan_tag int, trunks []int) (string, error)
+	deleteVhostPort(sock_name string, bridge_name string) error
+	createBridge(bridge_name string) error
+	createGatewayPort(port_name string, bridge_name string) error
+	deleteBridge(bridge_name string) error
+	getVhostPortMac(sock_name string) (string, error)
+	findBridge(bridge_name string) bool
+	doesBridgeContainInterfaces(bridge_name string, ignore_ports ...string) bool
+	getPortBridge(sock_name string) (string, error)
+	checkOvsdbConnection() error
+}
+
+func getOvsController(conf *types.NetConf) (ovsController, error) {
+	if conf.OvsConf.Backend == "" || conf.OvsConf.Backend == "vsctl" {
+		return vsctlController{}, nil
+	} else if conf.OvsConf.Backend == "ovsdb" {
+		socket := conf.OvsConf.OvsdbSocket
+		if socket == "" {
+			socket = defaultOvsdbSocket
+		}
+		return ovsdbController{socket: socket}, nil
+	}
+	return nil, errors.New("ERROR: Unknown OvsConf.Backend:" + conf.OvsConf.Backend)
+}
+
+type vsctlController struct{}
+
+func (vsctlController) createVhostPort(sock_dir string, sock_name string, client bool, bridge_name string, vlan_tag int, trunks []int) (string, error) {
+	return createVhostPort(sock_dir, sock_name, client, bridge_name, vlan_tag, trunks)
+}
+
+func (vsctlController) deleteVhostPort(sock_name string, bridge_name string) error {
+	return deleteVhostPort(sock_name, bridge_name)
+}
+
+func (vsctlController) createBridge(bridge_name string) error {
+	return createBridge(bridge_name)
+}
+
+func (vsctlController) createGatewayPort(port_name string, bridge_name string) error {
+	return createGatewayPort(port_name, bridge_name)
+}
+
+func (vsctlController) deleteBridge(bridge_name string) error {
+	return deleteBridge(bridge_name)
+}
+
+func (vsctlController) getVhostPortMac(sock_name string) (string, error) {
+	return getVhostPortMac(sock_name)
+}
+
+func (vsctlController) findBridge(bridge_name string) bool {
+	return findBridge(bridge_name)
+}
+
+func (vsctlController) doesBridgeContainInterfaces(bridge_name string, ignore_ports ...string) bool {
+	return doesBridgeContainInterfaces(bridge_name, ignore_ports...)
+}
+
+func (vsctlController) getPortBridge(sock_name string) (string, error) {
+	return getPortBridge(sock_name)
+}
+
+func (vsctlController) checkOvsdbConnection() error {
+	return checkOvsdbConnection()
+}
+
 /*
 Functions to control OVS by using the ovs-vsctl cmdline client.
 */
 
-func createVhostPort(sock_dir string, sock_name string, client bool, bridge_name string) (string, error) {
+func createVhostPort(sock_dir string, sock_name string, client bool, bridge_name string, vlan_tag int, trunks []int) (string, error) {
 	var err error
 
 	type_str := "type=dpdkvhostuser"
@@ -76,29 +161,52 @@ func createVhostPort(sock_dir string, sock_name string, client bool, bridge_name
 		args = append(args, socketarg)
 	}
 
+	// COMMAND: ... -- set Port <sock_name> [tag=<vlan_tag>] [trunks=<vlan>,...] [vlan_mode=native-untagged]
+	if vlan_tag != 0 || len(trunks) != 0 {
+		args = append(args, "--", "set", "Port", sock_name)
+		if vlan_tag != 0 {
+			args = append(args, "tag="+strconv.Itoa(vlan_tag))
+		}
+		if len(trunks) != 0 {
+			vlans := make([]string, len(trunks))
+			for i, vlan := range trunks {
+				vlans[i] = strconv.Itoa(vlan)
+			}
+			args = append(args, "trunks="+strings.Join(vlans, ","))
+		}
+		if vlan_tag != 0 && len(trunks) != 0 {
+			args = append(args, "vlan_mode="+nativeVlanMode)
+		}
+	}
+
 	if _, err = execCommand(cmd, args); err != nil {
 		return "", err
 	}
 
 	if !client {
-		// Determine the location OvS uses for Sockets. Default location can be
-		// overwritten with environmental variable: OVS_SOCKDIR
-		ovs_socket_dir, ok := os.LookupEnv("OVS_SOCKDIR")
-		if !ok {
-			ovs_socket_dir = defaultOvSSocketDir
-		}
+		moveVhostSocket(sock_dir, sock_name)
+	}
 
-		// Move socket to defined dir for easier mounting
-		err = os.Rename(filepath.Join(ovs_socket_dir, sock_name), filepath.Join(sock_dir, sock_name))
-		if err != nil {
-			_ = logging.Errorf("Rename ERROR: %v", err)
-			err = nil
+	return sock_name, err
+}
 
-			//deleteVhostPort(sock_name, bridge_name)
-		}
+// moveVhostSocket() - Move a vhost-user server socket to the shared directory.
+//
+//	OvS creates the socket in its own directory, moving it eases mounting.
+func moveVhostSocket(sock_dir string, sock_name string) {
+	// Determine the location OvS uses for Sockets. Default location can be
+	// overwritten with environmental variable: OVS_SOCKDIR
+	ovs_socket_dir, ok := os.LookupEnv("OVS_SOCKDIR")
+	if !ok {
+		ovs_socket_dir = defaultOvSSocketDir
 	}
 
-	return sock_name, err
+	err := os.Rename(filepath.Join(ovs_socket_dir, sock_name), filepath.Join(sock_dir, sock_name))
+	if err != nil {
+		_ = logging.Errorf("Rename ERROR: %v", err)
+
+		//deleteVhostPort(sock_name, bridge_name)
+	}
 }
 
 func deleteVhostPort(sock_name string, bridge_name string) error {
@@ -119,12 +227,60 @@ func createBridge(bridge_name string) error {
 	return err
 }
 
-func configL2Bridge(bridge_name string) error {
-	// COMMAND: ovs-ofctl add-flow <bridge_name> actions=NORMAL
+func createGatewayPort(port_name string, bridge_name string) error {
+	// COMMAND: ovs-vsctl add-port <bridge_name> <port_name> -- set Interface <port_name> type=internal
+	cmd := "ovs-vsctl"
+	args := []string{"add-port", bridge_name, port_name, "--", "set", "Interface", port_name, "type=internal"}
+	_, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.createGatewayPort(): return=%v", err)
+	return err
+}
+
+// configGatewayPort() - Add the gateway addresses to the host interface of
+//
+//	an internal port and bring it up.
+func configGatewayPort(port_name string, gateways []string) error {
+	// COMMAND: ip address add <gateway> dev <port_name>
+	cmd := "ip"
+	for _, gateway := range gateways {
+		args := []string{"address", "add", gateway, "dev", port_name}
+		if _, err := execCommand(cmd, args); err != nil {
+			logging.Verbosef("ovsctl.configGatewayPort(): gateway=%s return=%v", gateway, err)
+			return err
+		}
+	}
+
+	// COMMAND: ip link set <port_name> up
+	args := []string{"link", "set", port_name, "up"}
+	_, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.configGatewayPort(): return=%v", err)
+	return err
+}
+
+func addFlow(bridge_name string, flow string) error {
+	// COMMAND: ovs-ofctl add-flow <bridge_name> <flow>
 	cmd := "ovs-ofctl"
-	args := []string{"add-flow", bridge_name, "actions=NORMAL"}
+	args := []string{"add-flow", bridge_name, flow}
 	_, err := execCommand(cmd, args)
-	logging.Verbosef("ovsctl.configL2Bridge(): return=%v", err)
+	logging.Verbosef("ovsctl.addFlow(): flow=%s return=%v", flow, err)
+	return err
+}
+
+func deleteFlow(bridge_name string, match string) error {
+	// COMMAND: ovs-ofctl --strict del-flows <bridge_name> <match>
+	cmd := "ovs-ofctl"
+	args := []string{"--strict", "del-flows", bridge_name, match}
+	_, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.deleteFlow(): match=%s return=%v", match, err)
+	return err
+}
+
+func deleteCookieFlows(bridge_name string, cookie uint64) error {
+	// COMMAND: ovs-ofctl del-flows <bridge_name> cookie=<cookie>/-1
+	cmd := "ovs-ofctl"
+	args := []string{"del-flows", bridge_name, "cookie=0x" + strconv.FormatUint(cookie, 16) + "/-1"}
+	_, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.deleteCookieFlows(): cookie=%#x return=%v", cookie, err)
 	return err
 }
 
@@ -167,7 +323,10 @@ func findBridge(bridge_name string) bool {
 	return found
 }
 
-func doesBridgeContainInterfaces(bridge_name string) bool {
+// doesBridgeContainInterfaces() - Determine if any port, other than the
+//
+//	ignored ones, is on the bridge.
+func doesBridgeContainInterfaces(bridge_name string, ignore_ports ...string) bool {
 	found := false
 
 	// ovs-vsctl list-ports <bridge_name>
@@ -177,10 +336,33 @@ func doesBridgeContainInterfaces(bridge_name string) bool {
 	name, err := execCommand(cmd, args)
 	logging.Verbosef("ovsctl.doesBridgeContainInterfaces(): return  name=%v err=%v", name, err)
 	if err == nil {
-		if len(name) != 0 {
-			found = true
+		for _, port := range strings.Fields(string(name)) {
+			if !slices.Contains(ignore_ports, port) {
+				found = true
+			}
 		}
 	}
 
 	return found
 }
+
+func getPortBridge(sock_name string) (string, error) {
+	// COMMAND: ovs-vsctl port-to-br <sock_name>
+	cmd := "ovs-vsctl"
+	args := []string{"port-to-br", sock_name}
+	bridge_b, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.getPortBridge(): return  bridge=%v err=%v", bridge_b, err)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bridge_b)), nil
+}
+
+func checkOvsdbConnection() error {
+	// COMMAND: ovs-vsctl --timeout=<seconds> show
+	cmd := "ovs-vsctl"
+	args := []string{"--timeout=" + ovsdbProbeTimeout, "show"}
+	_, err := execCommand(cmd, args)
+	logging.Verbosef("ovsctl.checkOvsdbConnection(): return=%v", err)
+	return err
+}
@@ -114,7 +114,7 @@ func TestCreateVhostPort(t *testing.T) {
 			require.NoFileExists(path.Join(socketDir, socket), "Socket file shall not be in socketDir")
 
 			SetExecCommand(execCommand)
-			result, err := createVhostPort(socketDir, socket, tc.client, "br0")
+			result, err := createVhostPort(socketDir, socket, tc.client, "br0", 0, nil)
 			SetDefaultExecCommand()
 
 			if tc.fakeErr == nil {
@@ -143,6 +143,46 @@ func TestCreateVhostPort(t *testing.T) {
 	}
 }
 
+func TestCreateVhostPortVlan(t *testing.T) {
+	testCases := []struct {
+		name    string
+		vlanTag int
+		trunks  []int
+		expArgs []string
+	}{
+		{
+			name:    "create access port",
+			vlanTag: 10,
+			expArgs: []string{"--", "set", "Port", "vhost0", "tag=10"},
+		},
+		{
+			name:    "create trunk port",
+			trunks:  []int{20, 30},
+			expArgs: []string{"--", "set", "Port", "vhost0", "trunks=20,30"},
+		},
+		{
+			name:    "create trunk port with native vlan",
+			vlanTag: 10,
+			trunks:  []int{20},
+			expArgs: []string{"--", "set", "Port", "vhost0", "tag=10", "trunks=20", "vlan_mode=native-untagged"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{}
+
+			SetExecCommand(execCommand)
+			_, err := createVhostPort("/tmp/shared", "vhost0", true, "br0", tc.vlanTag, tc.trunks)
+			SetDefaultExecCommand()
+
+			require.NoError(t, err, "Unexpected error")
+			expArgs := append([]string{"add-port", "br0", "vhost0", "--", "set", "Interface", "vhost0",
+				"type=dpdkvhostuserclient", "options:vhost-server-path=/tmp/shared/vhost0"}, tc.expArgs...)
+			assert.Equal(t, expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
+
 func TestDeleteVhostPort(t *testing.T) {
 	expCmd := "ovs-vsctl"
 	bridge := "br0"
@@ -208,34 +248,62 @@ func TestCreateBridge(t *testing.T) {
 	}
 }
 
-func TestConfigL2Bridge(t *testing.T) {
-	expCmd := "ovs-ofctl"
-	bridge := "br0"
-	expArgs := []string{"add-flow", "br0", "actions=NORMAL"}
+func TestCreateGatewayPort(t *testing.T) {
+	expCmd := "ovs-vsctl"
+	expArgs := []string{"add-port", "br0", "br0-gw", "--", "set", "Interface", "br0-gw", "type=internal"}
 
 	testCases := []struct {
 		name    string
 		fakeErr error
 	}{
 		{
-			name:    "add L2 flow",
-			fakeErr: nil,
+			name: "create gateway port",
 		},
 		{
-			name:    "fail to add L2 flow",
-			fakeErr: errors.New("Can't insert a flow"),
+			name:    "fail to create gateway port",
+			fakeErr: errors.New("Can't create port"),
 		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			execCommand := &FakeExecCommand{Err: tc.fakeErr}
 			SetExecCommand(execCommand)
-			result := configL2Bridge(bridge)
+			result := createGatewayPort("br0-gw", "br0")
 			SetDefaultExecCommand()
 			assert.Equal(t, tc.fakeErr, result, "Unexpected result")
 			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
 			assert.Equal(t, expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
 
+func TestConfigGatewayPort(t *testing.T) {
+	expCmd := "ip"
+
+	testCases := []struct {
+		name    string
+		fakeErr error
+		expArgs []string
+	}{
+		{
+			name:    "add gateway address and bring port up",
+			expArgs: []string{"link", "set", "br0-gw", "up"},
+		},
+		{
+			name:    "fail to add gateway address",
+			fakeErr: errors.New("Can't add address"),
+			expArgs: []string{"address", "add", "192.168.1.1/24", "dev", "br0-gw"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			result := configGatewayPort("br0-gw", []string{"192.168.1.1/24"})
+			SetDefaultExecCommand()
+			assert.Equal(t, tc.fakeErr, result, "Unexpected result")
+			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
+			assert.Equal(t, tc.expArgs, execCommand.Args, "Unexpected command arguments")
 		})
 	}
 }
@@ -272,6 +340,37 @@ func TestDeleteBridge(t *testing.T) {
 	}
 }
 
+func TestDeleteCookieFlows(t *testing.T) {
+	expCmd := "ovs-ofctl"
+	bridge := "br0"
+	expArgs := []string{"del-flows", "br0", "cookie=0x1f2e3d4c5b6a7988/-1"}
+
+	testCases := []struct {
+		name    string
+		fakeErr error
+	}{
+		{
+			name:    "delete flows with cookie",
+			fakeErr: nil,
+		},
+		{
+			name:    "fail to delete flows with cookie",
+			fakeErr: errors.New("Can't delete flows"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			result := deleteCookieFlows(bridge, 0x1f2e3d4c5b6a7988)
+			SetDefaultExecCommand()
+			assert.Equal(t, tc.fakeErr, result, "Unexpected result")
+			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
+			assert.Equal(t, expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
+
 func TestGetVhostPortMac(t *testing.T) {
 	expCmd := "ovs-vsctl"
 	socket := "tmp-socket"
@@ -323,6 +422,48 @@ func TestGetVhostPortMac(t *testing.T) {
 	}
 }
 
+func TestGetPortBridge(t *testing.T) {
+	expCmd := "ovs-vsctl"
+	socket := "tmp-socket"
+	expArgs := []string{"port-to-br", "tmp-socket"}
+
+	testCases := []struct {
+		name      string
+		fakeOut   []byte
+		fakeErr   error
+		expResult string
+	}{
+		{
+			name:      "get bridge",
+			fakeOut:   []byte("br0"),
+			expResult: "br0",
+		},
+		{
+			name:      "get bridge with new line",
+			fakeOut:   []byte("br0\n"),
+			expResult: "br0",
+		},
+		{
+			name:      "fail to get bridge",
+			fakeOut:   []byte("br0"),
+			fakeErr:   errors.New("no port named tmp-socket"),
+			expResult: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{Out: tc.fakeOut, Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			result, err := getPortBridge(socket)
+			SetDefaultExecCommand()
+			assert.Equal(t, tc.expResult, result, "Unexpected result")
+			assert.Equal(t, tc.fakeErr, err, "Unexpected error")
+			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
+			assert.Equal(t, expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
+
 func TestFindBridge(t *testing.T) {
 	expCmd := "ovs-vsctl"
 	bridge := "br0"
@@ -379,10 +520,11 @@ func TestDoesBridgeContainInterfaces(t *testing.T) {
 	expArgs := []string{"list-ports", "br0"}
 
 	testCases := []struct {
-		name      string
-		fakeOut   []byte
-		fakeErr   error
-		expResult bool
+		name        string
+		fakeOut     []byte
+		fakeErr     error
+		ignorePorts []string
+		expResult   bool
 	}{
 		{
 			name:      "find interface connected to bridge",
@@ -402,6 +544,18 @@ func TestDoesBridgeContainInterfaces(t *testing.T) {
 			fakeErr:   nil,
 			expResult: true,
 		},
+		{
+			name:        "find interface besides ignored gateway port",
+			fakeOut:     []byte("br0-gw\neth2\n"),
+			ignorePorts: []string{"br0-gw"},
+			expResult:   true,
+		},
+		{
+			name:        "ignore gateway port",
+			fakeOut:     []byte("br0-gw\n"),
+			ignorePorts: []string{"br0-gw"},
+			expResult:   false,
+		},
 		{
 			name:      "fail to find interfaces",
 			fakeOut:   []byte(""),
@@ -425,7 +579,7 @@ func TestDoesBridgeContainInterfaces(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			execCommand := &FakeExecCommand{Out: tc.fakeOut, Err: tc.fakeErr}
 			SetExecCommand(execCommand)
-			result := doesBridgeContainInterfaces(bridge)
+			result := doesBridgeContainInterfaces(bridge, tc.ignorePorts...)
 			SetDefaultExecCommand()
 			assert.Equal(t, tc.expResult, result, "Unexpected result")
 			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
@@ -435,6 +589,36 @@ func TestDoesBridgeContainInterfaces(t *testing.T) {
 	}
 }
 
+func TestCheckOvsdbConnection(t *testing.T) {
+	expCmd := "ovs-vsctl"
+	expArgs := []string{"--timeout=5", "show"}
+
+	testCases := []struct {
+		name    string
+		fakeErr error
+	}{
+		{
+			name:    "ovsdb reachable",
+			fakeErr: nil,
+		},
+		{
+			name:    "ovsdb not reachable",
+			fakeErr: errors.New("database connection failed"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCommand := &FakeExecCommand{Err: tc.fakeErr}
+			SetExecCommand(execCommand)
+			result := checkOvsdbConnection()
+			SetDefaultExecCommand()
+			assert.Equal(t, tc.fakeErr, result, "Unexpected result")
+			assert.Equal(t, expCmd, execCommand.Cmd, "Unexpected command executed")
+			assert.Equal(t, expArgs, execCommand.Args, "Unexpected command arguments")
+		})
+	}
+}
+
 func TestExecCommand(t *testing.T) {
 	t.Run("verify execCommand", func(t *testing.T) {
 		cmd := "echo"
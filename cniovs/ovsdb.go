@@ -0,0 +1,458 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//
+// This module provides the "ovsdb" backend, which controls OVS by talking
+// the OVSDB management protocol (RFC 7047) to ovsdb-server over its unix
+// socket, instead of forking ovs-vsctl for each request.
+//
+
+package cniovs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/intel/userspace-cni-network-plugin/logging"
+)
+
+const (
+	ovsdbDatabase      = "Open_vSwitch"
+	defaultOvsdbSocket = defaultOvSSocketDir + "db.sock"
+	ovsdbTimeout       = 5 * time.Second
+)
+
+/*
+OVSDB connection handling and its public interface
+*/
+
+type OvsdbDialerInterface interface {
+	dial(socket string) (net.Conn, error)
+}
+
+type realOvsdbDialer struct{}
+
+func (d *realOvsdbDialer) dial(socket string) (net.Conn, error) {
+	return net.DialTimeout("unix", socket, ovsdbTimeout)
+}
+
+var ovsdbDialer OvsdbDialerInterface = &realOvsdbDialer{}
+
+func SetOvsdbDialer(d OvsdbDialerInterface) {
+	ovsdbDialer = d
+}
+
+func SetDefaultOvsdbDialer() {
+	ovsdbDialer = &realOvsdbDialer{}
+}
+
+/*
+Minimal OVSDB JSON-RPC client. A connection is opened per call, which is
+all a single CNI invocation needs.
+*/
+
+type ovsdbRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Id     interface{}   `json:"id"`
+}
+
+type ovsdbReply struct {
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+	Id     interface{} `json:"id"`
+}
+
+// Either a reply to our request, or a request from the server (i.e. "echo")
+type ovsdbMessage struct {
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  interface{}     `json:"error,omitempty"`
+	Id     interface{}     `json:"id"`
+}
+
+type ovsdbOperation map[string]interface{}
+
+type ovsdbResult struct {
+	Count   int                      `json:"count,omitempty"`
+	Rows    []map[string]interface{} `json:"rows,omitempty"`
+	Uuid    []interface{}            `json:"uuid,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+	Details string                   `json:"details,omitempty"`
+}
+
+func ovsdbCall(socket string, method string, params []interface{}, result interface{}) error {
+	conn, err := ovsdbDialer.dial(socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ovsdbTimeout))
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	if err = enc.Encode(ovsdbRequest{Method: method, Params: params, Id: 0}); err != nil {
+		return err
+	}
+
+	for {
+		var msg ovsdbMessage
+		if err = dec.Decode(&msg); err != nil {
+			return err
+		}
+
+		// Answer keepalives sent by ovsdb-server, ignore other notifications
+		if msg.Method == "echo" {
+			if err = enc.Encode(ovsdbReply{Result: msg.Params, Id: msg.Id}); err != nil {
+				return err
+			}
+			continue
+		} else if msg.Method != "" {
+			continue
+		}
+
+		if msg.Error != nil {
+			return fmt.Errorf("ovsdb %s: %v", method, msg.Error)
+		}
+		return json.Unmarshal(msg.Result, result)
+	}
+}
+
+// ovsdbTransact() - Run the operations as a single transaction.
+//
+//	Either all are applied or none, in which case the first error is returned.
+func ovsdbTransact(socket string, ops ...ovsdbOperation) ([]ovsdbResult, error) {
+	var results []ovsdbResult
+
+	params := []interface{}{ovsdbDatabase}
+	for _, op := range ops {
+		params = append(params, op)
+	}
+
+	if err := ovsdbCall(socket, "transact", params, &results); err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("ovsdb transact: %s: %s", result.Error, result.Details)
+		}
+	}
+	if len(results) < len(ops) {
+		return nil, fmt.Errorf("ovsdb transact: %d results for %d operations", len(results), len(ops))
+	}
+
+	return results, nil
+}
+
+/*
+Helpers to build and parse OVSDB values
+*/
+
+func ovsdbNamedUuid(name string) []interface{} {
+	return []interface{}{"named-uuid", name}
+}
+
+func ovsdbUuidValue(uuid string) []interface{} {
+	return []interface{}{"uuid", uuid}
+}
+
+func ovsdbSet(elems ...interface{}) []interface{} {
+	return []interface{}{"set", elems}
+}
+
+func ovsdbMap(m map[string]string) []interface{} {
+	pairs := []interface{}{}
+	for key, value := range m {
+		pairs = append(pairs, []interface{}{key, value})
+	}
+	return []interface{}{"map", pairs}
+}
+
+func ovsdbWhereName(name string) []interface{} {
+	return []interface{}{[]interface{}{"name", "==", name}}
+}
+
+// ovsdbSetElems() - Return the elements of a set.
+//
+//	A set of exactly one element may be encoded as the bare element.
+func ovsdbSetElems(value interface{}) []interface{} {
+	if pair, ok := value.([]interface{}); ok && len(pair) == 2 && pair[0] == "set" {
+		if elems, ok := pair[1].([]interface{}); ok {
+			return elems
+		}
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	return []interface{}{value}
+}
+
+// ovsdbUuid() - Return the uuid of an ["uuid", <uuid>] value, or "".
+func ovsdbUuid(value interface{}) string {
+	if pair, ok := value.([]interface{}); ok && len(pair) == 2 && pair[0] == "uuid" {
+		if uuid, ok := pair[1].(string); ok {
+			return uuid
+		}
+	}
+	return ""
+}
+
+// ovsdbString() - Return a string column, which is an empty set if unset.
+func ovsdbString(value interface{}) string {
+	for _, elem := range ovsdbSetElems(value) {
+		if str, ok := elem.(string); ok {
+			return str
+		}
+	}
+	return ""
+}
+
+func ovsdbWaitForName(table string, name string, exists bool) ovsdbOperation {
+	until := "=="
+	if exists {
+		until = "!="
+	}
+	return ovsdbOperation{
+		"op":      "wait",
+		"timeout": 0,
+		"table":   table,
+		"where":   ovsdbWhereName(name),
+		"columns": []string{"name"},
+		"until":   until,
+		"rows":    []interface{}{},
+	}
+}
+
+func ovsdbSelectByName(table string, name string, columns ...string) ovsdbOperation {
+	return ovsdbOperation{
+		"op":      "select",
+		"table":   table,
+		"where":   ovsdbWhereName(name),
+		"columns": columns,
+	}
+}
+
+/*
+Functions to control OVS by talking to ovsdb-server.
+*/
+
+type ovsdbController struct {
+	socket string
+}
+
+func (o ovsdbController) createVhostPort(sock_dir string, sock_name string, client bool, bridge_name string, vlan_tag int, trunks []int) (string, error) {
+	iface := map[string]interface{}{"name": sock_name, "type": "dpdkvhostuser"}
+	if client {
+		iface["type"] = "dpdkvhostuserclient"
+		iface["options"] = ovsdbMap(map[string]string{"vhost-server-path": filepath.Join(sock_dir, sock_name)})
+	}
+
+	port := map[string]interface{}{"name": sock_name, "interfaces": ovsdbNamedUuid("iface")}
+	if vlan_tag != 0 {
+		port["tag"] = vlan_tag
+	}
+	if len(trunks) != 0 {
+		vlans := make([]interface{}, len(trunks))
+		for i, vlan := range trunks {
+			vlans[i] = vlan
+		}
+		port["trunks"] = ovsdbSet(vlans...)
+	}
+	if vlan_tag != 0 && len(trunks) != 0 {
+		port["vlan_mode"] = nativeVlanMode
+	}
+
+	// Port must not exist yet and Bridge must, then add Interface and Port
+	_, err := ovsdbTransact(o.socket,
+		ovsdbWaitForName("Port", sock_name, false),
+		ovsdbWaitForName("Bridge", bridge_name, true),
+		ovsdbOperation{"op": "insert", "table": "Interface", "row": iface, "uuid-name": "iface"},
+		ovsdbOperation{"op": "insert", "table": "Port", "row": port, "uuid-name": "port"},
+		ovsdbOperation{"op": "mutate", "table": "Bridge", "where": ovsdbWhereName(bridge_name),
+			"mutations": []interface{}{[]interface{}{"ports", "insert", ovsdbSet(ovsdbNamedUuid("port"))}}},
+	)
+	logging.Verbosef("ovsdb.createVhostPort(): return=%v", err)
+	if err != nil {
+		return "", err
+	}
+
+	if !client {
+		moveVhostSocket(sock_dir, sock_name)
+	}
+
+	return sock_name, nil
+}
+
+func (o ovsdbController) deleteVhostPort(sock_name string, bridge_name string) error {
+	results, err := ovsdbTransact(o.socket, ovsdbSelectByName("Port", sock_name, "_uuid"))
+	if err != nil {
+		return err
+	}
+
+	// Like "ovs-vsctl --if-exists del-port"
+	if len(results[0].Rows) == 0 {
+		return nil
+	}
+
+	// The Port and its Interface are garbage collected once unreferenced
+	uuid := ovsdbUuid(results[0].Rows[0]["_uuid"])
+	_, err = ovsdbTransact(o.socket,
+		ovsdbOperation{"op": "mutate", "table": "Bridge", "where": ovsdbWhereName(bridge_name),
+			"mutations": []interface{}{[]interface{}{"ports", "delete", ovsdbSet(ovsdbUuidValue(uuid))}}},
+	)
+	logging.Verbosef("ovsdb.deleteVhostPort(): return=%v", err)
+	return err
+}
+
+func (o ovsdbController) createBridge(bridge_name string) error {
+	// Same as "ovs-vsctl add-br", including the bridge's internal port
+	_, err := ovsdbTransact(o.socket,
+		ovsdbWaitForName("Bridge", bridge_name, false),
+		ovsdbOperation{"op": "insert", "table": "Interface", "uuid-name": "iface",
+			"row": map[string]interface{}{"name": bridge_name, "type": "internal"}},
+		ovsdbOperation{"op": "insert", "table": "Port", "uuid-name": "port",
+			"row": map[string]interface{}{"name": bridge_name, "interfaces": ovsdbNamedUuid("iface")}},
+		ovsdbOperation{"op": "insert", "table": "Bridge", "uuid-name": "bridge",
+			"row": map[string]interface{}{"name": bridge_name, "datapath_type": "netdev", "ports": ovsdbNamedUuid("port")}},
+		ovsdbOperation{"op": "mutate", "table": "Open_vSwitch", "where": []interface{}{},
+			"mutations": []interface{}{[]interface{}{"bridges", "insert", ovsdbSet(ovsdbNamedUuid("bridge"))}}},
+	)
+	logging.Verbosef("ovsdb.createBridge(): return=%v", err)
+	return err
+}
+
+func (o ovsdbController) createGatewayPort(port_name string, bridge_name string) error {
+	// Port must not exist yet and Bridge must, then add Interface and Port
+	_, err := ovsdbTransact(o.socket,
+		ovsdbWaitForName("Port", port_name, false),
+		ovsdbWaitForName("Bridge", bridge_name, true),
+		ovsdbOperation{"op": "insert", "table": "Interface", "uuid-name": "iface",
+			"row": map[string]interface{}{"name": port_name, "type": "internal"}},
+		ovsdbOperation{"op": "insert", "table": "Port", "uuid-name": "port",
+			"row": map[string]interface{}{"name": port_name, "interfaces": ovsdbNamedUuid("iface")}},
+		ovsdbOperation{"op": "mutate", "table": "Bridge", "where": ovsdbWhereName(bridge_name),
+			"mutations": []interface{}{[]interface{}{"ports", "insert", ovsdbSet(ovsdbNamedUuid("port"))}}},
+	)
+	logging.Verbosef("ovsdb.createGatewayPort(): return=%v", err)
+	return err
+}
+
+func (o ovsdbController) deleteBridge(bridge_name string) error {
+	results, err := ovsdbTransact(o.socket, ovsdbSelectByName("Bridge", bridge_name, "_uuid"))
+	if err != nil {
+		return err
+	}
+	if len(results[0].Rows) == 0 {
+		return errors.New("no bridge named " + bridge_name)
+	}
+
+	// The Bridge, its Ports and Interfaces are garbage collected once
+	// unreferenced
+	uuid := ovsdbUuid(results[0].Rows[0]["_uuid"])
+	_, err = ovsdbTransact(o.socket,
+		ovsdbOperation{"op": "mutate", "table": "Open_vSwitch", "where": []interface{}{},
+			"mutations": []interface{}{[]interface{}{"bridges", "delete", ovsdbSet(ovsdbUuidValue(uuid))}}},
+	)
+	logging.Verbosef("ovsdb.deleteBridge(): return=%v", err)
+	return err
+}
+
+func (o ovsdbController) getVhostPortMac(sock_name string) (string, error) {
+	results, err := ovsdbTransact(o.socket, ovsdbSelectByName("Port", sock_name, "mac"))
+	if err != nil {
+		return "", err
+	}
+	if len(results[0].Rows) == 0 {
+		return "", nil
+	}
+	return ovsdbString(results[0].Rows[0]["mac"]), nil
+}
+
+func (o ovsdbController) findBridge(bridge_name string) bool {
+	results, err := ovsdbTransact(o.socket, ovsdbSelectByName("Bridge", bridge_name, "name"))
+	logging.Verbosef("ovsdb.findBridge(): return  results=%v err=%v", results, err)
+	return err == nil && len(results[0].Rows) != 0
+}
+
+func (o ovsdbController) doesBridgeContainInterfaces(bridge_name string, ignore_ports ...string) bool {
+	// Like "ovs-vsctl list-ports", the bridge's own internal port is ignored
+	results, err := ovsdbTransact(o.socket,
+		ovsdbSelectByName("Bridge", bridge_name, "ports"),
+		ovsdbOperation{"op": "select", "table": "Port", "columns": []string{"_uuid", "name"},
+			"where": []interface{}{[]interface{}{"name", "!=", bridge_name}}},
+	)
+	logging.Verbosef("ovsdb.doesBridgeContainInterfaces(): return  results=%v err=%v", results, err)
+	if err != nil || len(results[0].Rows) == 0 {
+		return false
+	}
+
+	ports := make(map[string]bool)
+	for _, port := range ovsdbSetElems(results[0].Rows[0]["ports"]) {
+		ports[ovsdbUuid(port)] = true
+	}
+	for _, row := range results[1].Rows {
+		if ports[ovsdbUuid(row["_uuid"])] && !slices.Contains(ignore_ports, ovsdbString(row["name"])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o ovsdbController) getPortBridge(sock_name string) (string, error) {
+	results, err := ovsdbTransact(o.socket,
+		ovsdbSelectByName("Port", sock_name, "_uuid"),
+		ovsdbOperation{"op": "select", "table": "Bridge", "where": []interface{}{}, "columns": []string{"name", "ports"}},
+	)
+	logging.Verbosef("ovsdb.getPortBridge(): return  results=%v err=%v", results, err)
+	if err != nil {
+		return "", err
+	}
+	if len(results[0].Rows) == 0 {
+		return "", errors.New("no port named " + sock_name)
+	}
+
+	uuid := ovsdbUuid(results[0].Rows[0]["_uuid"])
+	for _, row := range results[1].Rows {
+		for _, port := range ovsdbSetElems(row["ports"]) {
+			if ovsdbUuid(port) == uuid {
+				return ovsdbString(row["name"]), nil
+			}
+		}
+	}
+	return "", errors.New("port " + sock_name + " is not on any bridge")
+}
+
+func (o ovsdbController) checkOvsdbConnection() error {
+	var dbs []string
+
+	err := ovsdbCall(o.socket, "list_dbs", []interface{}{}, &dbs)
+	logging.Verbosef("ovsdb.checkOvsdbConnection(): return  dbs=%v err=%v", dbs, err)
+	if err != nil {
+		return err
+	}
+	for _, db := range dbs {
+		if db == ovsdbDatabase {
+			return nil
+		}
+	}
+	return errors.New("ovsdb-server does not serve database " + ovsdbDatabase)
+}
@@ -0,0 +1,393 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cniovs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+)
+
+//
+// Fake in-process ovsdb-server suitable for unit testing. It keeps the
+// subset of the Open_vSwitch schema used by the "ovsdb" backend in memory
+// and serves the requests over a net.Pipe(). Only the operations and
+// conditions issued by ovsdbController are supported.
+//
+
+// Columns referencing rows of another table, stored as a list of uuids
+var fakeOvsdbRefs = map[string]map[string]string{
+	"Open_vSwitch": {"bridges": "Bridge"},
+	"Bridge":       {"ports": "Port"},
+	"Port":         {"interfaces": "Interface"},
+}
+
+type fakeOvsdbRow map[string]interface{}
+
+type FakeOvsdbServer struct {
+	Err  error // returned by dial() if set
+	Echo bool  // send an "echo" request before each reply
+
+	mu     sync.Mutex
+	tables map[string]map[string]fakeOvsdbRow
+	nextId int
+}
+
+func NewFakeOvsdbServer() *FakeOvsdbServer {
+	s := &FakeOvsdbServer{tables: make(map[string]map[string]fakeOvsdbRow)}
+	for _, table := range []string{"Open_vSwitch", "Bridge", "Port", "Interface"} {
+		s.tables[table] = make(map[string]fakeOvsdbRow)
+	}
+	s.tables["Open_vSwitch"][s.newUuid()] = fakeOvsdbRow{"bridges": []string{}}
+	return s
+}
+
+// Names() - Return the sorted names of all rows in a table.
+func (s *FakeOvsdbServer) Names(table string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{}
+	for _, row := range s.tables[table] {
+		if name, ok := row["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Column() - Return a column of the named row in its OVSDB wire format.
+func (s *FakeOvsdbServer) Column(table string, name string, column string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for uuid, row := range s.tables[table] {
+		if row["name"] == name {
+			return s.wireValue(table, uuid, row, column)
+		}
+	}
+	return nil
+}
+
+func (s *FakeOvsdbServer) dial(socket string) (net.Conn, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	client, server := net.Pipe()
+	go s.serve(server)
+	return client, nil
+}
+
+func (s *FakeOvsdbServer) serve(conn net.Conn) {
+	// net.Pipe() is unbuffered, so write from a separate goroutine
+	// to let the client answer an "echo" while a reply is pending.
+	outgoing := make(chan interface{}, 2)
+	defer close(outgoing)
+	go func() {
+		defer conn.Close()
+		enc := json.NewEncoder(conn)
+		for msg := range outgoing {
+			if err := enc.Encode(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	dec := json.NewDecoder(conn)
+
+	for {
+		var req ovsdbMessage
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if req.Method == "" {
+			// reply to our own "echo"
+			continue
+		}
+
+		reply := ovsdbReply{Id: req.Id}
+		var params []json.RawMessage
+		_ = json.Unmarshal(req.Params, &params)
+
+		if req.Method == "transact" && len(params) > 0 {
+			reply.Result = s.transact(params[1:])
+		} else if req.Method == "list_dbs" {
+			reply.Result = []string{ovsdbDatabase}
+		} else if req.Method == "echo" {
+			reply.Result = params
+		} else {
+			reply.Error = "unknown method " + req.Method
+		}
+
+		if s.Echo {
+			outgoing <- ovsdbRequest{Method: "echo", Params: []interface{}{}, Id: "echo"}
+		}
+		outgoing <- reply
+	}
+}
+
+func (s *FakeOvsdbServer) newUuid() string {
+	s.nextId++
+	return fmt.Sprintf("%08x-0000-4000-8000-000000000000", s.nextId)
+}
+
+// transact() - Apply the operations to a copy of the tables.
+//
+//	The copy replaces the tables only if all operations succeeded.
+func (s *FakeOvsdbServer) transact(rawOps []json.RawMessage) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := s.copyTables()
+	namedUuids := make(map[string]string)
+	results := []interface{}{}
+
+	for _, rawOp := range rawOps {
+		var op map[string]interface{}
+		_ = json.Unmarshal(rawOp, &op)
+
+		result, err := s.apply(tables, namedUuids, op)
+		if err != nil {
+			return append(results, map[string]interface{}{"error": err.Error(), "details": fmt.Sprintf("%v", op)})
+		}
+		results = append(results, result)
+	}
+
+	s.collectGarbage(tables)
+	s.tables = tables
+	return results
+}
+
+func (s *FakeOvsdbServer) copyTables() map[string]map[string]fakeOvsdbRow {
+	tables := make(map[string]map[string]fakeOvsdbRow)
+	for table, rows := range s.tables {
+		tables[table] = make(map[string]fakeOvsdbRow)
+		for uuid, row := range rows {
+			newRow := fakeOvsdbRow{}
+			for column, value := range row {
+				if refs, ok := value.([]string); ok {
+					value = append([]string{}, refs...)
+				}
+				newRow[column] = value
+			}
+			tables[table][uuid] = newRow
+		}
+	}
+	return tables
+}
+
+func (s *FakeOvsdbServer) apply(tables map[string]map[string]fakeOvsdbRow,
+	namedUuids map[string]string,
+	op map[string]interface{}) (interface{}, error) {
+
+	table, _ := op["table"].(string)
+	rows, ok := tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+
+	matches := func() []string {
+		uuids := []string{}
+		for uuid, row := range rows {
+			if s.match(uuid, row, op["where"]) {
+				uuids = append(uuids, uuid)
+			}
+		}
+		return uuids
+	}
+
+	switch op["op"] {
+	case "insert":
+		uuid := s.newUuid()
+		if name, ok := op["uuid-name"].(string); ok {
+			namedUuids[name] = uuid
+		}
+		row := fakeOvsdbRow{}
+		for column := range fakeOvsdbRefs[table] {
+			row[column] = []string{}
+		}
+		values, _ := op["row"].(map[string]interface{})
+		for column, value := range values {
+			if _, isRef := fakeOvsdbRefs[table][column]; isRef {
+				row[column] = resolveRefs(value, namedUuids)
+			} else {
+				row[column] = value
+			}
+		}
+		rows[uuid] = row
+		return map[string]interface{}{"uuid": ovsdbUuidValue(uuid)}, nil
+
+	case "select":
+		selected := []interface{}{}
+		columns, _ := op["columns"].([]interface{})
+		for _, uuid := range matches() {
+			result := map[string]interface{}{}
+			for _, column := range columns {
+				name, _ := column.(string)
+				result[name] = s.wireValue(table, uuid, rows[uuid], name)
+			}
+			selected = append(selected, result)
+		}
+		return map[string]interface{}{"rows": selected}, nil
+
+	case "mutate":
+		uuids := matches()
+		mutations, _ := op["mutations"].([]interface{})
+		for _, uuid := range uuids {
+			for _, m := range mutations {
+				mutation, _ := m.([]interface{})
+				if len(mutation) != 3 {
+					return nil, fmt.Errorf("bad mutation %v", m)
+				}
+				column, _ := mutation[0].(string)
+				if _, isRef := fakeOvsdbRefs[table][column]; !isRef {
+					return nil, fmt.Errorf("unsupported mutation of column %s", column)
+				}
+				current, _ := rows[uuid][column].([]string)
+				refs := resolveRefs(mutation[2], namedUuids)
+				if mutation[1] == "insert" {
+					current = append(current, refs...)
+				} else if mutation[1] == "delete" {
+					kept := []string{}
+					for _, ref := range current {
+						if !containsString(refs, ref) {
+							kept = append(kept, ref)
+						}
+					}
+					current = kept
+				} else {
+					return nil, fmt.Errorf("unsupported mutator %v", mutation[1])
+				}
+				rows[uuid][column] = current
+			}
+		}
+		return map[string]interface{}{"count": len(uuids)}, nil
+
+	case "delete":
+		uuids := matches()
+		for _, uuid := range uuids {
+			delete(rows, uuid)
+		}
+		return map[string]interface{}{"count": len(uuids)}, nil
+
+	case "wait":
+		// Only waiting for no rows ("==") or some rows ("!=") is supported
+		found := len(matches()) != 0
+		if (op["until"] == "==" && found) || (op["until"] == "!=" && !found) {
+			return nil, fmt.Errorf("timed out")
+		}
+		return map[string]interface{}{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operation %v", op["op"])
+}
+
+// match() - Evaluate a where clause of "==" and "!=" conditions.
+func (s *FakeOvsdbServer) match(uuid string, row fakeOvsdbRow, where interface{}) bool {
+	conditions, _ := where.([]interface{})
+	for _, c := range conditions {
+		condition, _ := c.([]interface{})
+		if len(condition) != 3 {
+			return false
+		}
+
+		var value interface{} = row[condition[0].(string)]
+		if condition[0] == "_uuid" {
+			value = uuid
+		}
+		expected := condition[2]
+		if ref := ovsdbUuid(expected); ref != "" {
+			expected = ref
+		}
+
+		equal := fmt.Sprintf("%v", value) == fmt.Sprintf("%v", expected)
+		if (condition[1] == "==" && !equal) || (condition[1] == "!=" && equal) {
+			return false
+		}
+	}
+	return true
+}
+
+// wireValue() - Return a column in its OVSDB wire format.
+func (s *FakeOvsdbServer) wireValue(table string, uuid string, row fakeOvsdbRow, column string) interface{} {
+	if column == "_uuid" {
+		return ovsdbUuidValue(uuid)
+	}
+	if _, isRef := fakeOvsdbRefs[table][column]; isRef {
+		elems := []interface{}{}
+		refs, _ := row[column].([]string)
+		for _, ref := range refs {
+			elems = append(elems, ovsdbUuidValue(ref))
+		}
+		return ovsdbSet(elems...)
+	}
+	if value, ok := row[column]; ok {
+		return value
+	}
+	// Unset optional columns are empty sets
+	return ovsdbSet()
+}
+
+// collectGarbage() - Remove rows no longer referenced.
+//
+//	Same as ovsdb-server does for all tables but the root Open_vSwitch table.
+func (s *FakeOvsdbServer) collectGarbage(tables map[string]map[string]fakeOvsdbRow) {
+	for _, t := range []struct{ parent, column, child string }{
+		{"Open_vSwitch", "bridges", "Bridge"},
+		{"Bridge", "ports", "Port"},
+		{"Port", "interfaces", "Interface"},
+	} {
+		referenced := make(map[string]bool)
+		for _, row := range tables[t.parent] {
+			refs, _ := row[t.column].([]string)
+			for _, ref := range refs {
+				referenced[ref] = true
+			}
+		}
+		for uuid := range tables[t.child] {
+			if !referenced[uuid] {
+				delete(tables[t.child], uuid)
+			}
+		}
+	}
+}
+
+func resolveRefs(value interface{}, namedUuids map[string]string) []string {
+	refs := []string{}
+	for _, elem := range ovsdbSetElems(value) {
+		pair, ok := elem.([]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		ref, _ := pair[1].(string)
+		if pair[0] == "named-uuid" {
+			ref = namedUuids[ref]
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func containsString(list []string, value string) bool {
+	for _, elem := range list {
+		if elem == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cniovs
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOvsController(t *testing.T) {
+	testCases := []struct {
+		name    string
+		ovsConf types.OvsConf
+		expCtrl ovsController
+		expErr  string
+	}{
+		{
+			name:    "default to vsctl",
+			expCtrl: vsctlController{},
+		},
+		{
+			name:    "select vsctl",
+			ovsConf: types.OvsConf{Backend: "vsctl"},
+			expCtrl: vsctlController{},
+		},
+		{
+			name:    "select ovsdb with default socket",
+			ovsConf: types.OvsConf{Backend: "ovsdb"},
+			expCtrl: ovsdbController{socket: "/usr/local/var/run/openvswitch/db.sock"},
+		},
+		{
+			name:    "select ovsdb with given socket",
+			ovsConf: types.OvsConf{Backend: "ovsdb", OvsdbSocket: "/run/openvswitch/db.sock"},
+			expCtrl: ovsdbController{socket: "/run/openvswitch/db.sock"},
+		},
+		{
+			name:    "fail with unknown backend",
+			ovsConf: types.OvsConf{Backend: "nonsense"},
+			expErr:  "ERROR: Unknown OvsConf.Backend:nonsense",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl, err := getOvsController(&types.NetConf{OvsConf: tc.ovsConf})
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, tc.expCtrl, ctrl, "Unexpected controller")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestOvsdbBridge(t *testing.T) {
+	server := NewFakeOvsdbServer()
+	SetOvsdbDialer(server)
+	defer SetDefaultOvsdbDialer()
+	ctrl := ovsdbController{socket: defaultOvsdbSocket}
+
+	assert.False(t, ctrl.findBridge("br0"), "Unexpected bridge found")
+	require.NoError(t, ctrl.createBridge("br0"), "Can't create bridge")
+	assert.True(t, ctrl.findBridge("br0"), "Bridge not found")
+	assert.Equal(t, "netdev", server.Column("Bridge", "br0", "datapath_type"), "Unexpected datapath type")
+	assert.Equal(t, []string{"br0"}, server.Names("Port"), "Internal port not created")
+	assert.Equal(t, "internal", server.Column("Interface", "br0", "type"), "Unexpected internal interface type")
+
+	// bridge own internal port is not an interface
+	assert.False(t, ctrl.doesBridgeContainInterfaces("br0"), "Unexpected interfaces on bridge")
+
+	err := ctrl.createBridge("br0")
+	require.Error(t, err, "Bridge created twice")
+	assert.Contains(t, err.Error(), "timed out", "Unexpected error")
+
+	require.NoError(t, ctrl.deleteBridge("br0"), "Can't delete bridge")
+	assert.False(t, ctrl.findBridge("br0"), "Bridge not deleted")
+	assert.Empty(t, server.Names("Port"), "Ports not garbage collected")
+	assert.Empty(t, server.Names("Interface"), "Interfaces not garbage collected")
+
+	err = ctrl.deleteBridge("br0")
+	require.Error(t, err, "Deleted missing bridge")
+	assert.Equal(t, "no bridge named br0", err.Error(), "Unexpected error")
+}
+
+func TestOvsdbVhostPort(t *testing.T) {
+	testCases := []struct {
+		name       string
+		client     bool
+		bridge     string
+		expType    string
+		expOptions interface{}
+		expErr     string
+	}{
+		{
+			name:       "add client port",
+			client:     true,
+			bridge:     "br0",
+			expType:    "dpdkvhostuserclient",
+			expOptions: []interface{}{"map", []interface{}{[]interface{}{"vhost-server-path", "/tmp/shared/vhost0"}}},
+		},
+		{
+			name:       "add server port",
+			bridge:     "br0",
+			expType:    "dpdkvhostuser",
+			expOptions: ovsdbSet(),
+		},
+		{
+			name:   "fail to add port to missing bridge",
+			bridge: "br1",
+			expErr: "timed out",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewFakeOvsdbServer()
+			SetOvsdbDialer(server)
+			defer SetDefaultOvsdbDialer()
+			ctrl := ovsdbController{socket: defaultOvsdbSocket}
+			require.NoError(t, ctrl.createBridge("br0"), "Can't create bridge")
+
+			name, err := ctrl.createVhostPort("/tmp/shared", "vhost0", tc.client, tc.bridge, 0, nil)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+				assert.Equal(t, []string{"br0"}, server.Names("Interface"), "Interface left behind")
+				return
+			}
+			require.NoError(t, err, "Can't create port")
+			assert.Equal(t, "vhost0", name, "Unexpected port name")
+			assert.Equal(t, tc.expType, server.Column("Interface", "vhost0", "type"), "Unexpected interface type")
+			assert.Equal(t, tc.expOptions, server.Column("Interface", "vhost0", "options"), "Unexpected interface options")
+
+			bridge, err := ctrl.getPortBridge("vhost0")
+			require.NoError(t, err, "Can't get port bridge")
+			assert.Equal(t, "br0", bridge, "Unexpected port bridge")
+			assert.True(t, ctrl.doesBridgeContainInterfaces("br0"), "Port not on bridge")
+
+			mac, err := ctrl.getVhostPortMac("vhost0")
+			require.NoError(t, err, "Can't get port mac")
+			assert.Empty(t, mac, "Unexpected port mac")
+
+			_, err = ctrl.createVhostPort("/tmp/shared", "vhost0", tc.client, tc.bridge, 0, nil)
+			assert.Error(t, err, "Port created twice")
+
+			require.NoError(t, ctrl.deleteVhostPort("vhost0", "br0"), "Can't delete port")
+			assert.Equal(t, []string{"br0"}, server.Names("Port"), "Port not deleted")
+			assert.Equal(t, []string{"br0"}, server.Names("Interface"), "Interface not garbage collected")
+			assert.False(t, ctrl.doesBridgeContainInterfaces("br0"), "Port still on bridge")
+
+			// like --if-exists
+			assert.NoError(t, ctrl.deleteVhostPort("vhost0", "br0"), "Unexpected error")
+
+			_, err = ctrl.getPortBridge("vhost0")
+			require.Error(t, err, "Error was expected")
+			assert.Equal(t, "no port named vhost0", err.Error(), "Unexpected error")
+		})
+	}
+}
+
+func TestOvsdbVhostPortVlan(t *testing.T) {
+	testCases := []struct {
+		name        string
+		vlanTag     int
+		trunks      []int
+		expTag      interface{}
+		expTrunks   interface{}
+		expVlanMode interface{}
+	}{
+		{
+			name:        "add port without vlan",
+			expTag:      ovsdbSet(),
+			expTrunks:   ovsdbSet(),
+			expVlanMode: ovsdbSet(),
+		},
+		{
+			name:        "add access port",
+			vlanTag:     10,
+			expTag:      float64(10),
+			expTrunks:   ovsdbSet(),
+			expVlanMode: ovsdbSet(),
+		},
+		{
+			name:        "add trunk port with native vlan",
+			vlanTag:     10,
+			trunks:      []int{20, 30},
+			expTag:      float64(10),
+			expTrunks:   []interface{}{"set", []interface{}{float64(20), float64(30)}},
+			expVlanMode: "native-untagged",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewFakeOvsdbServer()
+			SetOvsdbDialer(server)
+			defer SetDefaultOvsdbDialer()
+			ctrl := ovsdbController{socket: defaultOvsdbSocket}
+			require.NoError(t, ctrl.createBridge("br0"), "Can't create bridge")
+
+			_, err := ctrl.createVhostPort("/tmp/shared", "vhost0", true, "br0", tc.vlanTag, tc.trunks)
+			require.NoError(t, err, "Can't create port")
+			assert.Equal(t, tc.expTag, server.Column("Port", "vhost0", "tag"), "Unexpected port tag")
+			assert.Equal(t, tc.expTrunks, server.Column("Port", "vhost0", "trunks"), "Unexpected port trunks")
+			assert.Equal(t, tc.expVlanMode, server.Column("Port", "vhost0", "vlan_mode"), "Unexpected port vlan_mode")
+		})
+	}
+}
+
+func TestOvsdbCheckConnection(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialErr error
+		echo    bool
+	}{
+		{
+			name: "ovsdb reachable",
+		},
+		{
+			name: "answer echo from ovsdb",
+			echo: true,
+		},
+		{
+			name:    "ovsdb not reachable",
+			dialErr: errors.New("connection refused"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewFakeOvsdbServer()
+			server.Err = tc.dialErr
+			server.Echo = tc.echo
+			SetOvsdbDialer(server)
+			defer SetDefaultOvsdbDialer()
+			ctrl := ovsdbController{socket: defaultOvsdbSocket}
+
+			err := ctrl.checkOvsdbConnection()
+			assert.Equal(t, tc.dialErr, err, "Unexpected result")
+			assert.Equal(t, tc.dialErr == nil, ctrl.createBridge("br0") == nil, "Unexpected transaction result")
+		})
+	}
+}
+
+func TestAddDelOnHostOvsdb(t *testing.T) {
+	ovs := CniOvs{}
+	args := testdata.GetTestArgs()
+	netConf := &types.NetConf{
+		OvsConf:  types.OvsConf{Backend: "ovsdb"},
+		HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", VhostConf: types.VhostConf{Mode: "client"}},
+	}
+
+	sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cniovs-")
+	require.NoError(t, dirErr, "Can't create temporary directory")
+	defer os.RemoveAll(sharedDir)
+
+	server := NewFakeOvsdbServer()
+	SetOvsdbDialer(server)
+	defer SetDefaultOvsdbDialer()
+	// flows are still written by ovs-ofctl
+	execCommand := &FakeExecCommand{}
+	SetExecCommand(execCommand)
+	defer SetDefaultExecCommand()
+
+	require.NoError(t, ovs.AddOnHost(netConf, args, nil, sharedDir, nil), "Can't add interface")
+	assert.Equal(t, []string{"br0"}, server.Names("Bridge"), "Bridge not created")
+	assert.Contains(t, server.Names("Port"), netConf.HostConf.VhostConf.Socketfile, "Port not created")
+	assert.Equal(t, []string{"add-flow", "br0", "table=0,priority=0,actions=resubmit(,1)"}, execCommand.Args, "Bridge tables not set up")
+	require.NoError(t, ovs.CheckOnHost(netConf, args, sharedDir), "Interface not found")
+
+	require.NoError(t, ovs.DelFromHost(netConf, args, sharedDir), "Can't delete interface")
+	assert.Empty(t, server.Names("Bridge"), "Bridge not deleted")
+	assert.Empty(t, server.Names("Port"), "Port not deleted")
+}
+
+func TestAddDelOnHostOvsdbGateway(t *testing.T) {
+	ovs := CniOvs{}
+	args := testdata.GetTestArgs()
+	netConf := &types.NetConf{
+		OvsConf: types.OvsConf{Backend: "ovsdb"},
+		HostConf: types.UserSpaceConf{Engine: "ovs-dpdk", IfType: "vhostuser", NetType: "bridge", VhostConf: types.VhostConf{Mode: "client"},
+			BridgeConf: types.BridgeConf{Gateway: true}},
+	}
+	ipResult := &current.Result{
+		Interfaces: []*current.Interface{{Name: args.IfName}},
+		IPs: []*current.IPConfig{{Interface: current.Int(0), Gateway: net.ParseIP("192.168.1.1"),
+			Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}}},
+	}
+
+	sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cniovs-")
+	require.NoError(t, dirErr, "Can't create temporary directory")
+	defer os.RemoveAll(sharedDir)
+
+	server := NewFakeOvsdbServer()
+	SetOvsdbDialer(server)
+	defer SetDefaultOvsdbDialer()
+	// flows and addresses are still set by commands
+	execCommand := &FakeExecCommand{}
+	SetExecCommand(execCommand)
+	defer SetDefaultExecCommand()
+
+	// no gateway without IPAM gateway, the new bridge is rolled back
+	require.Error(t, ovs.AddOnHost(netConf, args, nil, sharedDir, nil), "Gateway added without address")
+	assert.Empty(t, server.Names("Bridge"), "Bridge not rolled back")
+
+	require.NoError(t, ovs.AddOnHost(netConf, args, nil, sharedDir, ipResult), "Can't add interface")
+	assert.Contains(t, server.Names("Port"), "br0-gw", "Gateway port not created")
+	assert.Equal(t, "internal", server.Column("Interface", "br0-gw", "type"), "Unexpected gateway interface type")
+	assert.Equal(t, []string{"link", "set", "br0-gw", "up"}, execCommand.Args, "Gateway port not up")
+
+	// the gateway port goes with the bridge
+	require.NoError(t, ovs.DelFromHost(netConf, args, sharedDir), "Can't delete interface")
+	assert.Empty(t, server.Names("Bridge"), "Bridge not deleted")
+	assert.Empty(t, server.Names("Port"), "Gateway port not deleted")
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 Cisco and/or its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary simple-client is an example VPP management application that exercises the
+// govpp API on real-world use-cases.
+package vppacl
+
+// Generates Go bindings for all VPP APIs located in the json directory.
+//go:generate go run go.fd.io/govpp/cmd/binapi-generator --output-dir=../../bin_api
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip_types"
+	"go.fd.io/govpp/api"
+)
+
+// Constants
+const debugAcl = false
+
+// ACL index requesting a new ACL from acl_add_replace
+const newAclIndex = ^uint32(0)
+
+// A rule of an ACL. Src and Dst must be of the same address family. For
+// TCP and UDP, the port ranges match the source and destination ports, for
+// ICMP the type and code.
+type AclRule struct {
+	Permit       bool
+	Src          net.IPNet
+	Dst          net.IPNet
+	Proto        uint8 // IP protocol, 0 matches any
+	SrcPortFirst uint16
+	SrcPortLast  uint16
+	DstPortFirst uint16
+	DstPortLast  uint16
+}
+
+//
+// API Functions
+//
+
+// Attempt to create an ACL with the input rules, tagged. Traffic matching
+// none of the rules is denied.
+// Return: Index of the new ACL
+func AddAcl(ch api.Channel, tag string, rules []AclRule) (uint32, error) {
+
+	// Populate the Request Structure
+	req := &acl.ACLAddReplace{
+		ACLIndex: newAclIndex,
+		Tag:      tag,
+		Count:    uint32(len(rules)),
+	}
+	for _, rule := range rules {
+		action := acl_types.ACL_ACTION_API_DENY
+		if rule.Permit {
+			action = acl_types.ACL_ACTION_API_PERMIT
+		}
+		req.R = append(req.R, acl_types.ACLRule{
+			IsPermit:               action,
+			SrcPrefix:              ip_types.NewPrefix(rule.Src),
+			DstPrefix:              ip_types.NewPrefix(rule.Dst),
+			Proto:                  ip_types.IPProto(rule.Proto),
+			SrcportOrIcmptypeFirst: rule.SrcPortFirst,
+			SrcportOrIcmptypeLast:  rule.SrcPortLast,
+			DstportOrIcmpcodeFirst: rule.DstPortFirst,
+			DstportOrIcmpcodeLast:  rule.DstPortLast,
+		})
+	}
+
+	reply := &acl.ACLAddReplaceReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugAcl {
+			fmt.Println("Error creating ACL:", err)
+		}
+		return 0, err
+	}
+
+	return reply.ACLIndex, nil
+}
+
+// Attempt to delete an ACL. It must not be applied to any interface.
+func DeleteAcl(ch api.Channel, aclIndex uint32) error {
+
+	// Populate the Request Structure
+	req := &acl.ACLDel{
+		ACLIndex: aclIndex,
+	}
+
+	reply := &acl.ACLDelReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugAcl {
+			fmt.Println("Error deleting ACL:", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Attempt to set the ACLs applied to an interface, replacing any applied
+// before. Input ACLs filter the traffic received on the interface, output
+// ACLs the traffic sent. Empty lists remove all ACLs from the interface.
+func SetInterfaceAcls(ch api.Channel, swIfIndex interface_types.InterfaceIndex, inputAcls []uint32, outputAcls []uint32) error {
+
+	// Populate the Request Structure
+	req := &acl.ACLInterfaceSetACLList{
+		SwIfIndex: swIfIndex,
+		Count:     uint8(len(inputAcls) + len(outputAcls)),
+		NInput:    uint8(len(inputAcls)),
+		Acls:      append(append([]uint32{}, inputAcls...), outputAcls...),
+	}
+
+	reply := &acl.ACLInterfaceSetACLListReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugAcl {
+			fmt.Println("Error setting interface ACLs:", err)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package vppacl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip_types"
+)
+
+func TestAddAcl(t *testing.T) {
+	_, anyNet, _ := net.ParseCIDR("0.0.0.0/0")
+	_, podNet, _ := net.ParseCIDR("192.168.1.0/24")
+
+	testCases := []struct {
+		name     string
+		rules    []AclRule
+		retval   int32
+		expIndex uint32
+		expRules []acl_types.ACLRule
+		expErr   string
+	}{
+		{
+			name: "add ACL",
+			rules: []AclRule{
+				{Permit: true, Src: *podNet, Dst: *anyNet, Proto: 6, SrcPortLast: 65535, DstPortFirst: 80, DstPortLast: 80},
+				{Src: *anyNet, Dst: *anyNet, SrcPortLast: 65535, DstPortLast: 65535},
+			},
+			expIndex: 3,
+			expRules: []acl_types.ACLRule{
+				{
+					IsPermit:               acl_types.ACL_ACTION_API_PERMIT,
+					SrcPrefix:              ip_types.NewPrefix(*podNet),
+					DstPrefix:              ip_types.NewPrefix(*anyNet),
+					Proto:                  ip_types.IP_API_PROTO_TCP,
+					SrcportOrIcmptypeLast:  65535,
+					DstportOrIcmpcodeFirst: 80,
+					DstportOrIcmpcodeLast:  80,
+				},
+				{
+					IsPermit:              acl_types.ACL_ACTION_API_DENY,
+					SrcPrefix:             ip_types.NewPrefix(*anyNet),
+					DstPrefix:             ip_types.NewPrefix(*anyNet),
+					SrcportOrIcmptypeLast: 65535,
+					DstportOrIcmpcodeLast: 65535,
+				},
+			},
+		},
+		{
+			name:   "fail to add ACL",
+			rules:  []AclRule{{Src: *anyNet, Dst: *anyNet}},
+			retval: -1,
+			expErr: "VPPApiError: Unspecified Error (-1)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var request *acl.ACLAddReplace
+			mockVpp := mockVppReply(t, &acl.ACLAddReplace{}, &acl.ACLAddReplaceReply{ACLIndex: 3, Retval: tc.retval},
+				func(req api.Message) { request = req.(*acl.ACLAddReplace) })
+			ch := openMockCh(t, mockVpp)
+
+			index, err := AddAcl(ch, "usrspcni-0958c8871b32-net1-egress", tc.rules)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, tc.expIndex, index, "Unexpected ACL index")
+				expRequest := &acl.ACLAddReplace{
+					ACLIndex: ^uint32(0),
+					Tag:      "usrspcni-0958c8871b32-net1-egress",
+					Count:    uint32(len(tc.expRules)),
+					R:        tc.expRules,
+				}
+				assert.Equal(t, expRequest, request, "Unexpected request")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestDeleteAcl(t *testing.T) {
+	var request *acl.ACLDel
+	mockVpp := mockVppReply(t, &acl.ACLDel{}, &acl.ACLDelReply{},
+		func(req api.Message) { request = req.(*acl.ACLDel) })
+	ch := openMockCh(t, mockVpp)
+
+	require.NoError(t, DeleteAcl(ch, 3), "Unexpected error")
+	assert.Equal(t, &acl.ACLDel{ACLIndex: 3}, request, "Unexpected request")
+}
+
+func TestSetInterfaceAcls(t *testing.T) {
+	testCases := []struct {
+		name       string
+		inputAcls  []uint32
+		outputAcls []uint32
+		expRequest *acl.ACLInterfaceSetACLList
+	}{
+		{
+			name:       "set input and output ACLs",
+			inputAcls:  []uint32{3},
+			outputAcls: []uint32{4},
+			expRequest: &acl.ACLInterfaceSetACLList{SwIfIndex: 5, Count: 2, NInput: 1, Acls: []uint32{3, 4}},
+		},
+		{
+			name:       "set output ACL only",
+			outputAcls: []uint32{4},
+			expRequest: &acl.ACLInterfaceSetACLList{SwIfIndex: 5, Count: 1, Acls: []uint32{4}},
+		},
+		{
+			name:       "remove ACLs",
+			expRequest: &acl.ACLInterfaceSetACLList{SwIfIndex: 5, Acls: []uint32{}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var request *acl.ACLInterfaceSetACLList
+			mockVpp := mockVppReply(t, &acl.ACLInterfaceSetACLList{}, &acl.ACLInterfaceSetACLListReply{},
+				func(req api.Message) { request = req.(*acl.ACLInterfaceSetACLList) })
+			ch := openMockCh(t, mockVpp)
+
+			require.NoError(t, SetInterfaceAcls(ch, 5, tc.inputAcls, tc.outputAcls), "Unexpected error")
+			assert.Equal(t, tc.expRequest, request, "Unexpected request")
+		})
+	}
+}
+
+// Return a mock VPP replying to requests of the type of req, which are
+// decoded and passed to record.
+func mockVppReply(t *testing.T, req api.Message, reply api.Message, record func(api.Message)) *mock.VppAdapter {
+	mockVpp := mock.NewVppAdapter()
+	mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		if request.MsgName != req.GetMessageName() {
+			return nil, 0, false
+		}
+		require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+		record(req)
+
+		msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+		data, err := mockVpp.ReplyBytes(request, reply)
+		require.NoError(t, err, "Can't encode reply")
+		return data, msgID, true
+	})
+	return mockVpp
+}
+
+// Open a channel to the mock VPP.
+func openMockCh(t *testing.T, mockVpp *mock.VppAdapter) api.Channel {
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return ch
+}
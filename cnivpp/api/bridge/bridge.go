@@ -21,8 +21,12 @@ package vppbridge
 
 import (
 	"fmt"
+	"net"
 
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ethernet_types"
+	interfaces "github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface"
 	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip_types"
 	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
 	. "github.com/intel/userspace-cni-network-plugin/pkg/types"
 	"go.fd.io/govpp/api"
@@ -31,14 +35,38 @@ import (
 // Constants
 const debugBridge = false
 
+// VLAN tag rewrite operation popping one tag, from VPP l2_vtr.h
+const vtrOpPop1 = 3
+
+// Options of a Bridge Domain, applied when it is created.
+type BridgeOptions struct {
+	Learn   bool
+	Flood   bool
+	UuFlood bool
+	Forward bool
+	ArpTerm bool
+	MacAge  uint8 // In minutes, 0 disables MAC aging
+}
+
 //
 // API Functions
 //
 
-// Attempt to create a Bridge Domain.
-func CreateBridge(ch api.Channel, bridgeDomain uint32) error {
+// Return the Bridge Domain options VPP uses by default.
+func DefaultBridgeOptions() BridgeOptions {
+	return BridgeOptions{
+		Learn:   true,
+		Flood:   true,
+		UuFlood: true,
+		Forward: true,
+	}
+}
+
+// Attempt to create a Bridge Domain. If it already exists, it is left
+// untouched, including its options.
+func CreateBridge(ch api.Channel, bridgeDomain uint32, options BridgeOptions) error {
 
-	exists, _ := findBridge(ch, bridgeDomain)
+	exists, _, _ := findBridge(ch, bridgeDomain)
 	if exists {
 		if debugBridge {
 			fmt.Printf("Bridge Domain %d already exist, exit\n", bridgeDomain)
@@ -49,12 +77,12 @@ func CreateBridge(ch api.Channel, bridgeDomain uint32) error {
 	// Populate the Request Structure
 	req := &l2.BridgeDomainAddDel{
 		BdID:    bridgeDomain,
-		Flood:   true,
-		UuFlood: true,
-		Forward: true,
-		Learn:   true,
-		ArpTerm: false,
-		MacAge:  0,
+		Flood:   options.Flood,
+		UuFlood: options.UuFlood,
+		Forward: options.Forward,
+		Learn:   options.Learn,
+		ArpTerm: options.ArpTerm,
+		MacAge:  options.MacAge,
 		//BdTag   []byte `struc:"[64]byte"`
 		IsAdd: true,
 	}
@@ -77,7 +105,7 @@ func CreateBridge(ch api.Channel, bridgeDomain uint32) error {
 func DeleteBridge(ch api.Channel, bridgeDomain uint32) error {
 
 	// Determine if bridge domain exists
-	exists, count := findBridge(ch, bridgeDomain)
+	exists, count, _ := findBridge(ch, bridgeDomain)
 	if !exists || count != 0 {
 		return nil
 	}
@@ -103,12 +131,12 @@ func DeleteBridge(ch api.Channel, bridgeDomain uint32) error {
 }
 
 // Attempt to add an interface to a Bridge Domain.
-func AddBridgeInterface(ch api.Channel, bridgeDomain uint32, swIfId interface_types.InterfaceIndex) error {
+func AddBridgeInterface(ch api.Channel, bridgeDomain uint32, swIfId interface_types.InterfaceIndex, options BridgeOptions) error {
 	var err error
 
 	// Determine if bridge domain exists, and if not, create it. CreateBridge()
 	// checks for existence.
-	err = CreateBridge(ch, bridgeDomain)
+	err = CreateBridge(ch, bridgeDomain, options)
 	if err != nil {
 		return err
 	}
@@ -159,6 +187,17 @@ func RemoveBridgeInterface(ch api.Channel, bridgeDomain uint32, swIfId interface
 		return err
 	}
 
+	// The gateway BVI lives as long as there are other interfaces on the
+	// Bridge Domain, so remove it with the last one. A BVI not tagged as the
+	// gateway was set up by the operator, it and the Bridge Domain are kept.
+	exists, count, bviSwIfId := findBridge(ch, bridgeDomain)
+	if exists && count == 1 && bviSwIfId != DefaultSwIfIndex && isBridgeGateway(ch, bridgeDomain, bviSwIfId) {
+		err = removeBridgeGateway(ch, bridgeDomain, bviSwIfId)
+		if err != nil {
+			return err
+		}
+	}
+
 	// DeleteBridge() checks to see if there are any interfaces still attached,
 	// and if so, bail. So attempt to delete and let it validate.
 	err = DeleteBridge(ch, bridgeDomain)
@@ -169,6 +208,138 @@ func RemoveBridgeInterface(ch api.Channel, bridgeDomain uint32, swIfId interface
 	return err
 }
 
+// Attempt to add a gateway to a Bridge Domain: a loopback BVI, tagged with
+// the Bridge Domain, with the input addresses. The gateway is shared by all
+// the interfaces on the Bridge Domain, so if it already has a BVI, it is
+// left untouched. The Bridge Domain must exist.
+func AddBridgeGateway(ch api.Channel, bridgeDomain uint32, gateways []net.IPNet) error {
+
+	exists, _, bviSwIfId := findBridge(ch, bridgeDomain)
+	if !exists {
+		return fmt.Errorf("ERROR: Bridge Domain %d does not exist", bridgeDomain)
+	}
+	if bviSwIfId != DefaultSwIfIndex {
+		if debugBridge {
+			fmt.Printf("Bridge Domain %d already has BVI %d, exit\n", bridgeDomain, bviSwIfId)
+		}
+		return nil
+	}
+
+	// Populate the Request Structure, VPP picks the MAC address
+	req := &interfaces.CreateLoopback{}
+
+	reply := &interfaces.CreateLoopbackReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error creating loopback:", err)
+		}
+		return err
+	}
+	bviSwIfId = reply.SwIfIndex
+
+	err = setBridgeGateway(ch, bridgeDomain, bviSwIfId, gateways)
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error setting up gateway:", err)
+		}
+		_ = deleteLoopback(ch, bviSwIfId)
+		return err
+	}
+
+	return err
+}
+
+// Return the tag of the gateway BVI of a Bridge Domain.
+func GetGatewayTag(bridgeDomain uint32) string {
+	return fmt.Sprintf("usrspcni-bvi-%d", bridgeDomain)
+}
+
+// Attempt to set the VLAN tag rewrite of an interface so the outer dot1q tag
+// is popped from received frames and pushed back on transmitted frames.
+// Used on VLAN sub-interfaces, so the Bridge Domain sees untagged frames.
+func SetVlanTagPop(ch api.Channel, swIfId interface_types.InterfaceIndex) error {
+
+	// Populate the Request Structure
+	req := &l2.L2InterfaceVlanTagRewrite{
+		SwIfIndex: swIfId,
+		VtrOp:     vtrOpPop1,
+		PushDot1q: 1,
+	}
+
+	reply := &l2.L2InterfaceVlanTagRewriteReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error setting VLAN tag rewrite:", err)
+		}
+		return err
+	}
+
+	return err
+}
+
+// Attempt to add or delete an IP/MAC entry of the ARP termination table of
+// a Bridge Domain. IPv6 entries answer Neighbor Solicitations.
+func AddDelArpTermEntry(ch api.Channel, bridgeDomain uint32, ip net.IP, mac net.HardwareAddr, isAdd bool) error {
+
+	// Populate the Request Structure
+	req := &l2.BdIPMacAddDel{
+		IsAdd: isAdd,
+		Entry: l2.BdIPMac{
+			BdID: bridgeDomain,
+			IP:   ip_types.NewAddress(ip),
+			Mac:  ethernet_types.NewMacAddress(mac),
+		},
+	}
+
+	reply := &l2.BdIPMacAddDelReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error updating ARP termination entry:", err)
+		}
+		return err
+	}
+
+	return err
+}
+
+// Determine if an interface is a member of a Bridge Domain.
+// Return: true - Member  false - otherwise (including Bridge Domain not found)
+func IsBridgeInterface(ch api.Channel, bridgeDomain uint32, swIfId interface_types.InterfaceIndex) bool {
+	var rval bool = false
+
+	// Populate the Message Structure
+	req := &l2.BridgeDomainDump{
+		BdID: bridgeDomain, SwIfIndex: DefaultSwIfIndex,
+	}
+	reqCtx := ch.SendMultiRequest(req)
+
+	// See findBridge(), use SendMultiRequest to handle a non-existent Bridge Domain.
+	for {
+		reply := &l2.BridgeDomainDetails{}
+		stop, err := reqCtx.ReceiveReply(reply)
+		if stop || err != nil {
+			break // break out of the loop
+		}
+
+		for _, swIf := range reply.SwIfDetails {
+			if swIf.SwIfIndex == swIfId {
+				rval = true
+			}
+		}
+	}
+
+	return rval
+}
+
 // Dump the input Bridge data to Stdout. There is not VPP API to dump
 // all the Bridges.
 func DumpBridge(ch api.Channel, bridgeDomain uint32) {
@@ -211,13 +382,133 @@ func DumpBridge(ch api.Channel, bridgeDomain uint32) {
 // Local Functions
 //
 
+// Tag a loopback, make it the BVI of a Bridge Domain, add the gateway
+// addresses and bring it up.
+func setBridgeGateway(ch api.Channel, bridgeDomain uint32, bviSwIfId interface_types.InterfaceIndex, gateways []net.IPNet) error {
+
+	tagReq := &interfaces.SwInterfaceTagAddDel{
+		IsAdd:     true,
+		SwIfIndex: bviSwIfId,
+		Tag:       GetGatewayTag(bridgeDomain),
+	}
+	err := ch.SendRequest(tagReq).ReceiveReply(&interfaces.SwInterfaceTagAddDelReply{})
+	if err != nil {
+		return err
+	}
+
+	bridgeReq := &l2.SwInterfaceSetL2Bridge{
+		BdID:        bridgeDomain,
+		RxSwIfIndex: bviSwIfId,
+		Shg:         0,
+		PortType:    l2.L2_API_PORT_TYPE_BVI,
+		Enable:      true,
+	}
+	err = ch.SendRequest(bridgeReq).ReceiveReply(&l2.SwInterfaceSetL2BridgeReply{})
+	if err != nil {
+		return err
+	}
+
+	for _, gateway := range gateways {
+		addressReq := &interfaces.SwInterfaceAddDelAddress{
+			SwIfIndex: bviSwIfId,
+			IsAdd:     true,
+			Prefix:    ip_types.NewAddressWithPrefix(gateway),
+		}
+		err = ch.SendRequest(addressReq).ReceiveReply(&interfaces.SwInterfaceAddDelAddressReply{})
+		if err != nil {
+			return fmt.Errorf("ERROR: Failed to add gateway address %s: %v", gateway.String(), err)
+		}
+	}
+
+	stateReq := &interfaces.SwInterfaceSetFlags{
+		SwIfIndex: bviSwIfId,
+		Flags:     interface_types.IF_STATUS_API_FLAG_ADMIN_UP,
+	}
+	return ch.SendRequest(stateReq).ReceiveReply(&interfaces.SwInterfaceSetFlagsReply{})
+}
+
+// Determine if the BVI of a Bridge Domain is the gateway added by
+// AddBridgeGateway(), from its tag.
+func isBridgeGateway(ch api.Channel, bridgeDomain uint32, bviSwIfId interface_types.InterfaceIndex) bool {
+	var rval bool = false
+
+	// Populate the Message Structure
+	req := &interfaces.SwInterfaceDump{
+		SwIfIndex: bviSwIfId,
+	}
+	reqCtx := ch.SendMultiRequest(req)
+
+	for {
+		reply := &interfaces.SwInterfaceDetails{}
+		stop, err := reqCtx.ReceiveReply(reply)
+		if stop || err != nil {
+			break // break out of the loop
+		}
+		if reply.SwIfIndex == bviSwIfId && reply.Tag == GetGatewayTag(bridgeDomain) {
+			rval = true
+		}
+	}
+
+	return rval
+}
+
+// Attempt to remove the gateway BVI from a Bridge Domain and delete it.
+func removeBridgeGateway(ch api.Channel, bridgeDomain uint32, bviSwIfId interface_types.InterfaceIndex) error {
+
+	// Populate the Request Structure
+	req := &l2.SwInterfaceSetL2Bridge{
+		BdID:        bridgeDomain,
+		RxSwIfIndex: bviSwIfId,
+		Shg:         0,
+		PortType:    l2.L2_API_PORT_TYPE_BVI,
+		Enable:      false,
+	}
+
+	reply := &l2.SwInterfaceSetL2BridgeReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error removing BVI from bridge domain:", err)
+		}
+		return err
+	}
+
+	return deleteLoopback(ch, bviSwIfId)
+}
+
+// Attempt to delete a loopback interface.
+func deleteLoopback(ch api.Channel, swIfId interface_types.InterfaceIndex) error {
+
+	// Populate the Request Structure
+	req := &interfaces.DeleteLoopback{
+		SwIfIndex: swIfId,
+	}
+
+	reply := &interfaces.DeleteLoopbackReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugBridge {
+			fmt.Println("Error deleting loopback:", err)
+		}
+		return err
+	}
+
+	return err
+}
+
 // Determine if the input Bridge exists.
 // Return: true - Exists  false - otherwise
 //
-//	uint32 - Number of associated interfaces
-func findBridge(ch api.Channel, bridgeDomain uint32) (bool, uint32) {
+//	uint32 - Number of associated interfaces, including the BVI
+//	InterfaceIndex - BVI of the Bridge, DefaultSwIfIndex if none
+func findBridge(ch api.Channel, bridgeDomain uint32) (bool, uint32, interface_types.InterfaceIndex) {
 	var rval bool = false
 	var count uint32
+	var bviSwIfId interface_types.InterfaceIndex = DefaultSwIfIndex
 
 	// Populate the Message Structure
 	req := &l2.BridgeDomainDump{
@@ -243,10 +534,11 @@ func findBridge(ch api.Channel, bridgeDomain uint32) (bool, uint32) {
 			break // break out of the loop
 		} else {
 			count = reply.NSwIfs
+			bviSwIfId = reply.BviSwIfIndex
 		}
 
 		rval = true
 	}
 
-	return rval, count
+	return rval, count, bviSwIfId
 }
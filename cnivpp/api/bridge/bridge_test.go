@@ -0,0 +1,302 @@
+package vppbridge
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ethernet_types"
+	interfaces "github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memclnt"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+)
+
+func TestCreateBridge(t *testing.T) {
+	testCases := []struct {
+		name       string
+		exists     bool
+		options    BridgeOptions
+		expRequest *l2.BridgeDomainAddDel
+	}{
+		{
+			name:       "create bridge with default options",
+			options:    DefaultBridgeOptions(),
+			expRequest: &l2.BridgeDomainAddDel{BdID: 4, Flood: true, UuFlood: true, Forward: true, Learn: true, IsAdd: true},
+		},
+		{
+			name:       "create bridge with ARP termination and MAC aging",
+			options:    BridgeOptions{Forward: true, ArpTerm: true, MacAge: 5},
+			expRequest: &l2.BridgeDomainAddDel{BdID: 4, Forward: true, ArpTerm: true, MacAge: 5, IsAdd: true},
+		},
+		{
+			name:    "keep existing bridge",
+			exists:  true,
+			options: BridgeOptions{ArpTerm: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var request *l2.BridgeDomainAddDel
+			mockVpp := mock.NewVppAdapter()
+			mockVpp.MockReplyHandler(func(req mock.MessageDTO) ([]byte, uint16, bool) {
+				msg := &l2.BridgeDomainAddDel{}
+				if req.MsgName != msg.GetMessageName() {
+					return nil, 0, false
+				}
+				require.NoError(t, codec.DefaultCodec.DecodeMsg(req.Data, msg), "Can't decode request")
+				request = msg
+
+				reply := &l2.BridgeDomainAddDelReply{}
+				msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+				data, err := mockVpp.ReplyBytes(req, reply)
+				require.NoError(t, err, "Can't encode reply")
+				return data, msgID, true
+			})
+			ch := openMockCh(t, mockVpp)
+
+			if tc.exists {
+				mockVpp.MockReply(&l2.BridgeDomainDetails{BdID: 4})
+			}
+			mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+			require.NoError(t, CreateBridge(ch, 4, tc.options), "Unexpected error")
+			assert.Equal(t, tc.expRequest, request, "Unexpected request")
+		})
+	}
+}
+
+func TestAddDelArpTermEntry(t *testing.T) {
+	testCases := []struct {
+		name   string
+		ip     string
+		isAdd  bool
+		retval int32
+		expErr string
+	}{
+		{
+			name:  "add IPv4 entry",
+			ip:    "192.168.1.10",
+			isAdd: true,
+		},
+		{
+			name:  "add IPv6 entry",
+			ip:    "fd00::10",
+			isAdd: true,
+		},
+		{
+			name: "delete IPv4 entry",
+			ip:   "192.168.1.10",
+		},
+		{
+			name:   "fail to add entry",
+			ip:     "192.168.1.10",
+			isAdd:  true,
+			retval: -1,
+			expErr: "VPPApiError: Unspecified Error (-1)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []*l2.BdIPMacAddDel{}
+			mockVpp := mock.NewVppAdapter()
+			mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+				req := &l2.BdIPMacAddDel{}
+				if request.MsgName != req.GetMessageName() {
+					return nil, 0, false
+				}
+				require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+				requests = append(requests, req)
+
+				reply := &l2.BdIPMacAddDelReply{Retval: tc.retval}
+				msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+				data, err := mockVpp.ReplyBytes(request, reply)
+				require.NoError(t, err, "Can't encode reply")
+				return data, msgID, true
+			})
+			ch := openMockCh(t, mockVpp)
+
+			ip := net.ParseIP(tc.ip)
+			mac, _ := net.ParseMAC("02:00:00:00:00:0a")
+			err := AddDelArpTermEntry(ch, 4, ip, mac, tc.isAdd)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+
+			require.Len(t, requests, 1, "Unexpected number of requests")
+			expRequest := &l2.BdIPMacAddDel{
+				IsAdd: tc.isAdd,
+				Entry: l2.BdIPMac{BdID: 4, IP: ip_types.NewAddress(ip), Mac: ethernet_types.NewMacAddress(mac)},
+			}
+			assert.Equal(t, expRequest, requests[0], "Unexpected request")
+		})
+	}
+}
+
+func TestAddBridgeGateway(t *testing.T) {
+	testCases := []struct {
+		name        string
+		bviSwIfId   interface_types.InterfaceIndex
+		expRequests []string
+	}{
+		{
+			name:      "create gateway BVI",
+			bviSwIfId: types.DefaultSwIfIndex,
+			expRequests: []string{"create_loopback", "sw_interface_tag_add_del", "sw_interface_set_l2_bridge",
+				"sw_interface_add_del_address", "sw_interface_add_del_address", "sw_interface_set_flags"},
+		},
+		{
+			name:        "keep existing gateway BVI",
+			bviSwIfId:   9,
+			expRequests: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []string{}
+			mockVpp := mockVppReplies(t, map[string]api.Message{
+				"create_loopback":              &interfaces.CreateLoopbackReply{SwIfIndex: 9},
+				"sw_interface_tag_add_del":     &interfaces.SwInterfaceTagAddDelReply{},
+				"sw_interface_set_l2_bridge":   &l2.SwInterfaceSetL2BridgeReply{},
+				"sw_interface_add_del_address": &interfaces.SwInterfaceAddDelAddressReply{},
+				"sw_interface_set_flags":       &interfaces.SwInterfaceSetFlagsReply{},
+			}, &requests)
+			ch := openMockCh(t, mockVpp)
+
+			mockVpp.MockReply(&l2.BridgeDomainDetails{BdID: 4, BviSwIfIndex: tc.bviSwIfId})
+			mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+			gateways := []net.IPNet{
+				{IP: net.ParseIP("192.168.1.1"), Mask: net.CIDRMask(24, 32)},
+				{IP: net.ParseIP("fd00::1"), Mask: net.CIDRMask(64, 128)},
+			}
+			require.NoError(t, AddBridgeGateway(ch, 4, gateways), "Unexpected error")
+			assert.Equal(t, tc.expRequests, requests, "Unexpected requests")
+		})
+	}
+}
+
+func TestRemoveBridgeInterface(t *testing.T) {
+	testCases := []struct {
+		name        string
+		swIfs       int
+		bviSwIfId   interface_types.InterfaceIndex
+		bviTag      string
+		expRequests []string
+	}{
+		{
+			name:        "keep bridge with other interfaces",
+			swIfs:       2,
+			bviSwIfId:   9,
+			bviTag:      GetGatewayTag(4),
+			expRequests: []string{"sw_interface_set_l2_bridge"},
+		},
+		{
+			name:        "delete bridge with last interface",
+			bviSwIfId:   types.DefaultSwIfIndex,
+			expRequests: []string{"sw_interface_set_l2_bridge", "bridge_domain_add_del"},
+		},
+		{
+			name:      "delete gateway BVI and bridge with last interface",
+			swIfs:     1,
+			bviSwIfId: 9,
+			bviTag:    GetGatewayTag(4),
+			expRequests: []string{"sw_interface_set_l2_bridge", "sw_interface_set_l2_bridge", "delete_loopback",
+				"bridge_domain_add_del"},
+		},
+		{
+			name:        "keep foreign BVI and bridge with last interface",
+			swIfs:       1,
+			bviSwIfId:   9,
+			bviTag:      "operator-bvi",
+			expRequests: []string{"sw_interface_set_l2_bridge"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []string{}
+			mockVpp := mockVppReplies(t, map[string]api.Message{
+				"sw_interface_set_l2_bridge": &l2.SwInterfaceSetL2BridgeReply{},
+				"delete_loopback":            &interfaces.DeleteLoopbackReply{},
+				"bridge_domain_add_del":      &l2.BridgeDomainAddDelReply{},
+			}, &requests)
+			ch := openMockCh(t, mockVpp)
+
+			// The Bridge Domain is dumped before removing the BVI and again
+			// before deleting the Bridge Domain, without the BVI once deleted
+			mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+				var reply api.Message
+				pingID, _ := mockVpp.GetMsgID((&memclnt.ControlPing{}).GetMessageName(), "")
+				dumpID, _ := mockVpp.GetMsgID((&interfaces.SwInterfaceDump{}).GetMessageName(), (&interfaces.SwInterfaceDump{}).GetCrcString())
+				switch {
+				case request.MsgID == dumpID:
+					reply = &interfaces.SwInterfaceDetails{SwIfIndex: tc.bviSwIfId, Tag: tc.bviTag}
+				case request.MsgName == "bridge_domain_dump":
+					details := &l2.BridgeDomainDetails{BdID: 4, BviSwIfIndex: tc.bviSwIfId}
+					if slices.Contains(requests, "delete_loopback") {
+						details.BviSwIfIndex = types.DefaultSwIfIndex
+					} else {
+						details.SwIfDetails = make([]l2.BridgeDomainSwIf, tc.swIfs)
+					}
+					reply = details
+				case request.MsgID == pingID:
+					reply = &memclnt.ControlPingReply{}
+				default:
+					return nil, 0, false
+				}
+				msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+				data, err := mockVpp.ReplyBytes(request, reply)
+				require.NoError(t, err, "Can't encode reply")
+				return data, msgID, true
+			})
+
+			require.NoError(t, RemoveBridgeInterface(ch, 4, 3), "Unexpected error")
+			assert.Equal(t, tc.expRequests, requests, "Unexpected requests")
+		})
+	}
+}
+
+// Return a mock VPP replying to the named requests, which are recorded.
+func mockVppReplies(t *testing.T, replies map[string]api.Message, requests *[]string) *mock.VppAdapter {
+	mockVpp := mock.NewVppAdapter()
+	mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		reply, ok := replies[request.MsgName]
+		if !ok {
+			return nil, 0, false
+		}
+		*requests = append(*requests, request.MsgName)
+
+		msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+		data, err := mockVpp.ReplyBytes(request, reply)
+		require.NoError(t, err, "Can't encode reply")
+		return data, msgID, true
+	})
+	return mockVpp
+}
+
+// Open a channel to the mock VPP.
+func openMockCh(t *testing.T, mockVpp *mock.VppAdapter) api.Channel {
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return ch
+}
@@ -20,17 +20,39 @@ package vppinfra
 //go:generate go run go.fd.io/govpp/cmd/binapi-generator --output-dir=../../bin_api
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl"
+	interfaces "github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memif"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/vhost_user"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/vpe"
+
 	"go.fd.io/govpp"
 	"go.fd.io/govpp/api"
 	"go.fd.io/govpp/core"
 )
 
 // Constants
-const debugInfra = false
+const (
+	debugInfra = false
+
+	// Connect attempts to VPP, waiting connectBackoff before the second
+	// attempt and doubling the wait after each further failure. This rides
+	// out a VPP restart of a few seconds.
+	connectAttempts = 5
+
+	// Time to wait for each reply before the request fails, so a VPP that
+	// stops answering doesn't hang the CNI request.
+	replyTimeout = 5 * time.Second
+)
 
 // Types
 type ConnectionData struct {
@@ -40,12 +62,20 @@ type ConnectionData struct {
 	closeFlag      bool
 }
 
+// Connect to VPP and the first retry backoff, replaced in unit tests
+var (
+	connect        = govpp.Connect
+	connectBackoff = 250 * time.Millisecond
+)
+
 //
 // API Functions
 //
 
 // Open a Connection and Channel to VPP to allow communication to VPP.
-func VppOpenCh() (ConnectionData, error) {
+// An empty apiSocket connects to the default VPP API socket. Fails if the
+// running VPP doesn't provide the API messages the CNI was built with.
+func VppOpenCh(apiSocket string) (ConnectionData, error) {
 
 	var vppCh ConnectionData
 	var err error
@@ -55,7 +85,7 @@ func VppOpenCh() (ConnectionData, error) {
 	core.SetLogger(&logrus.Logger{Level: logrus.ErrorLevel})
 
 	// Connect to VPP
-	vppCh.conn, err = govpp.Connect("")
+	vppCh.conn, err = connectWithRetry(apiSocket)
 	if err != nil {
 		if debugInfra {
 			fmt.Println("Error:", err)
@@ -74,6 +104,17 @@ func VppOpenCh() (ConnectionData, error) {
 		return vppCh, err
 	}
 	vppCh.closeFlag = true
+	vppCh.Ch.SetReplyTimeout(replyTimeout)
+
+	// Verify the generated messages match the running VPP
+	err = checkCompatibility(vppCh.Ch)
+	if err != nil {
+		VppCloseCh(vppCh)
+		if debugInfra {
+			fmt.Println("Error:", err)
+		}
+		return vppCh, err
+	}
 
 	return vppCh, err
 }
@@ -91,3 +132,170 @@ func VppCloseCh(vppCh ConnectionData) {
 		vppCh.disconnectFlag = false
 	}
 }
+
+// Retrieve the version of the VPP instance the Channel is connected to.
+func VppVersion(ch api.Channel) (string, error) {
+
+	req := &vpe.ShowVersion{}
+	reply := &vpe.ShowVersionReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+	if err != nil {
+		if debugInfra {
+			fmt.Println("Error:", err)
+		}
+		return "", err
+	}
+
+	return reply.Version, nil
+}
+
+// Replace the function connecting to VPP and the first retry backoff.
+// Unit tests without VPP use it to fail fast or to connect to a mock VPP.
+// Returns a function restoring the previous ones.
+func SetConnect(connectFn func(apiSocket string) (*core.Connection, error), backoff time.Duration) func() {
+	prevConnect, prevBackoff := connect, connectBackoff
+	connect, connectBackoff = connectFn, backoff
+
+	return func() {
+		connect, connectBackoff = prevConnect, prevBackoff
+	}
+}
+
+//
+// Local Functions
+//
+
+// Connect to VPP, retrying with an increasing backoff while VPP is not
+// reachable.
+func connectWithRetry(apiSocket string) (*core.Connection, error) {
+	var conn *core.Connection
+	var err error
+
+	backoff := connectBackoff
+	for attempt := 1; attempt <= connectAttempts; attempt++ {
+		conn, err = connect(apiSocket)
+		if err == nil {
+			return conn, nil
+		}
+		if debugInfra {
+			fmt.Printf("Connect attempt %d to VPP failed: %v\n", attempt, err)
+		}
+		if attempt < connectAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return nil, fmt.Errorf("ERROR: Failed to connect to VPP after %d attempts: %v", connectAttempts, err)
+}
+
+// API messages sent by the CNI and their replies. VPP must know each of
+// them with the CRC of the generated bin_api. Messages of the same modules
+// the CNI doesn't use are left out, VPP may change them freely.
+func vppMessages() []api.Message {
+	return []api.Message{
+		// vpe
+		(*vpe.ShowVersion)(nil),
+		(*vpe.ShowVersionReply)(nil),
+
+		// interface
+		(*interfaces.CreateLoopback)(nil),
+		(*interfaces.CreateLoopbackReply)(nil),
+		(*interfaces.CreateVlanSubif)(nil),
+		(*interfaces.CreateVlanSubifReply)(nil),
+		(*interfaces.DeleteLoopback)(nil),
+		(*interfaces.DeleteLoopbackReply)(nil),
+		(*interfaces.DeleteSubif)(nil),
+		(*interfaces.DeleteSubifReply)(nil),
+		(*interfaces.SwInterfaceAddDelAddress)(nil),
+		(*interfaces.SwInterfaceAddDelAddressReply)(nil),
+		(*interfaces.SwInterfaceDump)(nil),
+		(*interfaces.SwInterfaceDetails)(nil),
+		(*interfaces.SwInterfaceSetFlags)(nil),
+		(*interfaces.SwInterfaceSetFlagsReply)(nil),
+		(*interfaces.SwInterfaceSetTable)(nil),
+		(*interfaces.SwInterfaceSetTableReply)(nil),
+		(*interfaces.SwInterfaceTagAddDel)(nil),
+		(*interfaces.SwInterfaceTagAddDelReply)(nil),
+
+		// ip
+		(*ip.IPRouteAddDel)(nil),
+		(*ip.IPRouteAddDelReply)(nil),
+		(*ip.IPTableAddDel)(nil),
+		(*ip.IPTableAddDelReply)(nil),
+
+		// l2
+		(*l2.BdIPMacAddDel)(nil),
+		(*l2.BdIPMacAddDelReply)(nil),
+		(*l2.BridgeDomainAddDel)(nil),
+		(*l2.BridgeDomainAddDelReply)(nil),
+		(*l2.BridgeDomainDump)(nil),
+		(*l2.BridgeDomainDetails)(nil),
+		(*l2.L2InterfaceVlanTagRewrite)(nil),
+		(*l2.L2InterfaceVlanTagRewriteReply)(nil),
+		(*l2.L2XconnectDump)(nil),
+		(*l2.L2XconnectDetails)(nil),
+		(*l2.SwInterfaceSetL2Bridge)(nil),
+		(*l2.SwInterfaceSetL2BridgeReply)(nil),
+		(*l2.SwInterfaceSetL2Xconnect)(nil),
+		(*l2.SwInterfaceSetL2XconnectReply)(nil),
+
+		// memif
+		(*memif.MemifCreate)(nil),
+		(*memif.MemifCreateReply)(nil),
+		(*memif.MemifDelete)(nil),
+		(*memif.MemifDeleteReply)(nil),
+		(*memif.MemifDump)(nil),
+		(*memif.MemifDetails)(nil),
+		(*memif.MemifSocketFilenameAddDel)(nil),
+		(*memif.MemifSocketFilenameAddDelReply)(nil),
+		(*memif.MemifSocketFilenameDump)(nil),
+		(*memif.MemifSocketFilenameDetails)(nil),
+
+		// vhost_user
+		(*vhost_user.CreateVhostUserIf)(nil),
+		(*vhost_user.CreateVhostUserIfReply)(nil),
+		(*vhost_user.DeleteVhostUserIf)(nil),
+		(*vhost_user.DeleteVhostUserIfReply)(nil),
+		(*vhost_user.SwInterfaceVhostUserDump)(nil),
+		(*vhost_user.SwInterfaceVhostUserDetails)(nil),
+	}
+}
+
+// ACL plugin messages sent by the CNI and their replies.
+func vppAclMessages() []api.Message {
+	return []api.Message{
+		(*acl.ACLAddReplace)(nil),
+		(*acl.ACLAddReplaceReply)(nil),
+		(*acl.ACLDel)(nil),
+		(*acl.ACLDelReply)(nil),
+		(*acl.ACLInterfaceSetACLList)(nil),
+		(*acl.ACLInterfaceSetACLListReply)(nil),
+	}
+}
+
+// Check that VPP provides the API messages used by the CNI.
+func checkCompatibility(ch api.Channel) error {
+	return compatibilityError(ch.CheckCompatiblity(vppMessages()...))
+}
+
+// Check that VPP provides the ACL plugin API. The plugin is only needed by
+// networks with an ACL, so it is checked on use.
+func CheckAclCompatibility(ch api.Channel) error {
+	return compatibilityError(ch.CheckCompatiblity(vppAclMessages()...))
+}
+
+// Turn a compatibility error into one naming the incompatible messages.
+func compatibilityError(err error) error {
+	var compErr *api.CompatibilityError
+
+	if err == nil {
+		return nil
+	}
+	if errors.As(err, &compErr) {
+		return fmt.Errorf("ERROR: VPP API incompatible, rebuild the CNI against the running VPP. Incompatible messages: %s",
+			strings.Join(compErr.IncompatibleMessages, ", "))
+	}
+	return fmt.Errorf("ERROR: Failed to check VPP API compatibility: %v", err)
+}
@@ -0,0 +1,146 @@
+package vppinfra
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/core"
+)
+
+func TestConnectWithRetry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		failures    int
+		expAttempts int
+		expErr      string
+	}{
+		{
+			name:        "connect at first attempt",
+			expAttempts: 1,
+		},
+		{
+			name:        "connect after VPP restart",
+			failures:    3,
+			expAttempts: 4,
+		},
+		{
+			name:        "fail after last attempt",
+			failures:    connectAttempts,
+			expAttempts: connectAttempts,
+			expErr:      "ERROR: Failed to connect to VPP after 5 attempts: connection refused",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(c func(string) (*core.Connection, error), b time.Duration) {
+				connect, connectBackoff = c, b
+			}(connect, connectBackoff)
+			connectBackoff = time.Millisecond
+
+			attempts := 0
+			connect = func(apiSocket string) (*core.Connection, error) {
+				assert.Equal(t, "/run/vpp1/api.sock", apiSocket, "Unexpected API socket")
+				attempts++
+				if attempts <= tc.failures {
+					return nil, errors.New("connection refused")
+				}
+				return core.Connect(mock.NewVppAdapter())
+			}
+
+			conn, err := connectWithRetry("/run/vpp1/api.sock")
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+				require.NotNil(t, conn, "Connection expected")
+				conn.Disconnect()
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+			assert.Equal(t, tc.expAttempts, attempts, "Unexpected number of attempts")
+		})
+	}
+}
+
+func TestCompatibilityError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		expErr string
+	}{
+		{
+			name: "compatible",
+		},
+		{
+			name: "name incompatible messages",
+			err: &api.CompatibilityError{
+				CompatibleMessages:   []string{"show_version_2a8f7e22"},
+				IncompatibleMessages: []string{"memif_create_v2_8c7de5f7", "ip_route_add_del_b8ecfe0d"},
+			},
+			expErr: "ERROR: VPP API incompatible, rebuild the CNI against the running VPP. " +
+				"Incompatible messages: memif_create_v2_8c7de5f7, ip_route_add_del_b8ecfe0d",
+		},
+		{
+			name:   "fail to check",
+			err:    errors.New("no connection"),
+			expErr: "ERROR: Failed to check VPP API compatibility: no connection",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := compatibilityError(tc.err)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	t.Run("accept messages known to VPP", func(t *testing.T) {
+		conn, err := core.Connect(mock.NewVppAdapter())
+		require.NoError(t, err, "Can't connect to mock VPP")
+		defer conn.Disconnect()
+		ch, err := conn.NewAPIChannel()
+		require.NoError(t, err, "Can't open channel to mock VPP")
+		defer ch.Close()
+
+		assert.NoError(t, checkCompatibility(ch), "Unexpected error")
+	})
+}
+
+func TestVppMessages(t *testing.T) {
+	t.Run("only list messages of the CNI", func(t *testing.T) {
+		names := map[string]bool{}
+		for _, msg := range append(vppMessages(), vppAclMessages()...) {
+			names[msg.GetMessageName()] = true
+		}
+		assert.True(t, names["memif_create"], "Message sent by the CNI missing")
+		assert.True(t, names["acl_interface_set_acl_list"], "Message sent by the CNI missing")
+		assert.False(t, names["memif_create_v2"], "Message not sent by the CNI checked")
+		assert.False(t, names["acl_dump"], "Message not sent by the CNI checked")
+	})
+}
+
+func TestSetConnect(t *testing.T) {
+	t.Run("replace and restore connect", func(t *testing.T) {
+		attempts := 0
+		restore := SetConnect(func(apiSocket string) (*core.Connection, error) {
+			attempts++
+			return nil, errors.New("connection refused")
+		}, 0)
+
+		_, err := connectWithRetry("")
+		restore()
+		assert.Error(t, err, "Error was expected")
+		assert.Equal(t, connectAttempts, attempts, "Replaced connect not used")
+		assert.Equal(t, 250*time.Millisecond, connectBackoff, "Backoff not restored")
+	})
+}
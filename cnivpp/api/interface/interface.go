@@ -20,6 +20,7 @@ package vppinterface
 //go:generate go run go.fd.io/govpp/cmd/binapi-generator --output-dir=../../bin_api
 
 import (
+	"errors"
 	"fmt"
 
 	current "github.com/containernetworking/cni/pkg/types/100"
@@ -59,44 +60,245 @@ func SetState(ch api.Channel, swIfIndex interface_types.InterfaceIndex, isUp int
 	return nil
 }
 
-func AddDelIpAddress(ch api.Channel, swIfIndex interface_types.InterfaceIndex, isAdd bool, ipResult *current.Result) error {
-
+// Attempt to set the tag of an interface. The tag is kept by VPP and can be
+// used to find the interface again, its index may change when VPP restarts.
+func SetTag(ch api.Channel, swIfIndex interface_types.InterfaceIndex, tag string) error {
 	// Populate the Add Structure
-	req := &interfaces.SwInterfaceAddDelAddress{
+	req := &interfaces.SwInterfaceTagAddDel{
+		IsAdd:     true,
 		SwIfIndex: swIfIndex,
-		IsAdd:     isAdd, // 1 = add, 0 = delete
-		DelAll:    false,
+		Tag:       tag,
 	}
-	for _, ip := range ipResult.IPs {
-		var addressWithPrefix ip_types.AddressWithPrefix
 
-		if prefix, _ := ip.Address.Mask.Size(); prefix == 4 {
-			addressWithPrefix = ip_types.AddressWithPrefix{Address: ip_types.AddressFromIP(ip.Address.IP.To4()), Len: 4}
+	reply := &interfaces.SwInterfaceTagAddDelReply{}
 
-		} else if prefix, _ := ip.Address.Mask.Size(); prefix == 16 {
-			addressWithPrefix = ip_types.AddressWithPrefix{Address: ip_types.AddressFromIP(ip.Address.IP.To16()), Len: 16}
-		} else {
-			break
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugInterface {
+			fmt.Println("Error setting interface tag:", err)
 		}
-		fmt.Println(addressWithPrefix)
-		req.Prefix = addressWithPrefix
+		return err
+	}
+
+	return nil
+}
 
-		// Only one address is currently supported.
-		if req.Prefix.Len != 0 {
-			break
+// Find the interface with the given tag.
+// Return: true - Exists  false - otherwise
+//
+//	InterfaceIndex - Index of the interface
+func FindByTag(ch api.Channel, tag string) (found bool, swIfIndex interface_types.InterfaceIndex, err error) {
+
+	// Dump all interfaces, VPP can't filter on the tag
+	req := &interfaces.SwInterfaceDump{
+		SwIfIndex: ^interface_types.InterfaceIndex(0),
+	}
+	reqCtx := ch.SendMultiRequest(req)
+
+	for {
+		reply := &interfaces.SwInterfaceDetails{}
+		stop, replyErr := reqCtx.ReceiveReply(reply)
+		if stop {
+			break // break out of the loop
+		}
+		if replyErr != nil {
+			if debugInterface {
+				fmt.Println("Error:", replyErr)
+			}
+			err = replyErr
+			break // break out of the loop
+		}
+		if !found && reply.Tag == tag {
+			found = true
+			swIfIndex = reply.SwIfIndex
 		}
 	}
 
-	reply := &interfaces.SwInterfaceAddDelAddressReply{}
+	return
+}
+
+// Find the interface with the given name, like an uplink.
+// Return: true - Exists  false - otherwise
+//
+//	InterfaceIndex - Index of the interface
+func FindByName(ch api.Channel, name string) (found bool, swIfIndex interface_types.InterfaceIndex, err error) {
+
+	// The name filter matches substrings, so compare the names again
+	req := &interfaces.SwInterfaceDump{
+		SwIfIndex:       ^interface_types.InterfaceIndex(0),
+		NameFilterValid: true,
+		NameFilter:      name,
+	}
+	reqCtx := ch.SendMultiRequest(req)
+
+	for {
+		reply := &interfaces.SwInterfaceDetails{}
+		stop, replyErr := reqCtx.ReceiveReply(reply)
+		if stop {
+			break // break out of the loop
+		}
+		if replyErr != nil {
+			if debugInterface {
+				fmt.Println("Error:", replyErr)
+			}
+			err = replyErr
+			break // break out of the loop
+		}
+		if !found && reply.InterfaceName == name {
+			found = true
+			swIfIndex = reply.SwIfIndex
+		}
+	}
+
+	return
+}
+
+// Attempt to bind an interface to a FIB table, IPv4 or IPv6. The table must
+// exist, and the interface must not have addresses yet.
+func SetTable(ch api.Channel, swIfIndex interface_types.InterfaceIndex, tableId uint32, isIPv6 bool) error {
+
+	// Populate the Request Structure
+	req := &interfaces.SwInterfaceSetTable{
+		SwIfIndex: swIfIndex,
+		IsIPv6:    isIPv6,
+		VrfID:     tableId,
+	}
+
+	reply := &interfaces.SwInterfaceSetTableReply{}
 
 	err := ch.SendRequest(req).ReceiveReply(reply)
 
 	if err != nil {
 		if debugInterface {
-			fmt.Println("Error:", err)
+			fmt.Println("Error setting interface table:", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Attempt to create a dot1q VLAN sub-interface on an interface. The
+// sub-interface receives the frames tagged with the VLAN Id.
+func CreateVlanSubif(ch api.Channel, swIfIndex interface_types.InterfaceIndex, vlanId uint32) (subIfIndex interface_types.InterfaceIndex, err error) {
+
+	// Populate the Add Structure
+	req := &interfaces.CreateVlanSubif{
+		SwIfIndex: swIfIndex,
+		VlanID:    vlanId,
+	}
+
+	reply := &interfaces.CreateVlanSubifReply{}
+
+	err = ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugInterface {
+			fmt.Println("Error creating VLAN sub-interface:", err)
+		}
+		return
+	} else {
+		subIfIndex = reply.SwIfIndex
+	}
+
+	return
+}
+
+// Attempt to delete a sub-interface.
+func DeleteSubif(ch api.Channel, swIfIndex interface_types.InterfaceIndex) error {
+
+	// Populate the Delete Structure
+	req := &interfaces.DeleteSubif{
+		SwIfIndex: swIfIndex,
+	}
+
+	reply := &interfaces.DeleteSubifReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugInterface {
+			fmt.Println("Error deleting sub-interface:", err)
 		}
 		return err
 	}
 
 	return nil
 }
+
+// Attempt to add or delete all the IP addresses of an IPAM result on an
+// interface, IPv4 and IPv6, each with its prefix length. All addresses are
+// attempted, the returned error lists each address that failed.
+func AddDelIpAddress(ch api.Channel, swIfIndex interface_types.InterfaceIndex, isAdd bool, ipResult *current.Result) error {
+	var errs []error
+
+	if ipResult == nil {
+		return nil
+	}
+
+	for _, ip := range ipResult.IPs {
+		if ip == nil {
+			continue
+		}
+
+		// Populate the Add Structure
+		req := &interfaces.SwInterfaceAddDelAddress{
+			SwIfIndex: swIfIndex,
+			IsAdd:     isAdd, // 1 = add, 0 = delete
+			DelAll:    false,
+			Prefix:    ip_types.NewAddressWithPrefix(ip.Address),
+		}
+
+		reply := &interfaces.SwInterfaceAddDelAddressReply{}
+
+		err := ch.SendRequest(req).ReceiveReply(reply)
+
+		if err != nil {
+			if debugInterface {
+				fmt.Println("Error:", err)
+			}
+			if isAdd {
+				errs = append(errs, fmt.Errorf("ERROR: Failed to add IP address %s: %v", ip.Address.String(), err))
+			} else {
+				errs = append(errs, fmt.Errorf("ERROR: Failed to delete IP address %s: %v", ip.Address.String(), err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Retrieve the state flags of an interface.
+// Return: true - Exists  false - otherwise
+//
+//	IfStatusFlags - Admin and link state of the interface
+func GetState(ch api.Channel, swIfIndex interface_types.InterfaceIndex) (found bool, flags interface_types.IfStatusFlags, err error) {
+
+	// Populate the Message Structure
+	req := &interfaces.SwInterfaceDump{
+		SwIfIndex: swIfIndex,
+	}
+	reqCtx := ch.SendMultiRequest(req)
+
+	for {
+		reply := &interfaces.SwInterfaceDetails{}
+		stop, replyErr := reqCtx.ReceiveReply(reply)
+		if stop {
+			break // break out of the loop
+		}
+		if replyErr != nil {
+			if debugInterface {
+				fmt.Println("Error:", replyErr)
+			}
+			err = replyErr
+			break // break out of the loop
+		}
+		if reply.SwIfIndex == swIfIndex {
+			found = true
+			flags = reply.Flags
+		}
+	}
+
+	return
+}
@@ -0,0 +1,271 @@
+package vppinterface
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	interfaces "github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memclnt"
+)
+
+// Open a channel to a mock VPP, which records the requests decoded with
+// newRequest() and answers each with the reply returned by getReply().
+func openMockCh(t *testing.T, newRequest func() api.Message, getReply func(req api.Message) api.Message, requests *[]api.Message) api.Channel {
+	mockVpp := mock.NewVppAdapter()
+	mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		req := newRequest()
+		if request.MsgName != req.GetMessageName() {
+			return nil, 0, false
+		}
+		require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+		*requests = append(*requests, req)
+
+		reply := getReply(req)
+		msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+		data, err := mockVpp.ReplyBytes(request, reply)
+		require.NoError(t, err, "Can't encode reply")
+		return data, msgID, true
+	})
+
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return ch
+}
+
+func getIPConfig(t *testing.T, address string) *current.IPConfig {
+	ip, ipNet, err := net.ParseCIDR(address)
+	require.NoError(t, err, "Can't parse address")
+	ipNet.IP = ip
+	return &current.IPConfig{Address: *ipNet}
+}
+
+func TestAddDelIpAddress(t *testing.T) {
+	testCases := []struct {
+		name         string
+		isAdd        bool
+		addresses    []string
+		failPrefixes []string
+		expPrefixes  []string
+		expErr       string
+	}{
+		{
+			name:        "add IPv4 address with prefix length",
+			isAdd:       true,
+			addresses:   []string{"192.168.1.10/24"},
+			expPrefixes: []string{"192.168.1.10/24"},
+		},
+		{
+			name:        "add dual-stack addresses",
+			isAdd:       true,
+			addresses:   []string{"192.168.1.10/24", "10.56.0.5/16", "fd00::5/64"},
+			expPrefixes: []string{"192.168.1.10/24", "10.56.0.5/16", "fd00::5/64"},
+		},
+		{
+			name:        "delete dual-stack addresses",
+			addresses:   []string{"192.168.1.10/24", "fd00::5/64"},
+			expPrefixes: []string{"192.168.1.10/24", "fd00::5/64"},
+		},
+		{
+			name:        "no addresses",
+			isAdd:       true,
+			expPrefixes: []string{},
+		},
+		{
+			name:         "report each failed address",
+			isAdd:        true,
+			addresses:    []string{"192.168.1.10/24", "10.56.0.5/16", "fd00::5/64"},
+			failPrefixes: []string{"192.168.1.10/24", "fd00::5/64"},
+			expPrefixes:  []string{"192.168.1.10/24", "10.56.0.5/16", "fd00::5/64"},
+			expErr: "ERROR: Failed to add IP address 192.168.1.10/24: VPPApiError: Unspecified Error (-1)\n" +
+				"ERROR: Failed to add IP address fd00::5/64: VPPApiError: Unspecified Error (-1)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []api.Message{}
+			newRequest := func() api.Message { return &interfaces.SwInterfaceAddDelAddress{} }
+			getReply := func(req api.Message) api.Message {
+				reply := &interfaces.SwInterfaceAddDelAddressReply{}
+				for _, prefix := range tc.failPrefixes {
+					if req.(*interfaces.SwInterfaceAddDelAddress).Prefix.String() == prefix {
+						reply.Retval = -1
+					}
+				}
+				return reply
+			}
+			ch := openMockCh(t, newRequest, getReply, &requests)
+
+			ipResult := &current.Result{}
+			for _, address := range tc.addresses {
+				ipResult.IPs = append(ipResult.IPs, getIPConfig(t, address))
+			}
+
+			err := AddDelIpAddress(ch, 3, tc.isAdd, ipResult)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+
+			prefixes := []string{}
+			for _, msg := range requests {
+				req := msg.(*interfaces.SwInterfaceAddDelAddress)
+				assert.Equal(t, uint32(3), uint32(req.SwIfIndex), "Unexpected interface")
+				assert.Equal(t, tc.isAdd, req.IsAdd, "Unexpected operation")
+				prefixes = append(prefixes, req.Prefix.String())
+			}
+			assert.Equal(t, tc.expPrefixes, prefixes, "Unexpected addresses")
+		})
+	}
+}
+
+func TestSetTable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		isIPv6 bool
+	}{
+		{
+			name: "bind interface to IPv4 table",
+		},
+		{
+			name:   "bind interface to IPv6 table",
+			isIPv6: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []api.Message{}
+			newRequest := func() api.Message { return &interfaces.SwInterfaceSetTable{} }
+			getReply := func(api.Message) api.Message { return &interfaces.SwInterfaceSetTableReply{} }
+			ch := openMockCh(t, newRequest, getReply, &requests)
+
+			require.NoError(t, SetTable(ch, 3, 7, tc.isIPv6), "Unexpected error")
+
+			require.Len(t, requests, 1, "Unexpected number of requests")
+			assert.Equal(t, &interfaces.SwInterfaceSetTable{SwIfIndex: 3, IsIPv6: tc.isIPv6, VrfID: 7}, requests[0], "Unexpected request")
+		})
+	}
+}
+
+func TestSetTag(t *testing.T) {
+	t.Run("tag interface", func(t *testing.T) {
+		requests := []api.Message{}
+		newRequest := func() api.Message { return &interfaces.SwInterfaceTagAddDel{} }
+		getReply := func(api.Message) api.Message { return &interfaces.SwInterfaceTagAddDelReply{} }
+		ch := openMockCh(t, newRequest, getReply, &requests)
+
+		require.NoError(t, SetTag(ch, 3, "usrspcni-0123456789ab-net1"), "Unexpected error")
+
+		require.Len(t, requests, 1, "Unexpected number of requests")
+		assert.Equal(t, &interfaces.SwInterfaceTagAddDel{IsAdd: true, SwIfIndex: 3, Tag: "usrspcni-0123456789ab-net1"}, requests[0], "Unexpected request")
+	})
+}
+
+func TestFindByTag(t *testing.T) {
+	testCases := []struct {
+		name         string
+		tag          string
+		expFound     bool
+		expSwIfIndex interface_types.InterfaceIndex
+	}{
+		{
+			name:         "find interface",
+			tag:          "usrspcni-0123456789ab-net1",
+			expFound:     true,
+			expSwIfIndex: 5,
+		},
+		{
+			name:         "find sub-interface",
+			tag:          "usrspcni-0123456789ab-net1-vlan",
+			expFound:     true,
+			expSwIfIndex: 6,
+		},
+		{
+			name: "interface not found",
+			tag:  "usrspcni-ba9876543210-net1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockVpp := mock.NewVppAdapter()
+			conn, err := core.Connect(mockVpp)
+			require.NoError(t, err, "Can't connect to mock VPP")
+			defer conn.Disconnect()
+			ch, err := conn.NewAPIChannel()
+			require.NoError(t, err, "Can't open channel to mock VPP")
+			defer ch.Close()
+
+			mockVpp.MockReply(
+				&interfaces.SwInterfaceDetails{SwIfIndex: 0, InterfaceName: "local0"},
+				&interfaces.SwInterfaceDetails{SwIfIndex: 5, Tag: "usrspcni-0123456789ab-net1"},
+				&interfaces.SwInterfaceDetails{SwIfIndex: 6, Tag: "usrspcni-0123456789ab-net1-vlan"},
+			)
+			mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+			found, swIfIndex, err := FindByTag(ch, tc.tag)
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expFound, found, "Unexpected result")
+			assert.Equal(t, tc.expSwIfIndex, swIfIndex, "Unexpected interface")
+		})
+	}
+}
+
+func TestFindByName(t *testing.T) {
+	testCases := []struct {
+		name         string
+		ifName       string
+		expFound     bool
+		expSwIfIndex interface_types.InterfaceIndex
+	}{
+		{
+			name:         "find uplink",
+			ifName:       "TenGigabitEthernet5/0/0",
+			expFound:     true,
+			expSwIfIndex: 1,
+		},
+		{
+			name:   "ignore names only containing the name",
+			ifName: "TenGigabitEthernet5/0/0.10",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockVpp := mock.NewVppAdapter()
+			conn, err := core.Connect(mockVpp)
+			require.NoError(t, err, "Can't connect to mock VPP")
+			defer conn.Disconnect()
+			ch, err := conn.NewAPIChannel()
+			require.NoError(t, err, "Can't open channel to mock VPP")
+			defer ch.Close()
+
+			mockVpp.MockReply(
+				&interfaces.SwInterfaceDetails{SwIfIndex: 1, InterfaceName: "TenGigabitEthernet5/0/0"},
+				&interfaces.SwInterfaceDetails{SwIfIndex: 2, InterfaceName: "TenGigabitEthernet5/0/0.100"},
+			)
+			mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+			found, swIfIndex, err := FindByName(ch, tc.ifName)
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expFound, found, "Unexpected result")
+			assert.Equal(t, tc.expSwIfIndex, swIfIndex, "Unexpected interface")
+		})
+	}
+}
@@ -53,6 +53,23 @@ const (
 	ModePuntInject MemifMode = 2
 )
 
+// Default interface settings
+const (
+	defaultQueues     = 1
+	defaultRingSize   = 1024
+	defaultBufferSize = 2048
+)
+
+// Optional memif interface settings. Zero values use the defaults.
+type MemifOptions struct {
+	RxQueues   uint8
+	TxQueues   uint8
+	RingSize   uint32
+	BufferSize uint16
+	Id         uint32
+	Secret     string
+}
+
 // Dump Strings
 var modeStr = [...]string{"eth", "ip ", "pnt"}
 var roleStr = [...]string{"master", "slave "}
@@ -67,21 +84,34 @@ var roleStr = [...]string{"master", "slave "}
 //	ch api.Channel
 //	socketId uint32
 //	role MemifRole - RoleMaster or RoleSlave
-func CreateMemifInterface(ch api.Channel, socketId uint32, role memif.MemifRole, mode memif.MemifMode) (swIfIndex interface_types.InterfaceIndex, err error) {
+//	options MemifOptions - Queues, ring and buffer size, Id and secret
+func CreateMemifInterface(ch api.Channel, socketId uint32, role memif.MemifRole, mode memif.MemifMode, options MemifOptions) (swIfIndex interface_types.InterfaceIndex, err error) {
 
 	// Populate the Add Structure
 	req := &memif.MemifCreate{
-		Role:     role,
-		Mode:     mode,
-		RxQueues: 1,
-		TxQueues: 1,
-		ID:       0,
-		SocketID: socketId,
-		//Secret: "",
-		RingSize:   1024,
-		BufferSize: 2048,
+		Role:       role,
+		Mode:       mode,
+		RxQueues:   options.RxQueues,
+		TxQueues:   options.TxQueues,
+		ID:         options.Id,
+		SocketID:   socketId,
+		Secret:     options.Secret,
+		RingSize:   options.RingSize,
+		BufferSize: options.BufferSize,
 		//HwAddr: "",
 	}
+	if req.RxQueues == 0 {
+		req.RxQueues = defaultQueues
+	}
+	if req.TxQueues == 0 {
+		req.TxQueues = defaultQueues
+	}
+	if req.RingSize == 0 {
+		req.RingSize = defaultRingSize
+	}
+	if req.BufferSize == 0 {
+		req.BufferSize = defaultBufferSize
+	}
 
 	reply := &memif.MemifCreateReply{}
 
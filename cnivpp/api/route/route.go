@@ -0,0 +1,111 @@
+// Copyright (c) 2017 Cisco and/or its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary simple-client is an example VPP management application that exercises the
+// govpp API on real-world use-cases.
+package vpproute
+
+// Generates Go bindings for all VPP APIs located in the json directory.
+//go:generate go run go.fd.io/govpp/cmd/binapi-generator --output-dir=../../bin_api
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/fib_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip_types"
+	"go.fd.io/govpp/api"
+)
+
+// Constants
+const debugRoute = false
+
+//
+// API Functions
+//
+
+// Attempt to add or delete a route in a FIB table. The route leaves through
+// the interface, to the next hop gw if set, otherwise dst is attached to the
+// interface. Only the path through the interface is added or deleted, so
+// pods sharing a prefix, like a default route, each keep their own path.
+func AddDelRoute(ch api.Channel, tableId uint32, swIfIndex interface_types.InterfaceIndex, isAdd bool, dst net.IPNet, gw net.IP) error {
+
+	path := fib_types.FibPath{
+		SwIfIndex: uint32(swIfIndex),
+		Weight:    1,
+		Type:      fib_types.FIB_API_PATH_TYPE_NORMAL,
+		Proto:     fib_types.FIB_API_PATH_NH_PROTO_IP4,
+	}
+	if dst.IP.To4() == nil {
+		path.Proto = fib_types.FIB_API_PATH_NH_PROTO_IP6
+	}
+	if gw != nil {
+		path.Nh.Address = ip_types.NewAddress(gw).Un
+	}
+
+	// Populate the Request Structure
+	req := &ip.IPRouteAddDel{
+		IsAdd:       isAdd,
+		IsMultipath: true,
+		Route: ip.IPRoute{
+			TableID: tableId,
+			Prefix:  ip_types.NewPrefix(dst),
+			NPaths:  1,
+			Paths:   []fib_types.FibPath{path},
+		},
+	}
+
+	reply := &ip.IPRouteAddDelReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugRoute {
+			fmt.Println("Error:", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Attempt to create or delete a FIB table, IPv4 or IPv6. Creating a table
+// that exists is not an error. VPP only deletes a table once no interface
+// is bound to it.
+func AddDelTable(ch api.Channel, tableId uint32, isIPv6 bool, isAdd bool) error {
+
+	// Populate the Request Structure
+	req := &ip.IPTableAddDel{
+		IsAdd: isAdd,
+		Table: ip.IPTable{
+			TableID: tableId,
+			IsIP6:   isIPv6,
+		},
+	}
+
+	reply := &ip.IPTableAddDelReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugRoute {
+			fmt.Println("Error:", err)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package vpproute
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/fib_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip"
+)
+
+// Open a channel to a mock VPP, which records the requests decoded with
+// newRequest() and answers each with the reply returned by getReply().
+func openMockCh(t *testing.T, newRequest func() api.Message, getReply func(req api.Message) api.Message, requests *[]api.Message) api.Channel {
+	mockVpp := mock.NewVppAdapter()
+	mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		req := newRequest()
+		if request.MsgName != req.GetMessageName() {
+			return nil, 0, false
+		}
+		require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+		*requests = append(*requests, req)
+
+		reply := getReply(req)
+		msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+		data, err := mockVpp.ReplyBytes(request, reply)
+		require.NoError(t, err, "Can't encode reply")
+		return data, msgID, true
+	})
+
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return ch
+}
+
+func TestAddDelRoute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		isAdd    bool
+		dst      string
+		gw       string
+		retval   int32
+		expProto fib_types.FibPathNhProto
+		expNh    string
+		expErr   string
+	}{
+		{
+			name:     "add IPv4 route via gateway",
+			isAdd:    true,
+			dst:      "0.0.0.0/0",
+			gw:       "10.56.217.1",
+			expProto: fib_types.FIB_API_PATH_NH_PROTO_IP4,
+			expNh:    "10.56.217.1",
+		},
+		{
+			name:     "add IPv4 host route",
+			isAdd:    true,
+			dst:      "10.56.217.131/32",
+			gw:       "10.56.217.131",
+			expProto: fib_types.FIB_API_PATH_NH_PROTO_IP4,
+			expNh:    "10.56.217.131",
+		},
+		{
+			name:     "add attached IPv6 route",
+			isAdd:    true,
+			dst:      "fd00:1::/64",
+			expProto: fib_types.FIB_API_PATH_NH_PROTO_IP6,
+		},
+		{
+			name:     "delete IPv6 route via gateway",
+			dst:      "::/0",
+			gw:       "fd00::1",
+			expProto: fib_types.FIB_API_PATH_NH_PROTO_IP6,
+			expNh:    "fd00::1",
+		},
+		{
+			name:     "fail to add route",
+			isAdd:    true,
+			dst:      "0.0.0.0/0",
+			gw:       "10.56.217.1",
+			retval:   -1,
+			expProto: fib_types.FIB_API_PATH_NH_PROTO_IP4,
+			expNh:    "10.56.217.1",
+			expErr:   "VPPApiError: Unspecified Error (-1)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []api.Message{}
+			newRequest := func() api.Message { return &ip.IPRouteAddDel{} }
+			getReply := func(api.Message) api.Message { return &ip.IPRouteAddDelReply{Retval: tc.retval} }
+			ch := openMockCh(t, newRequest, getReply, &requests)
+
+			_, dst, err := net.ParseCIDR(tc.dst)
+			require.NoError(t, err, "Can't parse destination")
+
+			err = AddDelRoute(ch, 7, 3, tc.isAdd, *dst, net.ParseIP(tc.gw))
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+
+			require.Len(t, requests, 1, "Unexpected number of requests")
+			req := requests[0].(*ip.IPRouteAddDel)
+			assert.Equal(t, tc.isAdd, req.IsAdd, "Unexpected operation")
+			assert.True(t, req.IsMultipath, "Route not added or deleted as one path")
+			assert.Equal(t, uint32(7), req.Route.TableID, "Unexpected FIB table")
+			assert.Equal(t, tc.dst, req.Route.Prefix.String(), "Unexpected destination")
+			require.Len(t, req.Route.Paths, 1, "Unexpected number of paths")
+			assert.Equal(t, uint32(3), req.Route.Paths[0].SwIfIndex, "Unexpected interface")
+			assert.Equal(t, tc.expProto, req.Route.Paths[0].Proto, "Unexpected protocol")
+			if tc.expNh != "" {
+				nh := req.Route.Paths[0].Nh.Address
+				if tc.expProto == fib_types.FIB_API_PATH_NH_PROTO_IP4 {
+					assert.Equal(t, tc.expNh, nh.GetIP4().String(), "Unexpected next hop")
+				} else {
+					assert.Equal(t, tc.expNh, nh.GetIP6().String(), "Unexpected next hop")
+				}
+			}
+		})
+	}
+}
+
+func TestAddDelSharedRoute(t *testing.T) {
+	t.Run("keep default route of pod when other pod deleted", func(t *testing.T) {
+		// FIB of the mock VPP, the interfaces of the paths of each prefix.
+		// Like VPP, a route not added as one path replaces all the paths of
+		// the prefix, and deleting it removes the prefix.
+		fib := map[string][]uint32{}
+		requests := []api.Message{}
+		newRequest := func() api.Message { return &ip.IPRouteAddDel{} }
+		getReply := func(msg api.Message) api.Message {
+			req := msg.(*ip.IPRouteAddDel)
+			prefix := req.Route.Prefix.String()
+			swIfIndex := req.Route.Paths[0].SwIfIndex
+			switch {
+			case req.IsAdd && req.IsMultipath:
+				fib[prefix] = append(fib[prefix], swIfIndex)
+			case req.IsAdd:
+				fib[prefix] = []uint32{swIfIndex}
+			case req.IsMultipath:
+				var paths []uint32
+				for _, path := range fib[prefix] {
+					if path != swIfIndex {
+						paths = append(paths, path)
+					}
+				}
+				fib[prefix] = paths
+			default:
+				fib[prefix] = nil
+			}
+			if len(fib[prefix]) == 0 {
+				delete(fib, prefix)
+			}
+			return &ip.IPRouteAddDelReply{}
+		}
+		ch := openMockCh(t, newRequest, getReply, &requests)
+
+		_, dst, err := net.ParseCIDR("0.0.0.0/0")
+		require.NoError(t, err, "Can't parse destination")
+		gw := net.ParseIP("10.56.217.1")
+
+		require.NoError(t, AddDelRoute(ch, 0, 3, true, *dst, gw), "Unexpected error")
+		require.NoError(t, AddDelRoute(ch, 0, 4, true, *dst, gw), "Unexpected error")
+		assert.Equal(t, map[string][]uint32{"0.0.0.0/0": {3, 4}}, fib, "Unexpected paths")
+
+		require.NoError(t, AddDelRoute(ch, 0, 3, false, *dst, gw), "Unexpected error")
+		assert.Equal(t, map[string][]uint32{"0.0.0.0/0": {4}}, fib, "Route of other pod removed")
+
+		require.NoError(t, AddDelRoute(ch, 0, 4, false, *dst, gw), "Unexpected error")
+		assert.Empty(t, fib, "Route left behind")
+	})
+}
+
+func TestAddDelTable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		isIPv6 bool
+		isAdd  bool
+	}{
+		{
+			name:  "add IPv4 table",
+			isAdd: true,
+		},
+		{
+			name:   "add IPv6 table",
+			isIPv6: true,
+			isAdd:  true,
+		},
+		{
+			name:   "delete IPv6 table",
+			isIPv6: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []api.Message{}
+			newRequest := func() api.Message { return &ip.IPTableAddDel{} }
+			getReply := func(api.Message) api.Message { return &ip.IPTableAddDelReply{} }
+			ch := openMockCh(t, newRequest, getReply, &requests)
+
+			require.NoError(t, AddDelTable(ch, 7, tc.isIPv6, tc.isAdd), "Unexpected error")
+
+			require.Len(t, requests, 1, "Unexpected number of requests")
+			req := requests[0].(*ip.IPTableAddDel)
+			assert.Equal(t, tc.isAdd, req.IsAdd, "Unexpected operation")
+			assert.Equal(t, ip.IPTable{TableID: 7, IsIP6: tc.isIPv6}, req.Table, "Unexpected table")
+		})
+	}
+}
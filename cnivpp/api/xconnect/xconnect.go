@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Cisco and/or its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary simple-client is an example VPP management application that exercises the
+// govpp API on real-world use-cases.
+package vppxconnect
+
+// Generates Go bindings for all VPP APIs located in the json directory.
+//go:generate go run go.fd.io/govpp/cmd/binapi-generator --output-dir=../../bin_api
+
+import (
+	"fmt"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
+	"go.fd.io/govpp/api"
+)
+
+// Constants
+const debugXconnect = false
+
+//
+// API Functions
+//
+
+// Attempt to cross-connect the frames received on rxSwIfIndex to txSwIfIndex,
+// one direction only. Disabling puts rxSwIfIndex back in L3 mode.
+func SetXconnect(ch api.Channel, rxSwIfIndex interface_types.InterfaceIndex, txSwIfIndex interface_types.InterfaceIndex, enable bool) error {
+
+	// Populate the Request Structure
+	req := &l2.SwInterfaceSetL2Xconnect{
+		RxSwIfIndex: rxSwIfIndex,
+		TxSwIfIndex: txSwIfIndex,
+		Enable:      enable,
+	}
+
+	reply := &l2.SwInterfaceSetL2XconnectReply{}
+
+	err := ch.SendRequest(req).ReceiveReply(reply)
+
+	if err != nil {
+		if debugXconnect {
+			fmt.Println("Error setting L2 xconnect:", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Retrieve all the L2 cross-connects, mapping each receive interface to
+// its transmit interface.
+func DumpXconnects(ch api.Channel) (map[interface_types.InterfaceIndex]interface_types.InterfaceIndex, error) {
+	xconnects := make(map[interface_types.InterfaceIndex]interface_types.InterfaceIndex)
+
+	reqCtx := ch.SendMultiRequest(&l2.L2XconnectDump{})
+
+	for {
+		reply := &l2.L2XconnectDetails{}
+		stop, err := reqCtx.ReceiveReply(reply)
+		if stop {
+			break // break out of the loop
+		}
+		if err != nil {
+			if debugXconnect {
+				fmt.Println("Error:", err)
+			}
+			return xconnects, err
+		}
+		xconnects[reply.RxSwIfIndex] = reply.TxSwIfIndex
+	}
+
+	return xconnects, nil
+}
@@ -0,0 +1,103 @@
+package vppxconnect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memclnt"
+)
+
+func TestSetXconnect(t *testing.T) {
+	testCases := []struct {
+		name   string
+		enable bool
+		retval int32
+		expErr string
+	}{
+		{
+			name:   "enable xconnect",
+			enable: true,
+		},
+		{
+			name: "disable xconnect",
+		},
+		{
+			name:   "fail to enable xconnect",
+			enable: true,
+			retval: -1,
+			expErr: "VPPApiError: Unspecified Error (-1)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []*l2.SwInterfaceSetL2Xconnect{}
+			mockVpp := mock.NewVppAdapter()
+			mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+				req := &l2.SwInterfaceSetL2Xconnect{}
+				if request.MsgName != req.GetMessageName() {
+					return nil, 0, false
+				}
+				require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+				requests = append(requests, req)
+
+				reply := &l2.SwInterfaceSetL2XconnectReply{Retval: tc.retval}
+				msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+				data, err := mockVpp.ReplyBytes(request, reply)
+				require.NoError(t, err, "Can't encode reply")
+				return data, msgID, true
+			})
+			ch := openMockCh(t, mockVpp)
+
+			err := SetXconnect(ch, 3, 5, tc.enable)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+
+			require.Len(t, requests, 1, "Unexpected number of requests")
+			assert.Equal(t, &l2.SwInterfaceSetL2Xconnect{RxSwIfIndex: 3, TxSwIfIndex: 5, Enable: tc.enable}, requests[0], "Unexpected request")
+		})
+	}
+}
+
+func TestDumpXconnects(t *testing.T) {
+	t.Run("dump xconnects", func(t *testing.T) {
+		mockVpp := mock.NewVppAdapter()
+		ch := openMockCh(t, mockVpp)
+
+		mockVpp.MockReply(
+			&l2.L2XconnectDetails{RxSwIfIndex: 3, TxSwIfIndex: 5},
+			&l2.L2XconnectDetails{RxSwIfIndex: 5, TxSwIfIndex: 3},
+		)
+		mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+		xconnects, err := DumpXconnects(ch)
+		require.NoError(t, err, "Unexpected error")
+		assert.Equal(t, map[interface_types.InterfaceIndex]interface_types.InterfaceIndex{3: 5, 5: 3}, xconnects, "Unexpected xconnects")
+	})
+}
+
+// Open a channel to the mock VPP.
+func openMockCh(t *testing.T, mockVpp *mock.VppAdapter) api.Channel {
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return ch
+}
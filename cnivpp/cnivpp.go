@@ -26,38 +26,80 @@
 package cnivpp
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 
+	vppacl "github.com/intel/userspace-cni-network-plugin/cnivpp/api/acl"
 	vppbridge "github.com/intel/userspace-cni-network-plugin/cnivpp/api/bridge"
 	vppinfra "github.com/intel/userspace-cni-network-plugin/cnivpp/api/infra"
 	vppinterface "github.com/intel/userspace-cni-network-plugin/cnivpp/api/interface"
 	vppmemif "github.com/intel/userspace-cni-network-plugin/cnivpp/api/memif"
+	vpproute "github.com/intel/userspace-cni-network-plugin/cnivpp/api/route"
+	vppvhostuser "github.com/intel/userspace-cni-network-plugin/cnivpp/api/vhostuser"
+	vppxconnect "github.com/intel/userspace-cni-network-plugin/cnivpp/api/xconnect"
 	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
 	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memif"
 	"github.com/intel/userspace-cni-network-plugin/logging"
+	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
 	"github.com/intel/userspace-cni-network-plugin/pkg/configdata"
+	"github.com/intel/userspace-cni-network-plugin/pkg/ipresult"
+	"github.com/intel/userspace-cni-network-plugin/pkg/k8sclient"
+	"github.com/intel/userspace-cni-network-plugin/pkg/shareddir"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/intel/userspace-cni-network-plugin/usrspcni"
 )
 
 // Constants
 const (
 	dbgBridge    = false
 	dbgInterface = false
+
+	// Name of the engine in the HostConf, also saved with each attachment
+	engineName = "vpp"
+
+	// Highest usable 802.1Q VLAN Id
+	maxVlanId = 4094
+
+	// Highest Bridge Domain MAC age in minutes in VPP
+	maxMacAge = 255
+
+	// Limits of the memif interface settings in VPP
+	maxMemifQueues     = 255
+	maxMemifRingSize   = 1 << 14
+	maxMemifBufferSize = 65535
+	maxMemifSecretLen  = 24
+
+	// IP protocol numbers matched by name in ACL rules
+	protoIcmp   = 1
+	protoTcp    = 6
+	protoUdp    = 17
+	protoIcmpv6 = 58
 )
 
 // Types
 type CniVpp struct {
 }
 
+func init() {
+	usrspcni.Register(engineName, CniVpp{})
+}
+
 // API Functions
 func (cniVpp CniVpp) AddOnHost(conf *types.NetConf,
 	args *skel.CmdArgs,
@@ -70,32 +112,100 @@ func (cniVpp CniVpp) AddOnHost(conf *types.NetConf,
 
 	logging.Infof("VPP AddOnHost: ENTER - Container %s Iface %s", args.ContainerID[:12], args.IfName)
 
+	//
+	// Validate the Local Network before touching VPP, so a bad NetType
+	// leaves nothing behind to clean up.
+	//
+	var bridgeName string
+	var bridgeDomain uint32
+
+	if conf.HostConf.NetType == "bridge" {
+		bridgeName = getBridgeName(conf)
+	} else if conf.HostConf.NetType == "" {
+		return fmt.Errorf("ERROR: NetType must be provided")
+	} else if conf.HostConf.NetType == "xconnect" {
+		if err = validateXconnectConf(conf.HostConf.XconnectConf); err != nil {
+			return err
+		}
+		// Only ethernet frames can be cross-connected
+		if conf.HostConf.IfType == "memif" && conf.HostConf.MemifConf.Mode != "" && conf.HostConf.MemifConf.Mode != "ethernet" {
+			return fmt.Errorf("ERROR: Invalid MEMIF Mode for xconnect:" + conf.HostConf.MemifConf.Mode)
+		}
+	} else if conf.HostConf.NetType != "interface" {
+		err = errors.New("ERROR: Unknown HostConf.NetType:" + conf.HostConf.NetType)
+		logging.Debugf("AddOnHost(vpp): %v", err)
+		return err
+	}
+
+	if conf.HostConf.BridgeConf.VlanId < 0 || conf.HostConf.BridgeConf.VlanId > maxVlanId {
+		return fmt.Errorf("ERROR: Invalid BridgeConf.VlanId:%d", conf.HostConf.BridgeConf.VlanId)
+	}
+	if len(conf.HostConf.BridgeConf.Trunks) != 0 {
+		return fmt.Errorf("ERROR: BridgeConf.Trunks not supported by VPP")
+	}
+	if conf.HostConf.BridgeConf.MacAge < 0 || conf.HostConf.BridgeConf.MacAge > maxMacAge {
+		return fmt.Errorf("ERROR: Invalid BridgeConf.MacAge:%d", conf.HostConf.BridgeConf.MacAge)
+	}
+	if conf.HostConf.IfType == "memif" {
+		if err = validateMemifConf(&conf.HostConf.MemifConf); err != nil {
+			return err
+		}
+	}
+	if err = validateAclConf(&conf.AclConf); err != nil {
+		return err
+	}
+
+	// Pick the VPP instance serving the pod
+	apiSocket, err := getApiSocket(conf, args, kubeClient)
+	if err != nil {
+		logging.Debugf("AddOnHost(vpp): %v", err)
+		return err
+	}
+
+	// Map the BridgeName to a Bridge Domain ID. It is held until DEL, so
+	// release it on any failure from here on.
+	if bridgeName != "" {
+		bridgeDomain, err = acquireNameId(getNameTable(bridgeDomainTable, apiSocket), bridgeName, firstNamedBridgeDomain, maxBridgeDomain)
+		if err != nil {
+			logging.Debugf("AddOnHost(vpp): Error mapping BridgeName %s: %v", bridgeName, err)
+			return err
+		}
+	}
+
 	// Create Channel to pass requests to VPP
-	vppCh, err = vppinfra.VppOpenCh()
+	vppCh, err = vppinfra.VppOpenCh(apiSocket)
 	if err != nil {
+		releaseBridgeDomain(apiSocket, bridgeName)
 		return err
 	}
 	defer vppinfra.VppCloseCh(vppCh)
 
-	//
-	// Create Local Interface
-	//
-	if conf.HostConf.IfType == "memif" {
-		err = addLocalDeviceMemif(vppCh, conf, args, sharedDir, &data)
-	} else {
-		err = errors.New("ERROR: Unknown HostConf.IfType:" + conf.HostConf.IfType)
+	// Identity of the attachment, used by peers to find it
+	data.ApiSocket = apiSocket
+	data.IfName = args.IfName
+	data.PodNamespace, data.PodName, err = k8sclient.GetPodRef(args)
+	if err != nil {
+		logging.Debugf("AddOnHost(vpp): No pod reference: %v", err)
 	}
 
+	//
+	// Create Local Interface, tagged, UP and with its VLAN sub-interface
+	//
+	err = addLocalDevice(vppCh, conf, args, sharedDir, &data)
 	if err != nil {
+		releaseBridgeDomain(apiSocket, bridgeName)
 		return err
 	}
+	netSwIfIndex := getNetworkSwIfIndex(&data)
 
 	//
-	// Set interface to up (1)
+	// Filter the traffic of the interface before it joins the Local Network
 	//
-	err = vppinterface.SetState(vppCh.Ch, data.InterfaceSwIfIndex, 1)
+	err = addLocalNetworkAcl(vppCh, &conf.AclConf, &data)
 	if err != nil {
-		logging.Debugf("AddOnHost(vpp): Error bringing interface UP: %v", err)
+		logging.Debugf("AddOnHost(vpp): Error adding ACLs: %v", err)
+		undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+		releaseBridgeDomain(apiSocket, bridgeName)
 		return err
 	}
 
@@ -105,60 +215,93 @@ func (cniVpp CniVpp) AddOnHost(conf *types.NetConf,
 
 	// Add L2 Network if supplied
 	if conf.HostConf.NetType == "bridge" {
-
-		var bridgeDomain uint32
-
-		// Check if DEPRECATED Attribute is being used.
-		if conf.HostConf.BridgeConf.BridgeId != 0 {
-			bridgeDomain = uint32(conf.HostConf.BridgeConf.BridgeId)
-		}
-
-		// Determine if BridgeName was entered
-		if conf.HostConf.BridgeConf.BridgeName != "" {
-			var tmpBridgeDomain uint64
-			tmpBridgeDomain, err = strconv.ParseUint(conf.HostConf.BridgeConf.BridgeName, 10, 32)
-			bridgeDomain = uint32(tmpBridgeDomain)
-			if err != nil {
-				logging.Debugf("AddOnHost(vpp): Error - VPP BridgeName not an ID: %v", err)
-				return err
-			}
-		}
-
 		// Add Interface to Bridge. If Bridge does not exist, AddBridgeInterface()
 		// will create.
-		err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, interface_types.InterfaceIndex(data.InterfaceSwIfIndex))
+		bridgeOptions := getBridgeOptions(conf)
+		err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, netSwIfIndex, bridgeOptions)
 		if err != nil {
 			logging.Debugf("AddOnHost(vpp): Error adding interface to bridge: %v", err)
+			undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+			releaseBridgeDomain(apiSocket, bridgeName)
 			return err
 		} else {
 			if dbgBridge {
-				logging.Debugf("INTERFACE %d added to BRIDGE %d\n", data.InterfaceSwIfIndex, bridgeDomain)
+				logging.Debugf("INTERFACE %d added to BRIDGE %d\n", netSwIfIndex, bridgeDomain)
 				vppbridge.DumpBridge(vppCh.Ch, bridgeDomain)
 			}
 		}
-		// Add L3 Network if supplied
-	} else if conf.HostConf.NetType == "interface" {
-		if ipResult != nil && len(ipResult.IPs) != 0 {
-			err = vppinterface.AddDelIpAddress(vppCh.Ch, data.InterfaceSwIfIndex, true, ipResult)
+
+		if conf.HostConf.BridgeConf.Gateway {
+			err = addLocalNetworkGateway(vppCh, bridgeDomain, args.IfName, ipResult, &data)
 			if err != nil {
-				logging.Debugf("AddOnHost(vpp): Error adding IP: %v", err)
+				logging.Debugf("AddOnHost(vpp): Error adding gateway: %v", err)
+				_ = vppbridge.RemoveBridgeInterface(vppCh.Ch, bridgeDomain, netSwIfIndex)
+				undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+				releaseBridgeDomain(apiSocket, bridgeName)
 				return err
 			}
 		}
-	} else if conf.HostConf.NetType != "" {
-		err = errors.New("ERROR: Unknown HostConf.NetType:" + conf.HostConf.NetType)
-		logging.Debugf("AddOnHost(vpp): %v", err)
-		return err
-	} else {
-		return fmt.Errorf("ERROR: NetType must be provided")
+
+		if bridgeOptions.ArpTerm {
+			err = addLocalNetworkArpTerm(vppCh, bridgeDomain, args.IfName, ipResult, &data)
+			if err != nil {
+				logging.Debugf("AddOnHost(vpp): Error adding ARP termination entries: %v", err)
+				_ = vppbridge.RemoveBridgeInterface(vppCh.Ch, bridgeDomain, netSwIfIndex)
+				undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+				releaseBridgeDomain(apiSocket, bridgeName)
+				return err
+			}
+		}
+		// Add L3 Network if supplied
+	} else if conf.HostConf.NetType == "interface" {
+		// Bind the interface to its FIB table before adding addresses
+		err = addLocalNetworkVrf(vppCh, conf, netSwIfIndex, &data)
+		if err != nil {
+			logging.Debugf("AddOnHost(vpp): Error setting VRF: %v", err)
+			undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+			return err
+		}
+
+		err = addLocalNetworkAddresses(vppCh, args.IfName, netSwIfIndex, ipResult, &data)
+		if err != nil {
+			logging.Debugf("AddOnHost(vpp): Error adding IP or route: %v", err)
+			undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+			return err
+		}
+		// Add L2 Cross-Connect if supplied
+	} else if conf.HostConf.NetType == "xconnect" {
+		err = addLocalNetworkXconnect(vppCh, conf, netSwIfIndex, &data)
+		if err != nil {
+			logging.Debugf("AddOnHost(vpp): Error setting xconnect: %v", err)
+			undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+			return err
+		}
 	}
 
 	//
 	// Save Create Data for Delete
 	//
+	data.BridgeName = bridgeName
+	data.BridgeDomain = bridgeDomain
+	data.HostConf = conf.HostConf
+	data.NetName = conf.Name
+	data.ContainerID = args.ContainerID
+	data.SharedDir = sharedDir
 	err = SaveVppConfig(conf, args, &data)
 
 	if err != nil {
+		logging.Debugf("AddOnHost(vpp): Error saving data: %v", err)
+		if conf.HostConf.NetType == "bridge" {
+			delLocalNetworkArpTerm(vppCh, bridgeDomain, &data)
+			// Also deletes the bridge if this was the only interface on it
+			_ = vppbridge.RemoveBridgeInterface(vppCh.Ch, bridgeDomain, netSwIfIndex)
+		} else if conf.HostConf.NetType == "interface" {
+			delLocalNetworkRoutes(vppCh, &data)
+		} else if conf.HostConf.NetType == "xconnect" {
+			delLocalNetworkXconnect(vppCh, netSwIfIndex)
+		}
+		undoLocalDevice(vppCh, conf, args, sharedDir, &data)
+		releaseBridgeDomain(apiSocket, bridgeName)
 		return err
 	}
 
@@ -182,57 +325,103 @@ func (cniVpp CniVpp) DelFromHost(conf *types.NetConf, args *skel.CmdArgs, shared
 
 	logging.Infof("VPP DelFromHost: ENTER - Container %s Iface %s", args.ContainerID[:12], args.IfName)
 
+	// Peek at the saved data for the VPP instance the interface was created
+	// on. It is only deleted by LoadVppConfig() once VPP can be reached.
+	_ = ReadVppConfig(conf, args, &data)
+
 	// Create Channel to pass requests to VPP
-	vppCh, err = vppinfra.VppOpenCh()
+	vppCh, err = vppinfra.VppOpenCh(getSavedApiSocket(conf, &data))
 	if err != nil {
 		return err
 	}
 	defer vppinfra.VppCloseCh(vppCh)
 
 	// Retrieved squirreled away data needed for processing delete
+	data = VppSavedData{}
 	err = LoadVppConfig(conf, args, &data)
 
 	if err != nil {
 		return err
 	}
 
+	// Find the interface by its tag, its index changes if VPP restarted
+	found, err := resolveLocalDevice(vppCh, &data)
+	if err != nil {
+		logging.Debugf("DelFromHost(vpp): Error resolving interface: %v", err)
+		return err
+	}
+	if !found {
+		// VPP lost the interface and everything attached to it, only
+		// the node-local state is left to clean up
+		logging.Infof("VPP DelFromHost: Interface %s no longer in VPP", data.Tag)
+		releaseBridgeDomain(data.ApiSocket, data.BridgeName)
+		delLocalNetworkVrf(vppCh, &data)
+		cleanupLocalDeviceFiles(conf, args, sharedDir)
+		return nil
+	}
+
 	//
 	// Remove L2 Network if supplied
 	//
 	if conf.HostConf.NetType == "bridge" {
 
-		// Validate and convert input data
-		var bridgeDomain uint32 = uint32(conf.HostConf.BridgeConf.BridgeId)
+		// Use the Bridge Domain the interface was added to on ADD
+		bridgeDomain, err := getSavedBridgeDomain(conf, &data)
+		if err != nil {
+			logging.Debugf("DelFromHost(vpp): Error - VPP BridgeName not an ID: %v", err)
+			return err
+		}
 
 		if dbgBridge {
-			logging.Verbosef("INTERFACE %d retrieved from CONF - attempt to DELETE Bridge %d\n", data.InterfaceSwIfIndex, bridgeDomain)
+			logging.Verbosef("INTERFACE %d retrieved from CONF - attempt to DELETE Bridge %d\n", getNetworkSwIfIndex(&data), bridgeDomain)
 		}
 
+		// Failures are only logged, deleting the bridge removes any entry left
+		delLocalNetworkArpTerm(vppCh, bridgeDomain, &data)
+
 		// Remove MemIf from Bridge. RemoveBridgeInterface() will delete Bridge if
-		// no more interfaces are associated with the Bridge.
-		err = vppbridge.RemoveBridgeInterface(vppCh.Ch, bridgeDomain, interface_types.InterfaceIndex(data.InterfaceSwIfIndex))
+		// no more interfaces are associated with the Bridge. The saved data is
+		// already gone, so on failure carry on releasing the Bridge Domain ID
+		// and deleting the interface, which takes it out of the Bridge too.
+		err = vppbridge.RemoveBridgeInterface(vppCh.Ch, bridgeDomain, getNetworkSwIfIndex(&data))
 
 		if err != nil {
-			logging.Debugf("DelFromHost(vpp): Error removing interface from bridge: %v", err)
-			return err
+			logging.Warningf("DelFromHost(vpp): Error removing interface from bridge: %v", err)
 		} else {
 			if dbgBridge {
-				logging.Verbosef("INTERFACE %d removed from BRIDGE %d\n", data.InterfaceSwIfIndex, bridgeDomain)
+				logging.Verbosef("INTERFACE %d removed from BRIDGE %d\n", getNetworkSwIfIndex(&data), bridgeDomain)
 				vppbridge.DumpBridge(vppCh.Ch, bridgeDomain)
 			}
 		}
+
+		releaseBridgeDomain(data.ApiSocket, data.BridgeName)
+
+		//
+		// Remove L3 Network if supplied
+		//
+	} else if conf.HostConf.NetType == "interface" {
+		// Failures are only logged, deleting the interface below removes
+		// any address left.
+		delLocalNetworkRoutes(vppCh, &data)
+
+		if len(data.IPs) != 0 {
+			err = vppinterface.AddDelIpAddress(vppCh.Ch, getNetworkSwIfIndex(&data), false, &current.Result{IPs: data.IPs})
+			if err != nil {
+				logging.Warningf("DelFromHost(vpp): Error removing IP: %v", err)
+			}
+		}
+
+		//
+		// Remove L2 Cross-Connect if supplied, both directions
+		//
+	} else if conf.HostConf.NetType == "xconnect" {
+		delLocalNetworkXconnect(vppCh, getNetworkSwIfIndex(&data))
 	}
 
 	//
 	// Delete Local Interface
 	//
-	if conf.HostConf.IfType == "memif" {
-		return delLocalDeviceMemif(vppCh, conf, args, sharedDir, &data)
-	} else if conf.HostConf.IfType == "vhostuser" {
-		return fmt.Errorf("GOOD: Found HostConf.Type:" + conf.HostConf.IfType)
-	} else {
-		return fmt.Errorf("ERROR: Unknown HostConf.Type:" + conf.HostConf.IfType)
-	}
+	return delLocalDevice(vppCh, conf, args, sharedDir, &data)
 }
 
 func (cniVpp CniVpp) DelFromContainer(conf *types.NetConf, args *skel.CmdArgs, sharedDir string, pod *v1.Pod) error {
@@ -243,97 +432,1396 @@ func (cniVpp CniVpp) DelFromContainer(conf *types.NetConf, args *skel.CmdArgs, s
 	return nil
 }
 
-// Local Functions
-func getMemifSocketfileName(conf *types.NetConf,
-	sharedDir string,
-	containerID string,
-	ifName string) string {
-	if conf.HostConf.MemifConf.Socketfile == "" {
-		conf.HostConf.MemifConf.Socketfile = fmt.Sprintf("memif-%s-%s.sock", containerID[:12], ifName)
-	}
-	return filepath.Join(sharedDir, conf.HostConf.MemifConf.Socketfile)
-}
+func (cniVpp CniVpp) CheckOnHost(conf *types.NetConf, args *skel.CmdArgs, sharedDir string) error {
+	var vppCh vppinfra.ConnectionData
+	var data VppSavedData
+	var err error
 
-func addLocalDeviceMemif(vppCh vppinfra.ConnectionData,
-	conf *types.NetConf,
-	args *skel.CmdArgs,
-	sharedDir string,
-	data *VppSavedData) (err error) {
-	// Validate and convert input data
-	var memifRole vppmemif.MemifRole
-	var memifMode vppmemif.MemifMode
+	logging.Infof("VPP CheckOnHost: ENTER - Container %s Iface %s", args.ContainerID[:12], args.IfName)
 
-	// Retrieve the Socketfile path
-	memifSocketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+	// Read squirreled away data. Unlike DelFromHost(), leave it in place.
+	err = ReadVppConfig(conf, args, &data)
+	if err != nil {
+		logging.Debugf("CheckOnHost(vpp): %v", err)
+		return cnitypes.NewError(cnitypes.ErrUnknownContainer, "VPP attachment not found", err.Error())
+	}
 
-	// Apply default values to input configuration
-	if conf.HostConf.MemifConf.Role == "master" {
-		memifRole = vppmemif.RoleMaster
-	} else if conf.HostConf.MemifConf.Role == "slave" {
-		memifRole = vppmemif.RoleSlave
-	} else {
-		return fmt.Errorf("ERROR: Invalid MEMIF Role:" + conf.HostConf.MemifConf.Role)
+	if conf.HostConf.IfType != "memif" && conf.HostConf.IfType != "vhostuser" {
+		return cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, "Unknown HostConf.IfType", conf.HostConf.IfType)
 	}
 
-	if conf.HostConf.MemifConf.Mode == "" {
-		conf.HostConf.MemifConf.Mode = "ethernet"
+	// Create Channel to pass requests to VPP
+	vppCh, err = vppinfra.VppOpenCh(getSavedApiSocket(conf, &data))
+	if err != nil {
+		return cnitypes.NewError(cnitypes.ErrTryAgainLater, "Unable to connect to VPP", err.Error())
 	}
-	if conf.HostConf.MemifConf.Mode == "ethernet" {
-		memifMode = vppmemif.ModeEthernet
-	} else if conf.HostConf.MemifConf.Mode == "ip" {
-		memifMode = vppmemif.ModeIP
-	} else if conf.HostConf.MemifConf.Mode == "inject-punt" {
-		memifMode = vppmemif.ModePuntInject
-	} else {
-		return fmt.Errorf("ERROR: Invalid MEMIF Mode:" + conf.HostConf.MemifConf.Mode)
+	defer vppinfra.VppCloseCh(vppCh)
+
+	//
+	// Verify the interface still exists and is UP
+	//
+	hasSubif := data.SubIfSwIfIndex != 0
+	found, err := resolveLocalDevice(vppCh, &data)
+	if err != nil {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("Unable to find VPP interface %s", data.Tag), err.Error())
+	}
+	if !found {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("VPP interface %s not found", data.Tag), "")
+	}
+	if hasSubif && data.SubIfSwIfIndex == 0 {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("VPP sub-interface %s not found", getSubifTag(data.Tag)), "")
 	}
 
-	// Create Memif Socket
-	data.MemifSocketId, err = vppmemif.CreateMemifSocket(vppCh.Ch, memifSocketPath)
+	found, flags, err := vppinterface.GetState(vppCh.Ch, data.InterfaceSwIfIndex)
 	if err != nil {
-		logging.Debugf("addLocalDeviceMemif(vpp): Error creating memif socket: %v", err)
-		return
-	} else {
-		if dbgInterface {
-			logging.Verbosef("MEMIF SOCKET %d %s %s", data.MemifSocketId, memifSocketPath, "created")
-			vppmemif.DumpMemifSocket(vppCh.Ch)
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("Unable to query VPP interface %d", data.InterfaceSwIfIndex), err.Error())
+	}
+	if !found {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("VPP interface %d not found", data.InterfaceSwIfIndex), "")
+	}
+	if flags&interface_types.IF_STATUS_API_FLAG_ADMIN_UP == 0 {
+		return cnitypes.NewError(cnitypes.ErrInternal,
+			fmt.Sprintf("VPP interface %d is admin down", data.InterfaceSwIfIndex), "")
+	}
+
+	//
+	// Verify the interface is still in the expected Bridge Domain
+	//
+	if conf.HostConf.NetType == "bridge" {
+		bridgeDomain, err := getSavedBridgeDomain(conf, &data)
+		if err != nil {
+			return cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, "VPP BridgeName not an ID", err.Error())
+		}
+
+		if !vppbridge.IsBridgeInterface(vppCh.Ch, bridgeDomain, getNetworkSwIfIndex(&data)) {
+			return cnitypes.NewError(cnitypes.ErrInternal,
+				fmt.Sprintf("VPP interface %d not in bridge domain %d", getNetworkSwIfIndex(&data), bridgeDomain), "")
 		}
 	}
 
-	// Create MemIf Interface
-	data.InterfaceSwIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, data.MemifSocketId, memif.MemifRole(memifRole), memif.MemifMode(memifMode))
+	return nil
+}
+
+func (cniVpp CniVpp) GarbageCollect(conf *types.NetConf, validAttachments []cnitypes.GCAttachment) error {
+	var gcErr error
+
+	logging.Infof("VPP GarbageCollect: ENTER - Network %s", conf.Name)
+
+	dataList, err := ListVppConfig(conf)
 	if err != nil {
-		logging.Debugf("addLocalDeviceMemif(vpp): Error creating memif inteface: %v", err)
-		return
-	} else {
-		if dbgInterface {
-			logging.Verbosef("MEMIF %d %s %s ", data.InterfaceSwIfIndex, "created", args.IfName)
-			vppmemif.DumpMemif(vppCh.Ch)
+		logging.Debugf("GarbageCollect(vpp): %v", err)
+		return err
+	}
+
+	for _, data := range dataList {
+		if usrspcni.IsValidAttachment(validAttachments, data.ContainerID, data.IfName) {
+			continue
 		}
+
+		logging.Infof("VPP GarbageCollect: Deleting stale attachment - Container %s Iface %s", data.ContainerID[:12], data.IfName)
+		args := &skel.CmdArgs{ContainerID: data.ContainerID, IfName: data.IfName}
+
+		// Remove the interface, memif socket and saved data, and the bridge if empty
+		if err = cniVpp.DelFromHost(conf, args, data.SharedDir); err != nil {
+			_ = logging.Errorf("GarbageCollect(vpp): Container %s Iface %s - %v", data.ContainerID[:12], data.IfName, err)
+			gcErr = err
+			continue
+		}
+
+		// Remove configuration data written to the shared directory, and the
+		// directory itself if empty
+		_ = configdata.FileCleanup("", filepath.Join(data.SharedDir, configdata.GetRemoteConfigFileName(args)))
+		_ = cniVpp.DelFromContainer(conf, args, data.SharedDir, nil)
 	}
 
-	return
+	return gcErr
 }
 
-func delLocalDeviceMemif(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) (err error) {
-	// Retrieve the Socketfile name
-	memifSocketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+func (cniVpp CniVpp) Status(conf *types.NetConf) error {
+	var err error
 
-	// Delete the memif interface
-	err = vppmemif.DeleteMemifInterface(vppCh.Ch, interface_types.InterfaceIndex(data.InterfaceSwIfIndex))
+	logging.Infof("VPP Status: ENTER - Network %s", conf.Name)
+
+	// Every VPP instance the network can attach to should be answering.
+	// Pods on the instances that do can still be added.
+	var unavailable []string
+	var statusErr error
+	apiSockets := getApiSockets(conf)
+	for _, apiSocket := range apiSockets {
+		if err = statusVppInstance(apiSocket); err != nil {
+			if statusErr == nil {
+				statusErr = err
+			}
+			unavailable = append(unavailable, strconv.Quote(apiSocket))
+		}
+	}
+
+	if len(unavailable) == len(apiSockets) {
+		return statusErr
+	} else if len(unavailable) != 0 {
+		return cnitypes.NewError(types.ErrLimitedConnectivity, "Some VPP instances unavailable", strings.Join(unavailable, ", "))
+	}
+
+	return nil
+}
+
+// Local Functions
+
+// Verify the VPP instance behind the API socket is answering API requests.
+func statusVppInstance(apiSocket string) error {
+	// Create Channel to pass requests to VPP
+	vppCh, err := vppinfra.VppOpenCh(apiSocket)
 	if err != nil {
-		logging.Debugf("delLocalDeviceMemif(vpp): Error deleting memif inteface: %v", err)
-		return logging.Errorf("delLocalDeviceMemif(vpp): Error deleting memif inteface: %v", err)
-	} else {
-		if dbgInterface {
-			logging.Verbosef("INTERFACE %d deleted\n", data.InterfaceSwIfIndex)
-			vppmemif.DumpMemif(vppCh.Ch)
-			vppmemif.DumpMemifSocket(vppCh.Ch)
+		logging.Debugf("Status(vpp): %s: %v", apiSocket, err)
+		return cnitypes.NewError(types.ErrPluginNotAvailable, "Unable to connect to VPP", err.Error())
+	}
+	defer vppinfra.VppCloseCh(vppCh)
+
+	version, err := vppinfra.VppVersion(vppCh.Ch)
+	if err != nil {
+		logging.Debugf("Status(vpp): %s: %v", apiSocket, err)
+		return cnitypes.NewError(types.ErrPluginNotAvailable, "Unable to retrieve VPP version", err.Error())
+	}
+	logging.Debugf("Status(vpp): VPP version %s on %q", version, apiSocket)
+
+	return nil
+}
+
+// getApiSocket() - Return the API socket of the VPP instance serving the pod.
+//
+//	With VppConf.NumaApiSockets, the instance is chosen from the NUMA node
+//	annotation of the pod. Pods without it, or without a pod to read it
+//	from, use VppConf.ApiSocket. An empty socket is govpp's default.
+func getApiSocket(conf *types.NetConf, args *skel.CmdArgs, kubeClient kubernetes.Interface) (string, error) {
+	if len(conf.VppConf.NumaApiSockets) == 0 {
+		return conf.VppConf.ApiSocket, nil
+	}
+
+	pod, _, err := k8sclient.GetPod(args, kubeClient, conf.KubeConfig)
+	if err != nil || pod == nil {
+		logging.Debugf("getApiSocket(vpp): No pod to read NUMA node from, using default socket: %v", err)
+		return conf.VppConf.ApiSocket, nil
+	}
+
+	numaNode, ok := pod.Annotations[annotations.AnnotKeyUsrspNumaNode]
+	if !ok {
+		return conf.VppConf.ApiSocket, nil
+	}
+
+	apiSocket, ok := conf.VppConf.NumaApiSockets[numaNode]
+	if !ok {
+		return "", fmt.Errorf("ERROR: No VPP API socket for NUMA node %s", numaNode)
+	}
+	logging.Debugf("getApiSocket(vpp): NUMA node %s uses %s", numaNode, apiSocket)
+
+	return apiSocket, nil
+}
+
+// getSavedApiSocket() - Return the API socket the attachment was created on,
+//
+//	or the configured one for attachments saved before it was recorded.
+func getSavedApiSocket(conf *types.NetConf, data *VppSavedData) string {
+	if data.ApiSocket != "" {
+		return data.ApiSocket
+	}
+	return conf.VppConf.ApiSocket
+}
+
+// Return every API socket the network can attach to, without duplicates.
+func getApiSockets(conf *types.NetConf) []string {
+	apiSockets := []string{conf.VppConf.ApiSocket}
+
+	numaNodes := make([]string, 0, len(conf.VppConf.NumaApiSockets))
+	for numaNode := range conf.VppConf.NumaApiSockets {
+		numaNodes = append(numaNodes, numaNode)
+	}
+	sort.Strings(numaNodes)
+
+	for _, numaNode := range numaNodes {
+		apiSocket := conf.VppConf.NumaApiSockets[numaNode]
+		if !slices.Contains(apiSockets, apiSocket) {
+			apiSockets = append(apiSockets, apiSocket)
 		}
 	}
 
-	// Remove socketfile
-	err = configdata.FileCleanup("", memifSocketPath)
+	return apiSockets
+}
 
-	return
+// Determine the Bridge Name from the input configuration. BridgeName,
+// if entered, overrides the DEPRECATED BridgeId. An empty name is Bridge
+// Domain 0, which VPP always has and is not tracked in the table.
+func getBridgeName(conf *types.NetConf) string {
+	if conf.HostConf.BridgeConf.BridgeName != "" {
+		return conf.HostConf.BridgeConf.BridgeName
+	}
+	if conf.HostConf.BridgeConf.BridgeId != 0 {
+		return strconv.Itoa(conf.HostConf.BridgeConf.BridgeId)
+	}
+	return ""
+}
+
+// Release the Bridge Domain ID held for a BridgeName on a VPP instance.
+// Failures are only logged, the attachment is gone either way.
+func releaseBridgeDomain(apiSocket string, bridgeName string) {
+	if bridgeName == "" {
+		return
+	}
+	if _, err := releaseNameId(getNameTable(bridgeDomainTable, apiSocket), bridgeName); err != nil {
+		logging.Warningf("releaseBridgeDomain(): Unable to release BridgeName %s - %v", bridgeName, err)
+	}
+}
+
+// Determine the Bridge Domain an attachment was added to. Data saved
+// before BridgeNames were mapped has no BridgeName, in that case the
+// Bridge Domain is the numeric BridgeName from the input configuration.
+func getSavedBridgeDomain(conf *types.NetConf, data *VppSavedData) (uint32, error) {
+	if data.BridgeName != "" {
+		return data.BridgeDomain, nil
+	}
+	return getBridgeDomain(conf)
+}
+
+// Determine the Bridge Domain from the input configuration, for a numeric
+// BridgeName or the DEPRECATED BridgeId.
+func getBridgeDomain(conf *types.NetConf) (uint32, error) {
+	var bridgeDomain uint32
+
+	// Check if DEPRECATED Attribute is being used.
+	if conf.HostConf.BridgeConf.BridgeId != 0 {
+		bridgeDomain = uint32(conf.HostConf.BridgeConf.BridgeId)
+	}
+
+	// Determine if BridgeName was entered
+	if conf.HostConf.BridgeConf.BridgeName != "" {
+		tmpBridgeDomain, err := strconv.ParseUint(conf.HostConf.BridgeConf.BridgeName, 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		bridgeDomain = uint32(tmpBridgeDomain)
+	}
+
+	return bridgeDomain, nil
+}
+
+// getBridgeOptions() - Return the Bridge Domain options of the BridgeConf.
+//
+//	Unset options keep the VPP defaults.
+func getBridgeOptions(conf *types.NetConf) vppbridge.BridgeOptions {
+	bridgeConf := &conf.HostConf.BridgeConf
+	options := vppbridge.DefaultBridgeOptions()
+
+	if bridgeConf.Learn != nil {
+		options.Learn = *bridgeConf.Learn
+	}
+	if bridgeConf.Flood != nil {
+		options.Flood = *bridgeConf.Flood
+	}
+	if bridgeConf.UuFlood != nil {
+		options.UuFlood = *bridgeConf.UuFlood
+	}
+	if bridgeConf.Forward != nil {
+		options.Forward = *bridgeConf.Forward
+	}
+	if bridgeConf.ArpTerm != nil {
+		options.ArpTerm = *bridgeConf.ArpTerm
+	}
+	options.MacAge = uint8(bridgeConf.MacAge)
+
+	return options
+}
+
+// addLocalNetworkGateway() - Give the Bridge Domain a loopback BVI with the
+//
+//	IPAM gateway of each pod address, unless it already has one. The
+//	addresses are recorded in the saved data, so the BVI can be recreated.
+func addLocalNetworkGateway(vppCh vppinfra.ConnectionData, bridgeDomain uint32, ifName string, ipResult *current.Result, data *VppSavedData) error {
+	data.Gateways = ipresult.GetGateways(ipResult, ifName)
+	if len(data.Gateways) == 0 {
+		return fmt.Errorf("ERROR: BridgeConf.Gateway set but no IPAM gateway for %s", ifName)
+	}
+
+	return vppbridge.AddBridgeGateway(vppCh.Ch, bridgeDomain, getGatewayNets(data.Gateways))
+}
+
+// getGatewayNets() - Convert saved gateway addresses for the bridge API.
+func getGatewayNets(gateways []cnitypes.IPNet) []net.IPNet {
+	nets := make([]net.IPNet, 0, len(gateways))
+	for _, gateway := range gateways {
+		nets = append(nets, net.IPNet(gateway))
+	}
+	return nets
+}
+
+// addLocalNetworkArpTerm() - Add the IP/MAC of the pod interface to the ARP
+//
+//	termination table of the Bridge Domain. The MAC is the one in the
+//	result, or a generated one set in the result for the container.
+func addLocalNetworkArpTerm(vppCh vppinfra.ConnectionData, bridgeDomain uint32, ifName string, ipResult *current.Result, data *VppSavedData) error {
+	var mac net.HardwareAddr
+	var err error
+
+	ifIndex, ips := ipresult.GetInterfaceIPs(ipResult, ifName)
+	if ifIndex < 0 || len(ips) == 0 {
+		logging.Debugf("addLocalNetworkArpTerm(): No IP address for %s, no ARP termination entries added", ifName)
+		return nil
+	}
+
+	if ipResult.Interfaces[ifIndex].Mac != "" {
+		mac, err = net.ParseMAC(ipResult.Interfaces[ifIndex].Mac)
+	} else {
+		mac, err = generateRandomMacAddress()
+	}
+	if err != nil {
+		return fmt.Errorf("ERROR: Unable to determine MAC address of %s: %v", ifName, err)
+	}
+	data.IfMac = mac.String()
+
+	if err = addLocalNetworkArpTermEntries(vppCh, bridgeDomain, ips, data); err != nil {
+		return err
+	}
+	ipResult.Interfaces[ifIndex].Mac = data.IfMac
+
+	return nil
+}
+
+// addLocalNetworkArpTermEntries() - Add an ARP termination entry with the
+//
+//	saved IfMac for each IP. The IPs added are recorded in the saved data,
+//	so DEL can remove them. On failure, the entries already added are removed.
+func addLocalNetworkArpTermEntries(vppCh vppinfra.ConnectionData, bridgeDomain uint32, ips []*current.IPConfig, data *VppSavedData) error {
+	mac, err := net.ParseMAC(data.IfMac)
+	if err != nil {
+		return fmt.Errorf("ERROR: Invalid IfMac %s: %v", data.IfMac, err)
+	}
+
+	for _, ip := range ips {
+		err = vppbridge.AddDelArpTermEntry(vppCh.Ch, bridgeDomain, ip.Address.IP, mac, true)
+		if err != nil {
+			err = fmt.Errorf("ERROR: Failed to add ARP termination entry %s: %v", ip.Address.IP.String(), err)
+			delLocalNetworkArpTerm(vppCh, bridgeDomain, data)
+			return err
+		}
+		data.ArpTermIPs = append(data.ArpTermIPs, ip)
+	}
+
+	return nil
+}
+
+// delLocalNetworkArpTerm() - Remove the ARP termination entries added by
+//
+//	addLocalNetworkArpTermEntries(). Failures are only logged, so all
+//	entries are attempted.
+func delLocalNetworkArpTerm(vppCh vppinfra.ConnectionData, bridgeDomain uint32, data *VppSavedData) {
+	if len(data.ArpTermIPs) == 0 {
+		return
+	}
+
+	mac, err := net.ParseMAC(data.IfMac)
+	if err != nil {
+		logging.Warningf("delLocalNetworkArpTerm(): Invalid IfMac %s - %v", data.IfMac, err)
+		return
+	}
+
+	for _, ip := range data.ArpTermIPs {
+		err = vppbridge.AddDelArpTermEntry(vppCh.Ch, bridgeDomain, ip.Address.IP, mac, false)
+		if err != nil {
+			logging.Warningf("delLocalNetworkArpTerm(): Failed to delete ARP termination entry %s - %v", ip.Address.IP.String(), err)
+		}
+	}
+	data.ArpTermIPs = nil
+}
+
+// generateRandomMacAddress() - Return a random, locally administered,
+//
+//	unicast MAC address.
+func generateRandomMacAddress() (net.HardwareAddr, error) {
+	mac := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(mac); err != nil {
+		return nil, err
+	}
+
+	// Set the local bit and make sure not MC address
+	mac[0] = (mac[0] | 0x2) &^ 0x1
+	return mac, nil
+}
+
+// Validate the AclConf rules, before touching VPP.
+func validateAclConf(aclConf *types.AclConf) error {
+	_, err := getAclRules(aclConf.Ingress)
+	if err == nil {
+		_, err = getAclRules(aclConf.Egress)
+	}
+	return err
+}
+
+// addLocalNetworkAcl() - Filter the traffic of the interface in the Local
+//
+//	Network, the VLAN sub-interface if there is one, with the rules of the
+//	AclConf. The interface receives the traffic sent by the pod, so
+//	Egress rules are its input ACL and Ingress rules its output ACL. The
+//	ACL indexes are saved for DEL.
+func addLocalNetworkAcl(vppCh vppinfra.ConnectionData, aclConf *types.AclConf, data *VppSavedData) error {
+	var inputAcls, outputAcls []uint32
+
+	if len(aclConf.Ingress) == 0 && len(aclConf.Egress) == 0 {
+		return nil
+	}
+
+	// The ACL plugin is optional in VPP
+	if err := vppinfra.CheckAclCompatibility(vppCh.Ch); err != nil {
+		return err
+	}
+
+	for _, dir := range []struct {
+		rules []types.AclRule
+		tag   string
+		acls  *[]uint32
+	}{
+		{aclConf.Egress, data.Tag + "-egress", &inputAcls},
+		{aclConf.Ingress, data.Tag + "-ingress", &outputAcls},
+	} {
+		if len(dir.rules) == 0 {
+			continue
+		}
+		rules, err := getAclRules(dir.rules)
+		if err == nil {
+			var aclIndex uint32
+			aclIndex, err = vppacl.AddAcl(vppCh.Ch, dir.tag, rules)
+			if err == nil {
+				data.AclIndexes = append(data.AclIndexes, aclIndex)
+				*dir.acls = append(*dir.acls, aclIndex)
+			}
+		}
+		if err != nil {
+			delLocalNetworkAcl(vppCh, data)
+			return err
+		}
+	}
+
+	err := vppacl.SetInterfaceAcls(vppCh.Ch, getNetworkSwIfIndex(data), inputAcls, outputAcls)
+	if err != nil {
+		delLocalNetworkAcl(vppCh, data)
+		return err
+	}
+	data.Acl = *aclConf
+
+	return nil
+}
+
+// delLocalNetworkAcl() - Remove the ACLs added by addLocalNetworkAcl() from
+//
+//	the interface and delete them. Failures are only logged.
+func delLocalNetworkAcl(vppCh vppinfra.ConnectionData, data *VppSavedData) {
+	if len(data.AclIndexes) == 0 {
+		return
+	}
+
+	swIfIndex := getNetworkSwIfIndex(data)
+	if err := vppacl.SetInterfaceAcls(vppCh.Ch, swIfIndex, nil, nil); err != nil {
+		logging.Warningf("delLocalNetworkAcl(): Failed to remove ACLs from interface %d - %v", swIfIndex, err)
+	}
+	for _, aclIndex := range data.AclIndexes {
+		if err := vppacl.DeleteAcl(vppCh.Ch, aclIndex); err != nil {
+			logging.Warningf("delLocalNetworkAcl(): Failed to delete ACL %d - %v", aclIndex, err)
+		}
+	}
+	data.AclIndexes = nil
+}
+
+// Convert AclConf rules to VPP ACL rules. A rule without prefixes applies
+// to IPv4 and IPv6, unless its protocol is ICMP or ICMPv6.
+func getAclRules(rules []types.AclRule) ([]vppacl.AclRule, error) {
+	var aclRules []vppacl.AclRule
+
+	for i := range rules {
+		rule, err := getAclRule(&rules[i])
+		if err != nil {
+			return nil, err
+		}
+		aclRules = append(aclRules, rule...)
+	}
+
+	return aclRules, nil
+}
+
+// Convert an AclConf rule to a VPP ACL rule per address family it matches.
+func getAclRule(rule *types.AclRule) ([]vppacl.AclRule, error) {
+	var aclRule vppacl.AclRule
+	var aclRules []vppacl.AclRule
+	var err error
+
+	switch rule.Action {
+	case "allow":
+		aclRule.Permit = true
+	case "deny":
+	default:
+		return nil, fmt.Errorf("ERROR: Invalid AclRule.Action:%s", rule.Action)
+	}
+
+	if aclRule.Proto, err = getAclProto(rule.Proto); err != nil {
+		return nil, err
+	}
+	if aclRule.SrcPortFirst, aclRule.SrcPortLast, err = getAclPorts(rule.SrcPorts, aclRule.Proto); err != nil {
+		return nil, err
+	}
+	if aclRule.DstPortFirst, aclRule.DstPortLast, err = getAclPorts(rule.DstPorts, aclRule.Proto); err != nil {
+		return nil, err
+	}
+
+	src, err := getAclPrefix(rule.Src)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := getAclPrefix(rule.Dst)
+	if err != nil {
+		return nil, err
+	}
+	if src != nil && dst != nil && len(src.IP) != len(dst.IP) {
+		return nil, fmt.Errorf("ERROR: AclRule mixes IPv4 and IPv6 prefixes: %s %s", rule.Src, rule.Dst)
+	}
+
+	// One rule per address family, matching any address unless restricted
+	for _, ipLen := range []int{net.IPv4len, net.IPv6len} {
+		if (src != nil && len(src.IP) != ipLen) || (dst != nil && len(dst.IP) != ipLen) {
+			continue
+		}
+		if (aclRule.Proto == protoIcmp && ipLen != net.IPv4len) || (aclRule.Proto == protoIcmpv6 && ipLen != net.IPv6len) {
+			continue
+		}
+		anyNet := net.IPNet{IP: make(net.IP, ipLen), Mask: net.CIDRMask(0, ipLen*8)}
+		aclRule.Src, aclRule.Dst = anyNet, anyNet
+		if src != nil {
+			aclRule.Src = *src
+		}
+		if dst != nil {
+			aclRule.Dst = *dst
+		}
+		aclRules = append(aclRules, aclRule)
+	}
+	if len(aclRules) == 0 {
+		return nil, fmt.Errorf("ERROR: AclRule.Proto %s doesn't match the address family of its prefixes", rule.Proto)
+	}
+
+	return aclRules, nil
+}
+
+// Parse an AclRule prefix, nil when unset. A single address is a host prefix.
+func getAclPrefix(prefix string) (*net.IPNet, error) {
+	if prefix == "" {
+		return nil, nil
+	}
+	if !strings.Contains(prefix, "/") {
+		ip := net.ParseIP(prefix)
+		if ip == nil {
+			return nil, fmt.Errorf("ERROR: Invalid AclRule prefix:%s", prefix)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("ERROR: Invalid AclRule prefix:%s", prefix)
+	}
+	return ipNet, nil
+}
+
+// Parse an AclRule protocol name or number, 0 when unset.
+func getAclProto(proto string) (uint8, error) {
+	switch proto {
+	case "":
+		return 0, nil
+	case "icmp":
+		return protoIcmp, nil
+	case "tcp":
+		return protoTcp, nil
+	case "udp":
+		return protoUdp, nil
+	case "icmpv6":
+		return protoIcmpv6, nil
+	}
+	number, err := strconv.ParseUint(proto, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("ERROR: Invalid AclRule.Proto:%s", proto)
+	}
+	return uint8(number), nil
+}
+
+// Parse an AclRule port or port range, all ports when unset. Ports are only
+// matched with TCP and UDP.
+func getAclPorts(ports string, proto uint8) (uint16, uint16, error) {
+	if ports == "" {
+		return 0, math.MaxUint16, nil
+	}
+	if proto != protoTcp && proto != protoUdp {
+		return 0, 0, fmt.Errorf("ERROR: AclRule ports %s need proto tcp or udp", ports)
+	}
+
+	firstStr, lastStr, isRange := strings.Cut(ports, "-")
+	if !isRange {
+		lastStr = firstStr
+	}
+	first, err := strconv.ParseUint(firstStr, 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ERROR: Invalid AclRule ports:%s", ports)
+	}
+	last, err := strconv.ParseUint(lastStr, 10, 16)
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("ERROR: Invalid AclRule ports:%s", ports)
+	}
+	return uint16(first), uint16(last), nil
+}
+
+func getMemifSocketfileName(conf *types.NetConf,
+	sharedDir string,
+	containerID string,
+	ifName string) string {
+	if conf.HostConf.MemifConf.Socketfile == "" {
+		conf.HostConf.MemifConf.Socketfile = fmt.Sprintf("memif-%s-%s.sock", containerID[:12], ifName)
+	}
+	return filepath.Join(sharedDir, conf.HostConf.MemifConf.Socketfile)
+}
+
+func addLocalDeviceMemif(vppCh vppinfra.ConnectionData,
+	conf *types.NetConf,
+	args *skel.CmdArgs,
+	sharedDir string,
+	data *VppSavedData) (err error) {
+	// Validate and convert input data
+	var memifRole vppmemif.MemifRole
+	var memifMode vppmemif.MemifMode
+
+	// Retrieve the Socketfile path
+	memifSocketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+
+	// Apply default values to input configuration
+	if conf.HostConf.MemifConf.Role == "master" {
+		memifRole = vppmemif.RoleMaster
+	} else if conf.HostConf.MemifConf.Role == "slave" {
+		memifRole = vppmemif.RoleSlave
+	} else {
+		return fmt.Errorf("ERROR: Invalid MEMIF Role:" + conf.HostConf.MemifConf.Role)
+	}
+
+	if conf.HostConf.MemifConf.Mode == "" {
+		conf.HostConf.MemifConf.Mode = "ethernet"
+	}
+	if conf.HostConf.MemifConf.Mode == "ethernet" {
+		memifMode = vppmemif.ModeEthernet
+	} else if conf.HostConf.MemifConf.Mode == "ip" {
+		memifMode = vppmemif.ModeIP
+	} else if conf.HostConf.MemifConf.Mode == "inject-punt" {
+		memifMode = vppmemif.ModePuntInject
+	} else {
+		return fmt.Errorf("ERROR: Invalid MEMIF Mode:" + conf.HostConf.MemifConf.Mode)
+	}
+
+	// Create Memif Socket
+	data.MemifSocketId, err = vppmemif.CreateMemifSocket(vppCh.Ch, memifSocketPath)
+	if err != nil {
+		logging.Debugf("addLocalDeviceMemif(vpp): Error creating memif socket: %v", err)
+		return
+	} else {
+		if dbgInterface {
+			logging.Verbosef("MEMIF SOCKET %d %s %s", data.MemifSocketId, memifSocketPath, "created")
+			vppmemif.DumpMemifSocket(vppCh.Ch)
+		}
+	}
+
+	// Create MemIf Interface
+	data.InterfaceSwIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, data.MemifSocketId, memif.MemifRole(memifRole), memif.MemifMode(memifMode), getMemifOptions(conf))
+	if err != nil {
+		logging.Debugf("addLocalDeviceMemif(vpp): Error creating memif inteface: %v", err)
+
+		// Remove the socket again, VPP refuses if other interfaces still use it
+		if delErr := vppmemif.DeleteMemifSocket(vppCh.Ch, data.MemifSocketId); delErr != nil {
+			logging.Debugf("addLocalDeviceMemif(vpp): Memif socket %d not deleted: %v", data.MemifSocketId, delErr)
+		}
+		return
+	} else {
+		if dbgInterface {
+			logging.Verbosef("MEMIF %d %s %s ", data.InterfaceSwIfIndex, "created", args.IfName)
+			vppmemif.DumpMemif(vppCh.Ch)
+		}
+	}
+
+	return
+}
+
+func delLocalDeviceMemif(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) (err error) {
+	// Retrieve the Socketfile name
+	memifSocketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+
+	// Delete the memif interface
+	err = vppmemif.DeleteMemifInterface(vppCh.Ch, interface_types.InterfaceIndex(data.InterfaceSwIfIndex))
+	if err != nil {
+		logging.Debugf("delLocalDeviceMemif(vpp): Error deleting memif inteface: %v", err)
+		return logging.Errorf("delLocalDeviceMemif(vpp): Error deleting memif inteface: %v", err)
+	} else {
+		if dbgInterface {
+			logging.Verbosef("INTERFACE %d deleted\n", data.InterfaceSwIfIndex)
+			vppmemif.DumpMemif(vppCh.Ch)
+			vppmemif.DumpMemifSocket(vppCh.Ch)
+		}
+	}
+
+	// Remove socketfile
+	err = configdata.FileCleanup("", memifSocketPath)
+
+	return
+}
+
+// Validate the optional memif interface settings. Unset (0) values are
+// valid, VPP defaults are used for them.
+func validateMemifConf(memifConf *types.MemifConf) error {
+	if memifConf.RxQueues < 0 || memifConf.RxQueues > maxMemifQueues {
+		return fmt.Errorf("ERROR: Invalid MemifConf.RxQueues:%d", memifConf.RxQueues)
+	}
+	if memifConf.TxQueues < 0 || memifConf.TxQueues > maxMemifQueues {
+		return fmt.Errorf("ERROR: Invalid MemifConf.TxQueues:%d", memifConf.TxQueues)
+	}
+	// The ring size must be a power of 2
+	if memifConf.RingSize < 0 || memifConf.RingSize > maxMemifRingSize || memifConf.RingSize&(memifConf.RingSize-1) != 0 {
+		return fmt.Errorf("ERROR: Invalid MemifConf.RingSize:%d", memifConf.RingSize)
+	}
+	if memifConf.BufferSize < 0 || memifConf.BufferSize > maxMemifBufferSize {
+		return fmt.Errorf("ERROR: Invalid MemifConf.BufferSize:%d", memifConf.BufferSize)
+	}
+	if memifConf.Id < 0 || memifConf.Id > math.MaxUint32 {
+		return fmt.Errorf("ERROR: Invalid MemifConf.Id:%d", memifConf.Id)
+	}
+	if len(memifConf.Secret) > maxMemifSecretLen {
+		return fmt.Errorf("ERROR: MemifConf.Secret longer than %d characters", maxMemifSecretLen)
+	}
+	return nil
+}
+
+// Convert the memif interface settings, validated by validateMemifConf(),
+// for the VPP API.
+func getMemifOptions(conf *types.NetConf) vppmemif.MemifOptions {
+	return vppmemif.MemifOptions{
+		RxQueues:   uint8(conf.HostConf.MemifConf.RxQueues),
+		TxQueues:   uint8(conf.HostConf.MemifConf.TxQueues),
+		RingSize:   uint32(conf.HostConf.MemifConf.RingSize),
+		BufferSize: uint16(conf.HostConf.MemifConf.BufferSize),
+		Id:         uint32(conf.HostConf.MemifConf.Id),
+		Secret:     conf.HostConf.MemifConf.Secret,
+	}
+}
+
+func getVhostSocketfileName(conf *types.NetConf,
+	sharedDir string,
+	containerID string,
+	ifName string) string {
+	// Same default name and short shared directory as the OVS engine
+	if conf.HostConf.VhostConf.Socketfile == "" {
+		conf.HostConf.VhostConf.Socketfile = fmt.Sprintf("%s-%s", containerID[:12], ifName)
+	}
+	return filepath.Join(shareddir.GetShortSharedDir(sharedDir), conf.HostConf.VhostConf.Socketfile)
+}
+
+func addLocalDeviceVhost(vppCh vppinfra.ConnectionData,
+	conf *types.NetConf,
+	args *skel.CmdArgs,
+	sharedDir string,
+	data *VppSavedData) (err error) {
+	// Validate and convert input data. VPP is the server unless it was
+	// asked to be the client, as with the OVS engine.
+	var vhostMode vppvhostuser.VhostUserMode
+
+	if conf.HostConf.VhostConf.Mode == "client" {
+		vhostMode = vppvhostuser.ModeClient
+	} else if conf.HostConf.VhostConf.Mode == "server" || conf.HostConf.VhostConf.Mode == "" {
+		vhostMode = vppvhostuser.ModeServer
+	} else {
+		return fmt.Errorf("ERROR: Invalid Vhost Mode:" + conf.HostConf.VhostConf.Mode)
+	}
+
+	// Retrieve the Socketfile path
+	vhostSocketPath := getVhostSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+
+	// Make sure the directory exists, and is accessible by the group of the
+	// container application
+	shortSharedDir := shareddir.GetShortSharedDir(sharedDir)
+	if err = shareddir.CreateSharedDir(shortSharedDir, sharedDir); err != nil {
+		logging.Debugf("addLocalDeviceVhost(vpp): Error creating shared dir: %v", err)
+		return
+	}
+	if conf.HostConf.VhostConf.Group != "" {
+		if err = shareddir.SetSharedDirGroup(shortSharedDir, conf.HostConf.VhostConf.Group); err != nil {
+			logging.Debugf("addLocalDeviceVhost(vpp): Error setting shared dir group: %v", err)
+			return
+		}
+	}
+
+	// Create Vhost-User Interface
+	data.InterfaceSwIfIndex, err = vppvhostuser.CreateVhostUserInterface(vppCh.Ch, bool(vhostMode), vhostSocketPath)
+	if err != nil {
+		logging.Debugf("addLocalDeviceVhost(vpp): Error creating vhost-user interface: %v", err)
+		return
+	} else {
+		if dbgInterface {
+			logging.Verbosef("VHOST-USER %d %s %s", data.InterfaceSwIfIndex, "created", args.IfName)
+			vppvhostuser.DumpVhostUser(vppCh.Ch)
+		}
+	}
+
+	return
+}
+
+func delLocalDeviceVhost(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) (err error) {
+	// Retrieve the Socketfile name
+	vhostSocketPath := getVhostSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+
+	// Delete the vhost-user interface
+	err = vppvhostuser.DeleteVhostUserInterface(vppCh.Ch, data.InterfaceSwIfIndex)
+	if err != nil {
+		return logging.Errorf("delLocalDeviceVhost(vpp): Error deleting vhost-user interface: %v", err)
+	} else {
+		if dbgInterface {
+			logging.Verbosef("INTERFACE %d deleted\n", data.InterfaceSwIfIndex)
+			vppvhostuser.DumpVhostUser(vppCh.Ch)
+		}
+	}
+
+	// Remove socketfile. In server mode VPP normally removes it itself, in
+	// client mode it belongs to the container application.
+	if _, statErr := os.Stat(vhostSocketPath); statErr == nil {
+		err = configdata.FileCleanup("", vhostSocketPath)
+	}
+
+	// Release a short shared directory, as the OVS engine does
+	if shortSharedDir := shareddir.GetShortSharedDir(sharedDir); err == nil && shareddir.IsShortSharedDir(shortSharedDir) {
+		err = shareddir.RemoveShortSharedDir(shortSharedDir)
+	}
+
+	return
+}
+
+// addLocalDeviceSubif() - Create a dot1q VLAN sub-interface on the interface.
+//
+//	On a bridge the VLAN tag is popped, so the Bridge Domain only carries
+//	the frames the container sent tagged with the VLAN Id, untagged.
+func addLocalDeviceSubif(vppCh vppinfra.ConnectionData, conf *types.NetConf, data *VppSavedData) (err error) {
+	data.SubIfSwIfIndex, err = vppinterface.CreateVlanSubif(vppCh.Ch, data.InterfaceSwIfIndex, uint32(conf.HostConf.BridgeConf.VlanId))
+	if err != nil {
+		logging.Debugf("addLocalDeviceSubif(vpp): Error creating VLAN %d sub-interface: %v", conf.HostConf.BridgeConf.VlanId, err)
+		return
+	}
+	if dbgInterface {
+		logging.Verbosef("SUB-INTERFACE %d %s VLAN %d", data.SubIfSwIfIndex, "created", conf.HostConf.BridgeConf.VlanId)
+	}
+
+	err = vppinterface.SetTag(vppCh.Ch, data.SubIfSwIfIndex, getSubifTag(data.Tag))
+	if err != nil {
+		logging.Debugf("addLocalDeviceSubif(vpp): Error tagging sub-interface: %v", err)
+		return
+	}
+
+	if conf.HostConf.NetType == "bridge" {
+		err = vppbridge.SetVlanTagPop(vppCh.Ch, data.SubIfSwIfIndex)
+		if err != nil {
+			logging.Debugf("addLocalDeviceSubif(vpp): Error setting VLAN tag rewrite: %v", err)
+			return
+		}
+	}
+
+	err = vppinterface.SetState(vppCh.Ch, data.SubIfSwIfIndex, 1)
+	if err != nil {
+		logging.Debugf("addLocalDeviceSubif(vpp): Error bringing sub-interface UP: %v", err)
+	}
+
+	return
+}
+
+// Interface added to the Local Network, the VLAN sub-interface if one was
+// created and the interface itself otherwise.
+func getNetworkSwIfIndex(data *VppSavedData) interface_types.InterfaceIndex {
+	if data.SubIfSwIfIndex != 0 {
+		return data.SubIfSwIfIndex
+	}
+	return data.InterfaceSwIfIndex
+}
+
+// addLocalNetworkAddresses() - Add the IPAM addresses of the pod interface
+//
+//	to an L3 interface, and route them and the IPAM routes through it. A
+//	chained result also holds the addresses and routes of the other
+//	interfaces of the pod, those are left out.
+func addLocalNetworkAddresses(vppCh vppinfra.ConnectionData, ifName string, swIfIndex interface_types.InterfaceIndex, ipResult *current.Result, data *VppSavedData) error {
+	_, ips := ipresult.GetInterfaceIPs(ipResult, ifName)
+	if len(ips) == 0 {
+		return nil
+	}
+
+	err := vppinterface.AddDelIpAddress(vppCh.Ch, swIfIndex, true, &current.Result{IPs: ips})
+	if err != nil {
+		return err
+	}
+	data.IPs = ips
+
+	return addLocalNetworkRoutes(vppCh, swIfIndex, getL3Routes(ips, ipResult.Routes), data)
+}
+
+// getL3Routes() - Determine the routes of an L3 interface from its IPAM
+//
+//	addresses and the IPAM routes. Each pod IP gets a host route via the
+//	interface, so it is reachable from the other VPP interfaces. IPAM
+//	routes without a gateway use the gateway of the pod IP in the same
+//	family, if any, else they are attached. Routes of other interfaces are
+//	skipped, see isInterfaceRoute().
+func getL3Routes(ips []*current.IPConfig, ipamRoutes []*cnitypes.Route) []*cnitypes.Route {
+	var routes []*cnitypes.Route
+	var gateway4, gateway6 net.IP
+
+	for _, ipConfig := range ips {
+		if ipConfig == nil {
+			continue
+		}
+
+		hostBits := 8 * net.IPv6len
+		if ipConfig.Address.IP.To4() != nil {
+			hostBits = 8 * net.IPv4len
+			if gateway4 == nil {
+				gateway4 = ipConfig.Gateway
+			}
+		} else if gateway6 == nil {
+			gateway6 = ipConfig.Gateway
+		}
+
+		routes = append(routes, &cnitypes.Route{
+			Dst: net.IPNet{IP: ipConfig.Address.IP, Mask: net.CIDRMask(hostBits, hostBits)},
+			GW:  ipConfig.Address.IP,
+		})
+	}
+
+	for _, route := range ipamRoutes {
+		if route == nil || !isInterfaceRoute(ips, route) {
+			continue
+		}
+
+		gw := route.GW
+		if gw == nil {
+			if route.Dst.IP.To4() != nil {
+				gw = gateway4
+			} else {
+				gw = gateway6
+			}
+		}
+		routes = append(routes, &cnitypes.Route{Dst: route.Dst, GW: gw})
+	}
+
+	return routes
+}
+
+// Whether an IPAM route goes through the interface with the given addresses.
+// Its gateway has to be in the subnet of one of them, a route without one
+// has to be in the family of one of them.
+func isInterfaceRoute(ips []*current.IPConfig, route *cnitypes.Route) bool {
+	for _, ipConfig := range ips {
+		if ipConfig == nil {
+			continue
+		}
+		if route.GW != nil {
+			if ipConfig.Address.Contains(route.GW) {
+				return true
+			}
+		} else if (ipConfig.Address.IP.To4() != nil) == (route.Dst.IP.To4() != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// addLocalNetworkRoutes() - Add the routes of an L3 interface to the FIB.
+//
+//	The routes added are recorded in the saved data, so DEL can withdraw
+//	them. On failure, the routes already added are withdrawn.
+func addLocalNetworkRoutes(vppCh vppinfra.ConnectionData, swIfIndex interface_types.InterfaceIndex, routes []*cnitypes.Route, data *VppSavedData) error {
+	for _, route := range routes {
+		err := vpproute.AddDelRoute(vppCh.Ch, data.Vrf, swIfIndex, true, route.Dst, route.GW)
+		if err != nil {
+			err = fmt.Errorf("ERROR: Failed to add route %s: %v", route.String(), err)
+			delLocalNetworkRoutes(vppCh, data)
+			return err
+		}
+		data.Routes = append(data.Routes, route)
+	}
+
+	return nil
+}
+
+// delLocalNetworkRoutes() - Withdraw the routes added by addLocalNetworkRoutes(),
+//
+//	in reverse order. Failures are only logged, so all routes are attempted.
+func delLocalNetworkRoutes(vppCh vppinfra.ConnectionData, data *VppSavedData) {
+	for i := len(data.Routes) - 1; i >= 0; i-- {
+		route := data.Routes[i]
+		err := vpproute.AddDelRoute(vppCh.Ch, data.Vrf, getNetworkSwIfIndex(data), false, route.Dst, route.GW)
+		if err != nil {
+			logging.Warningf("delLocalNetworkRoutes(): Failed to delete route %s - %v", route.String(), err)
+		}
+	}
+	data.Routes = nil
+}
+
+// addLocalNetworkVrf() - Bind an L3 interface to the FIB table of the VppConf.Vrf,
+//
+//	IPv4 and IPv6. The table is created with its first interface. The
+//	interfaces bound to each table are counted in a node-local table per
+//	VPP instance.
+func addLocalNetworkVrf(vppCh vppinfra.ConnectionData, conf *types.NetConf, swIfIndex interface_types.InterfaceIndex, data *VppSavedData) error {
+	vrf := conf.VppConf.Vrf
+	if vrf == 0 {
+		return nil
+	}
+
+	if _, err := acquireNameId(getNameTable(fibTableTable, data.ApiSocket), strconv.FormatUint(uint64(vrf), 10), vrf, vrf); err != nil {
+		return err
+	}
+	data.Vrf = vrf
+
+	if err := setLocalNetworkVrf(vppCh, vrf, swIfIndex); err != nil {
+		delLocalNetworkVrf(vppCh, data)
+		return err
+	}
+
+	return nil
+}
+
+// Create the IPv4 and IPv6 FIB tables of a VRF, if needed, and bind the
+// interface to them.
+func setLocalNetworkVrf(vppCh vppinfra.ConnectionData, vrf uint32, swIfIndex interface_types.InterfaceIndex) error {
+	for _, isIPv6 := range []bool{false, true} {
+		err := vpproute.AddDelTable(vppCh.Ch, vrf, isIPv6, true)
+		if err == nil {
+			err = vppinterface.SetTable(vppCh.Ch, swIfIndex, vrf, isIPv6)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// delLocalNetworkVrf() - Release the FIB table taken by addLocalNetworkVrf().
+//
+//	The table is deleted when its last interface is released. Failures are
+//	only logged.
+func delLocalNetworkVrf(vppCh vppinfra.ConnectionData, data *VppSavedData) {
+	if data.Vrf == 0 {
+		return
+	}
+
+	removed, err := releaseNameId(getNameTable(fibTableTable, data.ApiSocket), strconv.FormatUint(uint64(data.Vrf), 10))
+	if err != nil {
+		logging.Warningf("delLocalNetworkVrf(): Unable to release VRF %d - %v", data.Vrf, err)
+	} else if removed {
+		for _, isIPv6 := range []bool{false, true} {
+			if err = vpproute.AddDelTable(vppCh.Ch, data.Vrf, isIPv6, false); err != nil {
+				logging.Warningf("delLocalNetworkVrf(): Unable to delete VRF %d - %v", data.Vrf, err)
+			}
+		}
+	}
+	data.Vrf = 0
+}
+
+// addLocalDevice() - Create the interface according to the HostConf.IfType,
+//
+//	tag it, bring it UP and create its VLAN sub-interface if requested.
+//	On failure, whatever was created is deleted again.
+func addLocalDevice(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) error {
+	var err error
+
+	if conf.HostConf.IfType == "memif" {
+		err = addLocalDeviceMemif(vppCh, conf, args, sharedDir, data)
+	} else if conf.HostConf.IfType == "vhostuser" {
+		err = addLocalDeviceVhost(vppCh, conf, args, sharedDir, data)
+	} else {
+		err = errors.New("ERROR: Unknown HostConf.IfType:" + conf.HostConf.IfType)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Tag the interface, so it can be found after a VPP restart
+	data.Tag = getInterfaceTag(args.ContainerID, args.IfName)
+	err = vppinterface.SetTag(vppCh.Ch, data.InterfaceSwIfIndex, data.Tag)
+	if err != nil {
+		logging.Debugf("addLocalDevice(vpp): Error tagging interface: %v", err)
+		undoLocalDevice(vppCh, conf, args, sharedDir, data)
+		return err
+	}
+
+	//
+	// Set interface to up (1)
+	//
+	err = vppinterface.SetState(vppCh.Ch, data.InterfaceSwIfIndex, 1)
+	if err != nil {
+		logging.Debugf("addLocalDevice(vpp): Error bringing interface UP: %v", err)
+		undoLocalDevice(vppCh, conf, args, sharedDir, data)
+		return err
+	}
+
+	//
+	// Create VLAN sub-interface if requested. It is added to the Local
+	// Network in place of the interface.
+	//
+	if conf.HostConf.BridgeConf.VlanId != 0 {
+		err = addLocalDeviceSubif(vppCh, conf, data)
+		if err != nil {
+			undoLocalDevice(vppCh, conf, args, sharedDir, data)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validate the peer of an xconnect, exactly one of Interface and Pod.
+func validateXconnectConf(xconnectConf *types.XconnectConf) error {
+	if xconnectConf == nil || (xconnectConf.Interface == "") == (xconnectConf.Pod == "") {
+		return fmt.Errorf("ERROR: XconnectConf needs either Interface or Pod")
+	}
+	if xconnectConf.Pod != "" {
+		if strings.Count(xconnectConf.Pod, "/") > 1 {
+			return fmt.Errorf("ERROR: Invalid XconnectConf.Pod:%s", xconnectConf.Pod)
+		}
+		if xconnectConf.IfName == "" {
+			return fmt.Errorf("ERROR: XconnectConf.IfName must be provided with Pod")
+		}
+	}
+	return nil
+}
+
+// Namespace and name of the xconnect peer pod. Without a namespace, the
+// peer is in the namespace of the pod.
+func getXconnectPod(xconnectConf *types.XconnectConf, podNamespace string) (string, string) {
+	if namespace, name, found := strings.Cut(xconnectConf.Pod, "/"); found {
+		return namespace, name
+	}
+	return podNamespace, xconnectConf.Pod
+}
+
+// getXconnectPeer() - Find the interface to cross-connect to.
+//
+//	An uplink Interface must exist. A peer pod is looked up in the saved
+//	data of the xconnect attachments on the same VPP, and may not be
+//	attached yet. Interfaces of the pod in other networks are left alone,
+//	and the peer has to name this pod and interface as its own peer.
+func getXconnectPeer(vppCh vppinfra.ConnectionData, conf *types.NetConf, data *VppSavedData) (bool, interface_types.InterfaceIndex, error) {
+	xconnectConf := conf.HostConf.XconnectConf
+
+	if xconnectConf.Interface != "" {
+		found, swIfIndex, err := vppinterface.FindByName(vppCh.Ch, xconnectConf.Interface)
+		if err == nil && !found {
+			err = fmt.Errorf("ERROR: Xconnect interface %s not found", xconnectConf.Interface)
+		}
+		return found, swIfIndex, err
+	}
+
+	namespace, name := getXconnectPod(xconnectConf, data.PodNamespace)
+	peers, err := listAllVppConfig()
+	if err != nil {
+		return false, 0, err
+	}
+	for i := range peers {
+		peer := &peers[i]
+		if peer.PodNamespace != namespace || peer.PodName != name || peer.IfName != xconnectConf.IfName || peer.ApiSocket != data.ApiSocket {
+			continue
+		}
+		if peer.HostConf.NetType != "xconnect" {
+			return false, 0, fmt.Errorf("ERROR: Xconnect peer %s/%s %s not in an xconnect network", namespace, name, peer.IfName)
+		}
+		if !isXconnectPeer(peer, data) {
+			return false, 0, fmt.Errorf("ERROR: Xconnect peer %s/%s %s does not xconnect to %s/%s %s",
+				namespace, name, peer.IfName, data.PodNamespace, data.PodName, data.IfName)
+		}
+		found, err := resolveLocalDevice(vppCh, peer)
+		if err != nil || found {
+			return found, getNetworkSwIfIndex(peer), err
+		}
+	}
+
+	return false, 0, nil
+}
+
+// Whether the XconnectConf of a peer pod names the pod and interface of the
+// attachment, so both sides agree on the cross-connect.
+func isXconnectPeer(peer *VppSavedData, data *VppSavedData) bool {
+	xconnectConf := peer.HostConf.XconnectConf
+	if xconnectConf == nil || xconnectConf.Pod == "" {
+		return false
+	}
+
+	namespace, name := getXconnectPod(xconnectConf, peer.PodNamespace)
+	return namespace == data.PodNamespace && name == data.PodName && xconnectConf.IfName == data.IfName
+}
+
+// addLocalNetworkXconnect() - Cross-connect an interface with its peer, in
+//
+//	both directions. A peer pod not attached yet is not an error, the
+//	cross-connect is set up when the peer is added and finds this pod. A
+//	peer already cross-connected to another interface is left alone.
+func addLocalNetworkXconnect(vppCh vppinfra.ConnectionData, conf *types.NetConf, swIfIndex interface_types.InterfaceIndex, data *VppSavedData) error {
+	found, peerSwIfIndex, err := getXconnectPeer(vppCh, conf, data)
+	if err != nil {
+		return err
+	}
+	if !found {
+		logging.Infof("addLocalNetworkXconnect(vpp): Peer %s/%s not attached yet", conf.HostConf.XconnectConf.Pod, conf.HostConf.XconnectConf.IfName)
+		return nil
+	}
+
+	xconnects, err := vppxconnect.DumpXconnects(vppCh.Ch)
+	if err != nil {
+		return err
+	}
+	if txSwIfIndex, ok := xconnects[peerSwIfIndex]; ok && txSwIfIndex != swIfIndex {
+		return fmt.Errorf("ERROR: Xconnect peer %d already xconnected to %d", peerSwIfIndex, txSwIfIndex)
+	}
+
+	for _, rxTx := range [][2]interface_types.InterfaceIndex{{swIfIndex, peerSwIfIndex}, {peerSwIfIndex, swIfIndex}} {
+		err = vppxconnect.SetXconnect(vppCh.Ch, rxTx[0], rxTx[1], true)
+		if err != nil {
+			delLocalNetworkXconnect(vppCh, swIfIndex)
+			return fmt.Errorf("ERROR: Failed to xconnect %d to %d: %v", rxTx[0], rxTx[1], err)
+		}
+	}
+
+	return nil
+}
+
+// delLocalNetworkXconnect() - Remove the cross-connects from and to an
+//
+//	interface, so the peer is not left sending to a deleted interface.
+//	Failures are only logged.
+func delLocalNetworkXconnect(vppCh vppinfra.ConnectionData, swIfIndex interface_types.InterfaceIndex) {
+	xconnects, err := vppxconnect.DumpXconnects(vppCh.Ch)
+	if err != nil {
+		logging.Warningf("delLocalNetworkXconnect(): Unable to dump xconnects - %v", err)
+		return
+	}
+
+	for rx, tx := range xconnects {
+		if rx != swIfIndex && tx != swIfIndex {
+			continue
+		}
+		if err = vppxconnect.SetXconnect(vppCh.Ch, rx, tx, false); err != nil {
+			logging.Warningf("delLocalNetworkXconnect(): Unable to remove xconnect %d to %d - %v", rx, tx, err)
+		}
+	}
+}
+
+// delLocalDevice() - Delete the interface created by addLocalDevice(), and
+//
+//	release its FIB table.
+func delLocalDevice(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) error {
+	err := removeLocalDevice(vppCh, conf, args, sharedDir, data)
+
+	// The FIB table is released even if the interface is left behind, the
+	// saved data tracking it is gone.
+	delLocalNetworkVrf(vppCh, data)
+
+	return err
+}
+
+// removeLocalDevice() - Delete the interface created by addLocalDeviceMemif()
+//
+//	or addLocalDeviceVhost(), according to the HostConf.IfType. Its ACLs
+//	and VLAN sub-interface are deleted first, VPP doesn't delete them with
+//	the interface.
+func removeLocalDevice(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) error {
+	delLocalNetworkAcl(vppCh, data)
+
+	if data.SubIfSwIfIndex != 0 {
+		if err := vppinterface.DeleteSubif(vppCh.Ch, data.SubIfSwIfIndex); err != nil {
+			return logging.Errorf("removeLocalDevice(vpp): Error deleting sub-interface %d: %v", data.SubIfSwIfIndex, err)
+		}
+		data.SubIfSwIfIndex = 0
+	}
+
+	if conf.HostConf.IfType == "memif" {
+		return delLocalDeviceMemif(vppCh, conf, args, sharedDir, data)
+	} else if conf.HostConf.IfType == "vhostuser" {
+		return delLocalDeviceVhost(vppCh, conf, args, sharedDir, data)
+	}
+	return fmt.Errorf("ERROR: Unknown HostConf.Type:" + conf.HostConf.IfType)
+}
+
+// Remove the socketfile of an interface VPP no longer has.
+func cleanupLocalDeviceFiles(conf *types.NetConf, args *skel.CmdArgs, sharedDir string) {
+	var socketPath string
+
+	if conf.HostConf.IfType == "memif" {
+		socketPath = getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+	} else if conf.HostConf.IfType == "vhostuser" {
+		socketPath = getVhostSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+	} else {
+		return
+	}
+
+	if _, err := os.Stat(socketPath); err == nil {
+		_ = configdata.FileCleanup("", socketPath)
+	}
+}
+
+// Tag of the interface of an attachment. VPP tags are limited to 63
+// characters, so the container ID is shortened as for the socketfiles.
+func getInterfaceTag(containerID string, ifName string) string {
+	return fmt.Sprintf("usrspcni-%s-%s", containerID[:12], ifName)
+}
+
+// Tag of the VLAN sub-interface of an interface.
+func getSubifTag(tag string) string {
+	return tag + "-vlan"
+}
+
+// resolveLocalDevice() - Update the saved interface indexes from the tags
+//
+//	of the interfaces, they may have changed since ADD if VPP restarted.
+//	Returns false if VPP has no interface with the tag. If only the VLAN
+//	sub-interface is gone, the interface is still found and the saved
+//	sub-interface index is cleared. Data saved before interfaces were
+//	tagged is used as is.
+func resolveLocalDevice(vppCh vppinfra.ConnectionData, data *VppSavedData) (bool, error) {
+	if data.Tag == "" {
+		return true, nil
+	}
+
+	found, swIfIndex, err := vppinterface.FindByTag(vppCh.Ch, data.Tag)
+	if err != nil || !found {
+		return false, err
+	}
+	data.InterfaceSwIfIndex = swIfIndex
+
+	if data.SubIfSwIfIndex != 0 {
+		found, swIfIndex, err = vppinterface.FindByTag(vppCh.Ch, getSubifTag(data.Tag))
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			logging.Warningf("resolveLocalDevice(vpp): Sub-interface %s no longer in VPP", getSubifTag(data.Tag))
+		}
+		data.SubIfSwIfIndex = swIfIndex
+	}
+
+	return true, nil
+}
+
+// undoLocalDevice() - Roll back addLocalDeviceMemif() or addLocalDeviceVhost().
+//
+//	Used when a later step of AddOnHost() fails. Errors are only logged,
+//	the original error is the one reported.
+func undoLocalDevice(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, sharedDir string, data *VppSavedData) {
+	if err := delLocalDevice(vppCh, conf, args, sharedDir, data); err != nil {
+		logging.Debugf("undoLocalDevice(vpp): Unable to delete interface %d: %v", data.InterfaceSwIfIndex, err)
+	}
 }
@@ -3,19 +3,47 @@ package cnivpp
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
+	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
+	vppacl "github.com/intel/userspace-cni-network-plugin/cnivpp/api/acl"
+	vppbridge "github.com/intel/userspace-cni-network-plugin/cnivpp/api/bridge"
+	vppinfra "github.com/intel/userspace-cni-network-plugin/cnivpp/api/infra"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/acl"
+	interfaces "github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/ip"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/l2"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memclnt"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/memif"
+	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/vpe"
+	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+	"github.com/intel/userspace-cni-network-plugin/pkg/shareddir"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
 	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp"
+	"go.fd.io/govpp/adapter/mock"
+	"go.fd.io/govpp/api"
+	"go.fd.io/govpp/codec"
+	"go.fd.io/govpp/core"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
+func TestMain(m *testing.M) {
+	// Without VPP, fail at once instead of waiting for it to come back
+	vppinfra.SetConnect(govpp.Connect, 0)
+	os.Exit(m.Run())
+}
+
 func TestGetMemifSocketfileName(t *testing.T) {
 	t.Run("get Memif Socker File Name", func(t *testing.T) {
 		args := testdata.GetTestArgs()
@@ -35,6 +63,331 @@ func TestGetMemifSocketfileName(t *testing.T) {
 	})
 }
 
+func TestValidateMemifConf(t *testing.T) {
+	testCases := []struct {
+		name      string
+		memifConf types.MemifConf
+		expErr    string
+	}{
+		{
+			name: "use defaults",
+		},
+		{
+			name:      "valid settings",
+			memifConf: types.MemifConf{RxQueues: 4, TxQueues: 255, RingSize: 16384, BufferSize: 65535, Id: 7, Secret: "123456789012345678901234"},
+		},
+		{
+			name:      "fail with too many queues",
+			memifConf: types.MemifConf{RxQueues: 256},
+			expErr:    "ERROR: Invalid MemifConf.RxQueues:256",
+		},
+		{
+			name:      "fail with negative queues",
+			memifConf: types.MemifConf{TxQueues: -1},
+			expErr:    "ERROR: Invalid MemifConf.TxQueues:-1",
+		},
+		{
+			name:      "fail with ring size not a power of 2",
+			memifConf: types.MemifConf{RingSize: 1000},
+			expErr:    "ERROR: Invalid MemifConf.RingSize:1000",
+		},
+		{
+			name:      "fail with too large ring size",
+			memifConf: types.MemifConf{RingSize: 32768},
+			expErr:    "ERROR: Invalid MemifConf.RingSize:32768",
+		},
+		{
+			name:      "fail with too large buffer size",
+			memifConf: types.MemifConf{BufferSize: 65536},
+			expErr:    "ERROR: Invalid MemifConf.BufferSize:65536",
+		},
+		{
+			name:      "fail with negative Id",
+			memifConf: types.MemifConf{Id: -1},
+			expErr:    "ERROR: Invalid MemifConf.Id:-1",
+		},
+		{
+			name:      "fail with too long secret",
+			memifConf: types.MemifConf{Secret: "1234567890123456789012345"},
+			expErr:    "ERROR: MemifConf.Secret longer than 24 characters",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMemifConf(&tc.memifConf)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestGetVhostSocketfileName(t *testing.T) {
+	t.Run("get Vhost Socket File Name", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+
+		sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cnivpp-")
+		require.NoError(t, dirErr, "Can't create temporary directory")
+		defer os.RemoveAll(sharedDir)
+
+		conf := &types.NetConf{}
+		vhostSockFileName := getVhostSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+		assert.Equal(t, filepath.Join(sharedDir, fmt.Sprintf("%s-%s", args.ContainerID[:12], args.IfName)), vhostSockFileName, "Unexpected error")
+		assert.Equal(t, fmt.Sprintf("%s-%s", args.ContainerID[:12], args.IfName), conf.HostConf.VhostConf.Socketfile, "Socketfile not set for container")
+
+		conf.HostConf.VhostConf.Socketfile = "socketFile.sock"
+
+		vhostSockFileName = getVhostSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+		assert.Equal(t, filepath.Join(sharedDir, conf.HostConf.VhostConf.Socketfile), vhostSockFileName, "Unexpected error")
+	})
+
+	t.Run("shorten long shared dir of pod", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+		sharedDir := "/var/lib/kubelet/pods/0123456789abcdef0123456789abcdef/volumes/kubernetes.io~empty-dir/shared-dir"
+
+		vhostSockFileName := getVhostSocketfileName(&types.NetConf{}, sharedDir, args.ContainerID, args.IfName)
+		assert.Equal(t, filepath.Join(shareddir.DefaultHostVhostuserBaseDir, "0123456789abcdef0123456789abcdef",
+			fmt.Sprintf("%s-%s", args.ContainerID[:12], args.IfName)), vhostSockFileName, "Shared dir not shortened")
+		assert.Less(t, len(vhostSockFileName), 108, "Socketfile path too long")
+	})
+}
+
+func TestGetNetworkSwIfIndex(t *testing.T) {
+	t.Run("get interface in Local Network", func(t *testing.T) {
+		data := &VppSavedData{InterfaceSwIfIndex: 3}
+		assert.Equal(t, data.InterfaceSwIfIndex, getNetworkSwIfIndex(data), "Unexpected interface")
+
+		data.SubIfSwIfIndex = 4
+		assert.Equal(t, data.SubIfSwIfIndex, getNetworkSwIfIndex(data), "Unexpected VLAN sub-interface")
+	})
+}
+
+func TestGetL3Routes(t *testing.T) {
+	parseCIDR := func(address string) net.IPNet {
+		ip, ipNet, err := net.ParseCIDR(address)
+		require.NoError(t, err, "Can't parse address")
+		ipNet.IP = ip
+		return *ipNet
+	}
+	testCases := []struct {
+		name      string
+		ipResult  *current.Result
+		expRoutes []string
+	}{
+		{
+			name: "host route to each pod IP",
+			ipResult: &current.Result{IPs: []*current.IPConfig{
+				{Address: parseCIDR("10.56.217.131/24")},
+				{Address: parseCIDR("fd00::5/64")},
+			}},
+			expRoutes: []string{
+				"10.56.217.131/32 via 10.56.217.131",
+				"fd00::5/128 via fd00::5",
+			},
+		},
+		{
+			name: "IPAM routes via their gateway or the IPAM gateway",
+			ipResult: &current.Result{
+				IPs: []*current.IPConfig{
+					{Address: parseCIDR("10.56.217.131/24"), Gateway: net.ParseIP("10.56.217.1")},
+				},
+				Routes: []*cnitypes.Route{
+					{Dst: parseCIDR("0.0.0.0/0")},
+					{Dst: parseCIDR("10.10.0.0/16"), GW: net.ParseIP("10.56.217.2")},
+					{Dst: parseCIDR("fd01::/64"), GW: net.ParseIP("fd00::1")},
+				},
+			},
+			expRoutes: []string{
+				"10.56.217.131/32 via 10.56.217.131",
+				"0.0.0.0/0 via 10.56.217.1",
+				"10.10.0.0/16 via 10.56.217.2",
+			},
+		},
+		{
+			name: "attached IPAM route without gateway",
+			ipResult: &current.Result{
+				IPs:    []*current.IPConfig{{Address: parseCIDR("fd00::5/64")}},
+				Routes: []*cnitypes.Route{{Dst: parseCIDR("fd01::/64")}},
+			},
+			expRoutes: []string{
+				"fd00::5/128 via fd00::5",
+				"fd01::/64 via <nil>",
+			},
+		},
+		{
+			name: "skip routes of other families and subnets",
+			ipResult: &current.Result{
+				IPs: []*current.IPConfig{
+					{Address: parseCIDR("192.168.1.10/24"), Gateway: net.ParseIP("192.168.1.1")},
+				},
+				Routes: []*cnitypes.Route{
+					{Dst: parseCIDR("0.0.0.0/0"), GW: net.ParseIP("10.244.0.1")},
+					{Dst: parseCIDR("::/0")},
+					{Dst: parseCIDR("10.10.0.0/16"), GW: net.ParseIP("192.168.1.254")},
+				},
+			},
+			expRoutes: []string{
+				"192.168.1.10/32 via 192.168.1.10",
+				"10.10.0.0/16 via 192.168.1.254",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			routes := []string{}
+			for _, route := range getL3Routes(tc.ipResult.IPs, tc.ipResult.Routes) {
+				routes = append(routes, route.Dst.String()+" via "+route.GW.String())
+			}
+			assert.Equal(t, tc.expRoutes, routes, "Unexpected routes")
+		})
+	}
+}
+
+func TestAddLocalNetworkAddresses(t *testing.T) {
+	t.Run("only add addresses and routes of the interface from chained result", func(t *testing.T) {
+		eth0IP := &current.IPConfig{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("10.244.0.5"), Mask: net.CIDRMask(24, 32)},
+			Gateway: net.ParseIP("10.244.0.1")}
+		net1IP := &current.IPConfig{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+			Gateway: net.ParseIP("192.168.1.1")}
+		ipResult := &current.Result{
+			Interfaces: []*current.Interface{{Name: "eth0"}, {Name: "net1"}},
+			IPs:        []*current.IPConfig{eth0IP, net1IP},
+			Routes: []*cnitypes.Route{
+				{Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, GW: net.ParseIP("10.244.0.1")},
+				{Dst: net.IPNet{IP: net.ParseIP("10.10.0.0"), Mask: net.CIDRMask(16, 32)}},
+			},
+		}
+
+		requests := []string{}
+		vppCh := openMockVppCh(t, map[string]api.Message{
+			"sw_interface_add_del_address": &interfaces.SwInterfaceAddDelAddressReply{},
+			"ip_route_add_del":             &ip.IPRouteAddDelReply{},
+		}, &requests)
+
+		data := &VppSavedData{}
+		require.NoError(t, addLocalNetworkAddresses(vppCh, "net1", 3, ipResult, data), "Unexpected error")
+		assert.Equal(t, []string{"sw_interface_add_del_address", "ip_route_add_del", "ip_route_add_del"}, requests, "Unexpected requests")
+		assert.Equal(t, []*current.IPConfig{net1IP}, data.IPs, "Unexpected addresses saved")
+		routes := []string{}
+		for _, route := range data.Routes {
+			routes = append(routes, route.Dst.String()+" via "+route.GW.String())
+		}
+		assert.Equal(t, []string{"192.168.1.10/32 via 192.168.1.10", "10.10.0.0/16 via 192.168.1.1"}, routes, "Unexpected routes saved")
+	})
+}
+
+// Open a channel to a mock VPP, which answers the requests named in replies
+// and records the name of each request answered.
+func openMockVppCh(t *testing.T, replies map[string]api.Message, requests *[]string) vppinfra.ConnectionData {
+	mockVpp := mock.NewVppAdapter()
+	mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+		reply, ok := replies[request.MsgName]
+		if !ok {
+			return nil, 0, false
+		}
+		*requests = append(*requests, request.MsgName)
+
+		msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+		data, err := mockVpp.ReplyBytes(request, reply)
+		require.NoError(t, err, "Can't encode reply")
+		return data, msgID, true
+	})
+
+	conn, err := core.Connect(mockVpp)
+	require.NoError(t, err, "Can't connect to mock VPP")
+	t.Cleanup(conn.Disconnect)
+
+	ch, err := conn.NewAPIChannel()
+	require.NoError(t, err, "Can't open channel to mock VPP")
+	t.Cleanup(ch.Close)
+	ch.SetReplyTimeout(time.Second)
+
+	return vppinfra.ConnectionData{Ch: ch}
+}
+
+func TestAddDelLocalNetworkVrf(t *testing.T) {
+	t.Run("create VRF with first interface and delete with last", func(t *testing.T) {
+		tablePath := filepath.Join(annotations.DefaultLocalCNIDir, fibTableTable+".json")
+		tableData, tableErr := os.ReadFile(tablePath)
+		defer func() {
+			if tableErr == nil {
+				_ = os.WriteFile(tablePath, tableData, 0600)
+			} else {
+				_ = os.Remove(tablePath)
+			}
+		}()
+		_ = os.Remove(tablePath)
+
+		requests := []string{}
+		vppCh := openMockVppCh(t, map[string]api.Message{
+			"ip_table_add_del":       &ip.IPTableAddDelReply{},
+			"sw_interface_set_table": &interfaces.SwInterfaceSetTableReply{},
+		}, &requests)
+		conf := &types.NetConf{}
+
+		// default table 0 is left alone
+		data1 := &VppSavedData{}
+		require.NoError(t, addLocalNetworkVrf(vppCh, conf, 3, data1), "Can't bind to default VRF")
+		delLocalNetworkVrf(vppCh, data1)
+		assert.Empty(t, requests, "Default VRF changed")
+
+		conf.VppConf.Vrf = 7
+		require.NoError(t, addLocalNetworkVrf(vppCh, conf, 3, data1), "Can't bind to VRF")
+		assert.Equal(t, uint32(7), data1.Vrf, "VRF not saved")
+		data2 := &VppSavedData{}
+		require.NoError(t, addLocalNetworkVrf(vppCh, conf, 4, data2), "Can't bind to VRF")
+		assert.Equal(t, []string{"ip_table_add_del", "sw_interface_set_table", "ip_table_add_del", "sw_interface_set_table",
+			"ip_table_add_del", "sw_interface_set_table", "ip_table_add_del", "sw_interface_set_table"}, requests, "Unexpected requests")
+
+		// the table is only deleted with its last interface
+		requests = requests[:0]
+		delLocalNetworkVrf(vppCh, data1)
+		assert.Empty(t, requests, "VRF deleted while in use")
+		assert.Zero(t, data1.Vrf, "VRF not cleared")
+		delLocalNetworkVrf(vppCh, data2)
+		assert.Equal(t, []string{"ip_table_add_del", "ip_table_add_del"}, requests, "VRF not deleted")
+	})
+
+	t.Run("count interfaces per VPP instance", func(t *testing.T) {
+		apiSocket := "/run/vpp/test-api.sock"
+		for _, tableName := range []string{fibTableTable, getNameTable(fibTableTable, apiSocket)} {
+			tablePath := filepath.Join(annotations.DefaultLocalCNIDir, tableName+".json")
+			tableData, tableErr := os.ReadFile(tablePath)
+			defer func() {
+				if tableErr == nil {
+					_ = os.WriteFile(tablePath, tableData, 0600)
+				} else {
+					_ = os.Remove(tablePath)
+				}
+			}()
+			_ = os.Remove(tablePath)
+		}
+
+		requests := []string{}
+		vppCh := openMockVppCh(t, map[string]api.Message{
+			"ip_table_add_del":       &ip.IPTableAddDelReply{},
+			"sw_interface_set_table": &interfaces.SwInterfaceSetTableReply{},
+		}, &requests)
+		conf := &types.NetConf{VppConf: types.VppConf{Vrf: 7}}
+
+		data1 := &VppSavedData{}
+		require.NoError(t, addLocalNetworkVrf(vppCh, conf, 3, data1), "Can't bind to VRF")
+		data2 := &VppSavedData{ApiSocket: apiSocket}
+		require.NoError(t, addLocalNetworkVrf(vppCh, conf, 3, data2), "Can't bind to VRF")
+
+		// each instance deletes its own table with its last interface
+		requests = requests[:0]
+		delLocalNetworkVrf(vppCh, data1)
+		assert.Equal(t, []string{"ip_table_add_del", "ip_table_add_del"}, requests, "VRF not deleted")
+		requests = requests[:0]
+		delLocalNetworkVrf(vppCh, data2)
+		assert.Equal(t, []string{"ip_table_add_del", "ip_table_add_del"}, requests, "VRF not deleted")
+	})
+}
+
 func TestAddOnContainer(t *testing.T) {
 	t.Run("save container data to file", func(t *testing.T) {
 		var result *current.Result
@@ -160,6 +513,64 @@ func TestAddOnHost(t *testing.T) {
 					}}},
 			expErr: errors.New("ERROR: NetType must be provided"),
 		},
+		{
+			name: "vhost-user server interface",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "vhostuser", NetType: "interface",
+					VhostConf: types.VhostConf{Mode: "server"}}},
+			expErr: nil,
+		},
+		{
+			name: "vhost-user client interface in bridge",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "vhostuser", NetType: "bridge",
+					BridgeConf: types.BridgeConf{
+						BridgeName: "12346",
+					},
+					VhostConf: types.VhostConf{Mode: "client"}}},
+			expErr: nil,
+		},
+		{
+			name: "Invalid Vhost Mode",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "vhostuser", NetType: "interface",
+					VhostConf: types.VhostConf{Mode: "bad"}}},
+			expErr: errors.New("ERROR: Invalid Vhost Mode"),
+		},
+		{
+			name: "memif in bridge on VLAN sub-interface",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "memif", NetType: "bridge",
+					BridgeConf: types.BridgeConf{
+						BridgeName: "12347",
+						VlanId:     100,
+					},
+					MemifConf: types.MemifConf{
+						Role: "master", // Role of memif: master|slave
+						Mode: "ethernet",
+					}}},
+			expErr: nil,
+		},
+		{
+			name: "Invalid VlanId",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "memif", NetType: "bridge",
+					BridgeConf: types.BridgeConf{
+						BridgeName: "12347",
+						VlanId:     4095,
+					}}},
+			expErr: errors.New("ERROR: Invalid BridgeConf.VlanId:4095"),
+		},
+		{
+			name: "Trunks not supported",
+			netConf: &types.NetConf{
+				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "memif", NetType: "bridge",
+					BridgeConf: types.BridgeConf{
+						BridgeName: "12347",
+						Trunks:     []int{100, 200},
+					}}},
+			expErr: errors.New("ERROR: BridgeConf.Trunks not supported by VPP"),
+		},
 		{
 			name: "interface slave and ip mode",
 			netConf: &types.NetConf{
@@ -190,7 +601,11 @@ func TestAddOnHost(t *testing.T) {
 				// on success there shall be saved ovs data
 				var data VppSavedData
 				assert.NoError(t, LoadVppConfig(tc.netConf, args, &data))
-				assert.NotEmpty(t, data.MemifSocketId)
+				if tc.netConf.HostConf.IfType == "memif" {
+					assert.NotEmpty(t, data.MemifSocketId)
+				} else {
+					assert.NotEmpty(t, tc.netConf.HostConf.VhostConf.Socketfile)
+				}
 			} else {
 				require.Error(t, err, "Unexpected result")
 				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected result")
@@ -232,7 +647,7 @@ func TestDelFromHost(t *testing.T) {
 			expErr: fmt.Errorf("ERROR: Unknown HostConf.Type"),
 		},
 		{
-			name: "Delete Bridge with IfType set to vhostUser",
+			name: "Delete Bridge with IfType set to vhostuser",
 			netConf: &types.NetConf{
 				HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "vhostuser", NetType: "bridge",
 					VhostConf: types.VhostConf{Mode: "client"},
@@ -244,7 +659,7 @@ func TestDelFromHost(t *testing.T) {
 						Role: "master", // Role of memif: master|slave
 						Mode: "ip",     // Mode of memif: ip|ethernet|inject-punt
 					}}},
-			expErr: fmt.Errorf("GOOD: Found HostConf.Type:vhostuser"),
+			expErr: nil,
 		},
 	}
 	for _, tc := range testCases {
@@ -271,3 +686,880 @@ func TestDelFromHost(t *testing.T) {
 		})
 	}
 }
+
+func TestDelFromHostBridgeFailure(t *testing.T) {
+	t.Run("delete interface when leaving bridge fails", func(t *testing.T) {
+		apiSocket := "/run/vpp/test-del-api.sock"
+		bridgeTable := getNameTable(bridgeDomainTable, apiSocket)
+		defer func() {
+			_ = os.Remove(filepath.Join(annotations.DefaultLocalCNIDir, bridgeTable+".json"))
+			_ = os.Remove(filepath.Join(annotations.DefaultLocalCNIDir, bridgeTable+".lock"))
+		}()
+
+		requests := []string{}
+		mockVpp := mock.NewVppAdapter()
+		mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+			var reply api.Message
+			pingID, _ := mockVpp.GetMsgID((&memclnt.ControlPing{}).GetMessageName(), "")
+			switch {
+			case request.MsgName == "sw_interface_set_l2_bridge":
+				reply = &l2.SwInterfaceSetL2BridgeReply{Retval: -2}
+			case request.MsgName == "memif_dump":
+				reply = &memif.MemifDetails{SwIfIndex: 3}
+			case request.MsgName == "memif_delete":
+				reply = &memif.MemifDeleteReply{}
+			case request.MsgID == pingID:
+				reply = &memclnt.ControlPingReply{}
+			default:
+				return nil, 0, false
+			}
+			if request.MsgID != pingID {
+				requests = append(requests, request.MsgName)
+			}
+
+			msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+			data, err := mockVpp.ReplyBytes(request, reply)
+			require.NoError(t, err, "Can't encode reply")
+			return data, msgID, true
+		})
+		defer vppinfra.SetConnect(func(string) (*core.Connection, error) {
+			return core.Connect(mockVpp)
+		}, 0)()
+
+		args := testdata.GetTestArgs()
+		sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cnivpp-")
+		require.NoError(t, dirErr, "Can't create temporary directory")
+		defer os.RemoveAll(sharedDir)
+
+		conf := &types.NetConf{HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "memif", NetType: "bridge",
+			BridgeConf: types.BridgeConf{BridgeName: "tenant-a"}}}
+		bridgeDomain, err := acquireNameId(bridgeTable, "tenant-a", firstNamedBridgeDomain, maxBridgeDomain)
+		require.NoError(t, err, "Can't map BridgeName")
+		data := VppSavedData{InterfaceSwIfIndex: 3, BridgeName: "tenant-a", BridgeDomain: bridgeDomain, ApiSocket: apiSocket}
+		require.NoError(t, SaveVppConfig(conf, args, &data), "Can't save data")
+		socketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+		require.NoError(t, os.WriteFile(socketPath, nil, 0600), "Can't create socketfile")
+
+		err = CniVpp{}.DelFromHost(conf, args, sharedDir)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []string{"sw_interface_set_l2_bridge", "memif_dump", "memif_delete"}, requests, "Interface not deleted")
+		assert.NoFileExists(t, socketPath, "Socketfile not removed")
+
+		removed, err := releaseNameId(bridgeTable, "tenant-a")
+		require.NoError(t, err, "Can't release BridgeName")
+		assert.False(t, removed, "Bridge Domain ID not released")
+	})
+}
+
+func TestDelFromHostSubifLost(t *testing.T) {
+	t.Run("delete interface when sub-interface lost", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+		tag := getInterfaceTag(args.ContainerID, args.IfName)
+
+		requests := []string{}
+		mockVpp := mock.NewVppAdapter()
+		mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+			var reply api.Message
+			pingID, _ := mockVpp.GetMsgID((&memclnt.ControlPing{}).GetMessageName(), "")
+			dumpID, _ := mockVpp.GetMsgID((&interfaces.SwInterfaceDump{}).GetMessageName(), (&interfaces.SwInterfaceDump{}).GetCrcString())
+			switch {
+			case request.MsgID == dumpID:
+				request.MsgName = (&interfaces.SwInterfaceDump{}).GetMessageName()
+				reply = &interfaces.SwInterfaceDetails{SwIfIndex: 7, Tag: tag}
+			case request.MsgName == "memif_dump":
+				reply = &memif.MemifDetails{SwIfIndex: 7}
+			case request.MsgName == "memif_delete":
+				reply = &memif.MemifDeleteReply{}
+			case request.MsgID == pingID:
+				reply = &memclnt.ControlPingReply{}
+			default:
+				return nil, 0, false
+			}
+			if request.MsgID != pingID {
+				requests = append(requests, request.MsgName)
+			}
+
+			msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+			data, err := mockVpp.ReplyBytes(request, reply)
+			require.NoError(t, err, "Can't encode reply")
+			return data, msgID, true
+		})
+		defer vppinfra.SetConnect(func(string) (*core.Connection, error) {
+			return core.Connect(mockVpp)
+		}, 0)()
+
+		sharedDir, dirErr := os.MkdirTemp("/tmp", "test-cnivpp-")
+		require.NoError(t, dirErr, "Can't create temporary directory")
+		defer os.RemoveAll(sharedDir)
+
+		conf := &types.NetConf{HostConf: types.UserSpaceConf{Engine: "vpp", IfType: "memif", NetType: "interface",
+			BridgeConf: types.BridgeConf{VlanId: 100}}}
+		data := VppSavedData{InterfaceSwIfIndex: 3, SubIfSwIfIndex: 4, Tag: tag, ApiSocket: "/run/vpp/test-del-api.sock"}
+		require.NoError(t, SaveVppConfig(conf, args, &data), "Can't save data")
+		socketPath := getMemifSocketfileName(conf, sharedDir, args.ContainerID, args.IfName)
+		require.NoError(t, os.WriteFile(socketPath, nil, 0600), "Can't create socketfile")
+
+		err := CniVpp{}.DelFromHost(conf, args, sharedDir)
+		assert.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []string{"sw_interface_dump", "sw_interface_dump", "memif_dump", "memif_delete"}, requests, "Interface not deleted")
+		assert.NoFileExists(t, socketPath, "Socketfile not removed")
+	})
+}
+
+func TestGetBridgeName(t *testing.T) {
+	testCases := []struct {
+		name       string
+		bridgeName string
+		bridgeId   int
+		expName    string
+	}{
+		{
+			name: "default bridge domain",
+		},
+		{
+			name:       "use BridgeName",
+			bridgeName: "tenant-a",
+			expName:    "tenant-a",
+		},
+		{
+			name:     "use DEPRECATED BridgeId",
+			bridgeId: 5,
+			expName:  "5",
+		},
+		{
+			name:       "BridgeName overrides BridgeId",
+			bridgeName: "tenant-a",
+			bridgeId:   5,
+			expName:    "tenant-a",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &types.NetConf{}
+			conf.HostConf.BridgeConf.BridgeName = tc.bridgeName
+			conf.HostConf.BridgeConf.BridgeId = tc.bridgeId
+			assert.Equal(t, tc.expName, getBridgeName(conf), "Unexpected bridge name")
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		down     []string
+		expError uint
+	}{
+		{
+			name: "all VPP instances answer",
+		},
+		{
+			name:     "limited connectivity with VPP instance down",
+			down:     []string{"/run/vpp-numa1/api.sock"},
+			expError: types.ErrLimitedConnectivity,
+		},
+		{
+			name:     "not available with all VPP instances down",
+			down:     []string{"/run/vpp/api.sock", "/run/vpp-numa0/api.sock", "/run/vpp-numa1/api.sock"},
+			expError: types.ErrPluginNotAvailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer vppinfra.SetConnect(func(apiSocket string) (*core.Connection, error) {
+				if slices.Contains(tc.down, apiSocket) {
+					return nil, errors.New("connection refused")
+				}
+				mockVpp := mock.NewVppAdapter()
+				mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+					if request.MsgName != "show_version" {
+						return nil, 0, false
+					}
+					reply := &vpe.ShowVersionReply{Version: "24.02"}
+					msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+					data, err := mockVpp.ReplyBytes(request, reply)
+					require.NoError(t, err, "Can't encode reply")
+					return data, msgID, true
+				})
+				return core.Connect(mockVpp)
+			}, 0)()
+
+			conf := &types.NetConf{VppConf: types.VppConf{ApiSocket: "/run/vpp/api.sock",
+				NumaApiSockets: map[string]string{"0": "/run/vpp-numa0/api.sock", "1": "/run/vpp-numa1/api.sock"}}}
+			err := CniVpp{}.Status(conf)
+			if tc.expError == 0 {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				var cniErr *cnitypes.Error
+				require.ErrorAs(t, err, &cniErr, "CNI error was expected")
+				assert.Equal(t, tc.expError, cniErr.Code, "Unexpected error code")
+			}
+		})
+	}
+}
+
+func TestGetApiSocket(t *testing.T) {
+	numaApiSockets := map[string]string{
+		"0": "/run/vpp0/api.sock",
+		"1": "/run/vpp1/api.sock",
+	}
+	testCases := []struct {
+		name      string
+		vppConf   types.VppConf
+		numaNode  string
+		noPod     bool
+		expSocket string
+		expErr    string
+	}{
+		{
+			name:      "use default socket",
+			expSocket: "",
+		},
+		{
+			name:      "use configured socket",
+			vppConf:   types.VppConf{ApiSocket: "/run/vpp/custom.sock"},
+			numaNode:  "1",
+			expSocket: "/run/vpp/custom.sock",
+		},
+		{
+			name:      "use socket of pod NUMA node",
+			vppConf:   types.VppConf{ApiSocket: "/run/vpp/custom.sock", NumaApiSockets: numaApiSockets},
+			numaNode:  "1",
+			expSocket: "/run/vpp1/api.sock",
+		},
+		{
+			name:      "use configured socket without NUMA node",
+			vppConf:   types.VppConf{ApiSocket: "/run/vpp/custom.sock", NumaApiSockets: numaApiSockets},
+			expSocket: "/run/vpp/custom.sock",
+		},
+		{
+			name:      "use configured socket without pod",
+			vppConf:   types.VppConf{ApiSocket: "/run/vpp/custom.sock", NumaApiSockets: numaApiSockets},
+			numaNode:  "1",
+			noPod:     true,
+			expSocket: "/run/vpp/custom.sock",
+		},
+		{
+			name:     "fail with unknown NUMA node",
+			vppConf:  types.VppConf{NumaApiSockets: numaApiSockets},
+			numaNode: "2",
+			expErr:   "ERROR: No VPP API socket for NUMA node 2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := testdata.GetTestArgs()
+			pod := testdata.GetTestPod("/tmp")
+			args.Args = fmt.Sprintf("K8S_POD_NAME=%s;K8S_POD_NAMESPACE=%s", pod.Name, pod.Namespace)
+			if tc.numaNode != "" {
+				pod.Annotations = map[string]string{annotations.AnnotKeyUsrspNumaNode: tc.numaNode}
+			}
+			kubeClient := fake.NewSimpleClientset(pod)
+			if tc.noPod {
+				kubeClient = fake.NewSimpleClientset()
+			}
+			conf := &types.NetConf{VppConf: tc.vppConf}
+
+			apiSocket, err := getApiSocket(conf, args, kubeClient)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, tc.expSocket, apiSocket, "Unexpected API socket")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestGetApiSockets(t *testing.T) {
+	t.Run("list each API socket once", func(t *testing.T) {
+		conf := &types.NetConf{VppConf: types.VppConf{
+			ApiSocket: "/run/vpp0/api.sock",
+			NumaApiSockets: map[string]string{
+				"0": "/run/vpp0/api.sock",
+				"1": "/run/vpp1/api.sock",
+			},
+		}}
+		assert.Equal(t, []string{"/run/vpp0/api.sock", "/run/vpp1/api.sock"}, getApiSockets(conf), "Unexpected API sockets")
+	})
+}
+
+func TestResolveLocalDevice(t *testing.T) {
+	tag := getInterfaceTag("0123456789abcdef", "net1")
+	interfaceDetails := []api.Message{
+		&interfaces.SwInterfaceDetails{SwIfIndex: 0, InterfaceName: "local0"},
+		&interfaces.SwInterfaceDetails{SwIfIndex: 7, Tag: tag},
+		&interfaces.SwInterfaceDetails{SwIfIndex: 8, Tag: getSubifTag(tag)},
+	}
+	testCases := []struct {
+		name     string
+		data     VppSavedData
+		dumps    int
+		details  []api.Message
+		expFound bool
+		expData  VppSavedData
+	}{
+		{
+			name:     "use index saved without tag",
+			data:     VppSavedData{InterfaceSwIfIndex: 3},
+			expFound: true,
+			expData:  VppSavedData{InterfaceSwIfIndex: 3},
+		},
+		{
+			name:     "resolve interface by tag",
+			data:     VppSavedData{InterfaceSwIfIndex: 3, Tag: tag},
+			dumps:    1,
+			details:  interfaceDetails,
+			expFound: true,
+			expData:  VppSavedData{InterfaceSwIfIndex: 7, Tag: tag},
+		},
+		{
+			name:     "resolve interface and sub-interface by tag",
+			data:     VppSavedData{InterfaceSwIfIndex: 3, SubIfSwIfIndex: 4, Tag: tag},
+			dumps:    2,
+			details:  interfaceDetails,
+			expFound: true,
+			expData:  VppSavedData{InterfaceSwIfIndex: 7, SubIfSwIfIndex: 8, Tag: tag},
+		},
+		{
+			name:     "keep interface with sub-interface lost",
+			data:     VppSavedData{InterfaceSwIfIndex: 3, SubIfSwIfIndex: 4, Tag: tag},
+			dumps:    2,
+			details:  interfaceDetails[:2],
+			expFound: true,
+			expData:  VppSavedData{InterfaceSwIfIndex: 7, Tag: tag},
+		},
+		{
+			name:    "interface lost with VPP restart",
+			data:    VppSavedData{InterfaceSwIfIndex: 3, Tag: tag},
+			dumps:   1,
+			details: interfaceDetails[:1],
+			expData: VppSavedData{InterfaceSwIfIndex: 3, Tag: tag},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockVpp := mock.NewVppAdapter()
+			conn, err := core.Connect(mockVpp)
+			require.NoError(t, err, "Can't connect to mock VPP")
+			defer conn.Disconnect()
+			ch, err := conn.NewAPIChannel()
+			require.NoError(t, err, "Can't open channel to mock VPP")
+			defer ch.Close()
+
+			for i := 0; i < tc.dumps; i++ {
+				mockVpp.MockReply(tc.details...)
+				mockVpp.MockReply(&memclnt.ControlPingReply{})
+			}
+
+			data := tc.data
+			found, err := resolveLocalDevice(vppinfra.ConnectionData{Ch: ch}, &data)
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expFound, found, "Unexpected result")
+			assert.Equal(t, tc.expData, data, "Unexpected saved data")
+		})
+	}
+}
+
+func TestGetInterfaceTag(t *testing.T) {
+	t.Run("tag fits VPP tag size", func(t *testing.T) {
+		tag := getInterfaceTag("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "net1-longname")
+		assert.Equal(t, "usrspcni-0123456789ab-net1-longname", tag, "Unexpected tag")
+		assert.LessOrEqual(t, len(getSubifTag(tag)), 63, "Tag too long")
+	})
+}
+
+func TestValidateXconnectConf(t *testing.T) {
+	testCases := []struct {
+		name         string
+		xconnectConf *types.XconnectConf
+		expErr       string
+	}{
+		{
+			name:         "uplink interface",
+			xconnectConf: &types.XconnectConf{Interface: "TenGigabitEthernet5/0/0"},
+		},
+		{
+			name:         "pod in same namespace",
+			xconnectConf: &types.XconnectConf{Pod: "firewall", IfName: "net2"},
+		},
+		{
+			name:         "pod in other namespace",
+			xconnectConf: &types.XconnectConf{Pod: "sfc/firewall", IfName: "net2"},
+		},
+		{
+			name:   "fail without XconnectConf",
+			expErr: "ERROR: XconnectConf needs either Interface or Pod",
+		},
+		{
+			name:         "fail without peer",
+			xconnectConf: &types.XconnectConf{},
+			expErr:       "ERROR: XconnectConf needs either Interface or Pod",
+		},
+		{
+			name:         "fail with both peers",
+			xconnectConf: &types.XconnectConf{Interface: "TenGigabitEthernet5/0/0", Pod: "firewall", IfName: "net2"},
+			expErr:       "ERROR: XconnectConf needs either Interface or Pod",
+		},
+		{
+			name:         "fail with pod without IfName",
+			xconnectConf: &types.XconnectConf{Pod: "firewall"},
+			expErr:       "ERROR: XconnectConf.IfName must be provided with Pod",
+		},
+		{
+			name:         "fail with invalid pod",
+			xconnectConf: &types.XconnectConf{Pod: "sfc/firewall/0", IfName: "net2"},
+			expErr:       "ERROR: Invalid XconnectConf.Pod:sfc/firewall/0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateXconnectConf(tc.xconnectConf)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestAddDelLocalNetworkXconnect(t *testing.T) {
+	testCases := []struct {
+		name         string
+		peerPod      string
+		peerNetType  string
+		peerXconnect *types.XconnectConf
+		uplink       string
+		xconnected   [2]interface_types.InterfaceIndex
+		expRequests  []l2.SwInterfaceSetL2Xconnect
+		expErr       string
+	}{
+		{
+			name:    "xconnect pod in same namespace",
+			peerPod: "firewall",
+			expRequests: []l2.SwInterfaceSetL2Xconnect{
+				{RxSwIfIndex: 3, TxSwIfIndex: 5, Enable: true},
+				{RxSwIfIndex: 5, TxSwIfIndex: 3, Enable: true},
+			},
+		},
+		{
+			name:    "xconnect pod in other namespace",
+			peerPod: "sfc/firewall",
+			expRequests: []l2.SwInterfaceSetL2Xconnect{
+				{RxSwIfIndex: 3, TxSwIfIndex: 5, Enable: true},
+				{RxSwIfIndex: 5, TxSwIfIndex: 3, Enable: true},
+			},
+		},
+		{
+			name:        "wait for pod not attached yet",
+			peerPod:     "sfc/router",
+			expRequests: []l2.SwInterfaceSetL2Xconnect{},
+		},
+		{
+			name:        "fail with pod in other network",
+			peerPod:     "firewall",
+			peerNetType: "bridge",
+			expRequests: []l2.SwInterfaceSetL2Xconnect{},
+			expErr:      "ERROR: Xconnect peer sfc/firewall net2 not in an xconnect network",
+		},
+		{
+			name:         "fail with pod xconnecting to other pod",
+			peerPod:      "firewall",
+			peerXconnect: &types.XconnectConf{Pod: "router", IfName: "net1"},
+			expRequests:  []l2.SwInterfaceSetL2Xconnect{},
+			expErr:       "ERROR: Xconnect peer sfc/firewall net2 does not xconnect to sfc/classifier net1",
+		},
+		{
+			name:         "fail with pod xconnecting to other interface",
+			peerPod:      "firewall",
+			peerXconnect: &types.XconnectConf{Pod: "sfc/classifier", IfName: "net3"},
+			expRequests:  []l2.SwInterfaceSetL2Xconnect{},
+			expErr:       "ERROR: Xconnect peer sfc/firewall net2 does not xconnect to sfc/classifier net1",
+		},
+		{
+			name:        "fail with pod already xconnected",
+			peerPod:     "firewall",
+			xconnected:  [2]interface_types.InterfaceIndex{5, 9},
+			expRequests: []l2.SwInterfaceSetL2Xconnect{},
+			expErr:      "ERROR: Xconnect peer 5 already xconnected to 9",
+		},
+		{
+			name:   "xconnect uplink",
+			uplink: "TenGigabitEthernet5/0/0",
+			expRequests: []l2.SwInterfaceSetL2Xconnect{
+				{RxSwIfIndex: 3, TxSwIfIndex: 5, Enable: true},
+				{RxSwIfIndex: 5, TxSwIfIndex: 3, Enable: true},
+			},
+		},
+		{
+			name:        "fail with uplink already xconnected",
+			uplink:      "TenGigabitEthernet5/0/0",
+			xconnected:  [2]interface_types.InterfaceIndex{5, 9},
+			expRequests: []l2.SwInterfaceSetL2Xconnect{},
+			expErr:      "ERROR: Xconnect peer 5 already xconnected to 9",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Attachment of the peer pod
+			peerArgs := testdata.GetTestArgs()
+			peerConf := &types.NetConf{}
+			peerNetType := tc.peerNetType
+			if peerNetType == "" {
+				peerNetType = "xconnect"
+			}
+			peerXconnect := tc.peerXconnect
+			if peerXconnect == nil {
+				peerXconnect = &types.XconnectConf{Pod: "classifier", IfName: "net1"}
+			}
+			peerData := VppSavedData{InterfaceSwIfIndex: 5, PodNamespace: "sfc", PodName: "firewall", ContainerID: peerArgs.ContainerID,
+				IfName: "net2", HostConf: types.UserSpaceConf{Engine: "vpp", NetType: peerNetType, XconnectConf: peerXconnect}}
+			peerArgs.IfName = "net2"
+			require.NoError(t, SaveVppConfig(peerConf, peerArgs, &peerData), "Can't save peer data")
+			defer func() { _ = LoadVppConfig(peerConf, peerArgs, &VppSavedData{}) }()
+
+			xconnected := tc.xconnected
+			if xconnected == [2]interface_types.InterfaceIndex{} {
+				xconnected = [2]interface_types.InterfaceIndex{7, 8}
+			}
+
+			requests := []l2.SwInterfaceSetL2Xconnect{}
+			mockVpp := mock.NewVppAdapter()
+			mockVpp.MockReplyHandler(func(request mock.MessageDTO) ([]byte, uint16, bool) {
+				var reply api.Message
+				pingID, _ := mockVpp.GetMsgID((&memclnt.ControlPing{}).GetMessageName(), "")
+				xconnectDumpID, _ := mockVpp.GetMsgID((&l2.L2XconnectDump{}).GetMessageName(), (&l2.L2XconnectDump{}).GetCrcString())
+				ifDumpID, _ := mockVpp.GetMsgID((&interfaces.SwInterfaceDump{}).GetMessageName(), (&interfaces.SwInterfaceDump{}).GetCrcString())
+				req := &l2.SwInterfaceSetL2Xconnect{}
+				switch {
+				case request.MsgName == req.GetMessageName():
+					require.NoError(t, codec.DefaultCodec.DecodeMsg(request.Data, req), "Can't decode request")
+					requests = append(requests, *req)
+					reply = &l2.SwInterfaceSetL2XconnectReply{}
+				case request.MsgID == xconnectDumpID:
+					reply = &l2.L2XconnectDetails{RxSwIfIndex: xconnected[0], TxSwIfIndex: xconnected[1]}
+				case request.MsgID == ifDumpID:
+					reply = &interfaces.SwInterfaceDetails{SwIfIndex: 5, InterfaceName: "TenGigabitEthernet5/0/0"}
+				case request.MsgID == pingID:
+					reply = &memclnt.ControlPingReply{}
+				default:
+					return nil, 0, false
+				}
+
+				msgID, _ := mockVpp.GetMsgID(reply.GetMessageName(), reply.GetCrcString())
+				data, err := mockVpp.ReplyBytes(request, reply)
+				require.NoError(t, err, "Can't encode reply")
+				return data, msgID, true
+			})
+			conn, err := core.Connect(mockVpp)
+			require.NoError(t, err, "Can't connect to mock VPP")
+			defer conn.Disconnect()
+			ch, err := conn.NewAPIChannel()
+			require.NoError(t, err, "Can't open channel to mock VPP")
+			defer ch.Close()
+			vppCh := vppinfra.ConnectionData{Ch: ch}
+
+			conf := &types.NetConf{}
+			if tc.uplink != "" {
+				conf.HostConf.XconnectConf = &types.XconnectConf{Interface: tc.uplink}
+			} else {
+				conf.HostConf.XconnectConf = &types.XconnectConf{Pod: tc.peerPod, IfName: "net2"}
+			}
+			data := &VppSavedData{PodNamespace: "sfc", PodName: "classifier", IfName: "net1"}
+
+			err = addLocalNetworkXconnect(vppCh, conf, 3, data)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+			assert.Equal(t, tc.expRequests, requests, "Unexpected xconnects")
+
+			// Both directions are removed, other xconnects are left alone
+			requests = []l2.SwInterfaceSetL2Xconnect{}
+			details := []api.Message{&l2.L2XconnectDetails{RxSwIfIndex: 7, TxSwIfIndex: 8}}
+			for _, req := range tc.expRequests {
+				details = append(details, &l2.L2XconnectDetails{RxSwIfIndex: req.RxSwIfIndex, TxSwIfIndex: req.TxSwIfIndex})
+			}
+			mockVpp.MockReply(details...)
+			mockVpp.MockReply(&memclnt.ControlPingReply{})
+
+			delLocalNetworkXconnect(vppCh, 3)
+			expRequests := []l2.SwInterfaceSetL2Xconnect{}
+			for _, req := range tc.expRequests {
+				req.Enable = false
+				expRequests = append(expRequests, req)
+			}
+			assert.ElementsMatch(t, expRequests, requests, "Unexpected xconnects removed")
+		})
+	}
+}
+
+func TestGetBridgeOptions(t *testing.T) {
+	on, off := true, false
+	testCases := []struct {
+		name       string
+		bridgeConf types.BridgeConf
+		expOptions vppbridge.BridgeOptions
+	}{
+		{
+			name:       "default options",
+			expOptions: vppbridge.DefaultBridgeOptions(),
+		},
+		{
+			name: "ARP termination without learning and flooding",
+			bridgeConf: types.BridgeConf{Learn: &off, Flood: &off, UuFlood: &off, Forward: &on,
+				ArpTerm: &on, MacAge: 5},
+			expOptions: vppbridge.BridgeOptions{Forward: true, ArpTerm: true, MacAge: 5},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &types.NetConf{}
+			conf.HostConf.BridgeConf = tc.bridgeConf
+			assert.Equal(t, tc.expOptions, getBridgeOptions(conf), "Unexpected options")
+		})
+	}
+}
+
+func TestAddDelLocalNetworkArpTerm(t *testing.T) {
+	ipv4 := &current.IPConfig{Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}}
+	ipv6 := &current.IPConfig{Address: net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)}}
+
+	testCases := []struct {
+		name       string
+		mac        string
+		ips        []*current.IPConfig
+		expEntries int
+	}{
+		{
+			name:       "add entries with MAC from result",
+			mac:        "02:00:00:00:00:0a",
+			ips:        []*current.IPConfig{ipv4, ipv6},
+			expEntries: 2,
+		},
+		{
+			name:       "add entry with generated MAC",
+			ips:        []*current.IPConfig{ipv4},
+			expEntries: 1,
+		},
+		{
+			name: "no IP address",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []string{}
+			vppCh := openMockVppCh(t, map[string]api.Message{
+				"bd_ip_mac_add_del": &l2.BdIPMacAddDelReply{},
+			}, &requests)
+			ipResult := &current.Result{
+				Interfaces: []*current.Interface{{Name: "eth0", Mac: tc.mac}},
+				IPs:        tc.ips,
+			}
+			data := &VppSavedData{}
+
+			require.NoError(t, addLocalNetworkArpTerm(vppCh, 4, "eth0", ipResult, data), "Unexpected error")
+			assert.Len(t, requests, tc.expEntries, "Unexpected entries added")
+			assert.Equal(t, tc.ips, data.ArpTermIPs, "IPs not saved")
+			if tc.expEntries == 0 {
+				assert.Empty(t, data.IfMac, "Unexpected MAC saved")
+				return
+			}
+			if tc.mac != "" {
+				assert.Equal(t, tc.mac, data.IfMac, "MAC from result not used")
+			}
+			mac, err := net.ParseMAC(data.IfMac)
+			require.NoError(t, err, "Invalid MAC saved")
+			assert.Zero(t, mac[0]&0x1, "Multicast MAC")
+			assert.Equal(t, data.IfMac, ipResult.Interfaces[0].Mac, "MAC not set in result")
+
+			requests = requests[:0]
+			delLocalNetworkArpTerm(vppCh, 4, data)
+			assert.Len(t, requests, tc.expEntries, "Unexpected entries removed")
+			assert.Empty(t, data.ArpTermIPs, "IPs not cleared")
+		})
+	}
+}
+
+func TestGetAclRules(t *testing.T) {
+	any4 := net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	any6 := net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	_, pod4, _ := net.ParseCIDR("192.168.1.0/24")
+	_, pod6, _ := net.ParseCIDR("fd00::/64")
+	host4 := net.IPNet{IP: net.ParseIP("10.0.0.1").To4(), Mask: net.CIDRMask(32, 32)}
+
+	testCases := []struct {
+		name     string
+		rules    []types.AclRule
+		expRules []vppacl.AclRule
+		expErr   string
+	}{
+		{
+			name:  "rule without prefixes for IPv4 and IPv6",
+			rules: []types.AclRule{{Action: "deny"}},
+			expRules: []vppacl.AclRule{
+				{Src: any4, Dst: any4, SrcPortLast: 65535, DstPortLast: 65535},
+				{Src: any6, Dst: any6, SrcPortLast: 65535, DstPortLast: 65535},
+			},
+		},
+		{
+			name:  "rule with prefix and port range",
+			rules: []types.AclRule{{Action: "allow", Src: "192.168.1.0/24", Proto: "tcp", DstPorts: "8000-8080"}},
+			expRules: []vppacl.AclRule{
+				{Permit: true, Src: *pod4, Dst: any4, Proto: 6, SrcPortLast: 65535, DstPortFirst: 8000, DstPortLast: 8080},
+			},
+		},
+		{
+			name:  "rule with host address and port",
+			rules: []types.AclRule{{Action: "allow", Dst: "10.0.0.1", Proto: "udp", SrcPorts: "53"}},
+			expRules: []vppacl.AclRule{
+				{Permit: true, Src: any4, Dst: host4, Proto: 17, SrcPortFirst: 53, SrcPortLast: 53, DstPortLast: 65535},
+			},
+		},
+		{
+			name:  "rules by protocol family",
+			rules: []types.AclRule{{Action: "allow", Proto: "icmp"}, {Action: "allow", Proto: "icmpv6"}, {Action: "allow", Dst: "fd00::/64", Proto: "47"}},
+			expRules: []vppacl.AclRule{
+				{Permit: true, Src: any4, Dst: any4, Proto: 1, SrcPortLast: 65535, DstPortLast: 65535},
+				{Permit: true, Src: any6, Dst: any6, Proto: 58, SrcPortLast: 65535, DstPortLast: 65535},
+				{Permit: true, Src: any6, Dst: *pod6, Proto: 47, SrcPortLast: 65535, DstPortLast: 65535},
+			},
+		},
+		{
+			name:   "fail with unknown action",
+			rules:  []types.AclRule{{Action: "permit"}},
+			expErr: "ERROR: Invalid AclRule.Action:permit",
+		},
+		{
+			name:   "fail with invalid prefix",
+			rules:  []types.AclRule{{Action: "allow", Src: "192.168.1.0/33"}},
+			expErr: "ERROR: Invalid AclRule prefix:192.168.1.0/33",
+		},
+		{
+			name:   "fail with mixed address families",
+			rules:  []types.AclRule{{Action: "allow", Src: "192.168.1.0/24", Dst: "fd00::/64"}},
+			expErr: "ERROR: AclRule mixes IPv4 and IPv6 prefixes: 192.168.1.0/24 fd00::/64",
+		},
+		{
+			name:   "fail with protocol of other address family",
+			rules:  []types.AclRule{{Action: "allow", Src: "fd00::/64", Proto: "icmp"}},
+			expErr: "ERROR: AclRule.Proto icmp doesn't match the address family of its prefixes",
+		},
+		{
+			name:   "fail with unknown protocol",
+			rules:  []types.AclRule{{Action: "allow", Proto: "sctp"}},
+			expErr: "ERROR: Invalid AclRule.Proto:sctp",
+		},
+		{
+			name:   "fail with ports without tcp or udp",
+			rules:  []types.AclRule{{Action: "allow", DstPorts: "80"}},
+			expErr: "ERROR: AclRule ports 80 need proto tcp or udp",
+		},
+		{
+			name:   "fail with reversed port range",
+			rules:  []types.AclRule{{Action: "allow", Proto: "tcp", DstPorts: "8080-8000"}},
+			expErr: "ERROR: Invalid AclRule ports:8080-8000",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := getAclRules(tc.rules)
+			if tc.expErr == "" {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, tc.expRules, rules, "Unexpected rules")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestAddDelLocalNetworkAcl(t *testing.T) {
+	testCases := []struct {
+		name           string
+		aclConf        types.AclConf
+		subIfSwIfIndex interface_types.InterfaceIndex
+		expRequests    []string
+		expAcls        int
+		expSwIfIndex   interface_types.InterfaceIndex
+	}{
+		{
+			name: "add ingress and egress ACLs",
+			aclConf: types.AclConf{
+				Ingress: []types.AclRule{{Action: "allow", Proto: "tcp", DstPorts: "80"}},
+				Egress:  []types.AclRule{{Action: "allow", Dst: "192.168.1.0/24"}},
+			},
+			expRequests:  []string{"acl_add_replace", "acl_add_replace", "acl_interface_set_acl_list"},
+			expAcls:      2,
+			expSwIfIndex: 5,
+		},
+		{
+			name:         "add ingress ACL",
+			aclConf:      types.AclConf{Ingress: []types.AclRule{{Action: "deny", Src: "10.0.0.0/8"}}},
+			expRequests:  []string{"acl_add_replace", "acl_interface_set_acl_list"},
+			expAcls:      1,
+			expSwIfIndex: 5,
+		},
+		{
+			name:           "add ACL to VLAN sub-interface",
+			aclConf:        types.AclConf{Egress: []types.AclRule{{Action: "allow"}}},
+			subIfSwIfIndex: 6,
+			expRequests:    []string{"acl_add_replace", "acl_interface_set_acl_list"},
+			expAcls:        1,
+			expSwIfIndex:   6,
+		},
+		{
+			name:        "no ACL",
+			expRequests: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []string{}
+			swIfIndexes := []interface_types.InterfaceIndex{}
+			vppCh := openMockVppCh(t, map[string]api.Message{
+				"acl_add_replace":            &acl.ACLAddReplaceReply{ACLIndex: 3},
+				"acl_interface_set_acl_list": &acl.ACLInterfaceSetACLListReply{},
+				"acl_del":                    &acl.ACLDelReply{},
+			}, &requests)
+			vppCh.Ch = &recordAclListCh{Channel: vppCh.Ch, swIfIndexes: &swIfIndexes}
+			data := &VppSavedData{InterfaceSwIfIndex: 5, SubIfSwIfIndex: tc.subIfSwIfIndex, Tag: "usrspcni-0958c8871b32-net1"}
+
+			require.NoError(t, addLocalNetworkAcl(vppCh, &tc.aclConf, data), "Unexpected error")
+			assert.Equal(t, tc.expRequests, requests, "Unexpected requests")
+			assert.Len(t, data.AclIndexes, tc.expAcls, "Unexpected ACLs saved")
+			if tc.expAcls == 0 {
+				return
+			}
+			assert.Equal(t, tc.aclConf, data.Acl, "AclConf not saved")
+
+			requests = requests[:0]
+			delLocalNetworkAcl(vppCh, data)
+			expRequests := []string{"acl_interface_set_acl_list"}
+			for i := 0; i < tc.expAcls; i++ {
+				expRequests = append(expRequests, "acl_del")
+			}
+			assert.Equal(t, expRequests, requests, "Unexpected requests")
+			assert.Empty(t, data.AclIndexes, "ACLs not cleared")
+			assert.Equal(t, []interface_types.InterfaceIndex{tc.expSwIfIndex, tc.expSwIfIndex}, swIfIndexes, "ACLs not bound to the Local Network interface")
+		})
+	}
+}
+
+// Channel recording the interface of each ACL list set through it.
+type recordAclListCh struct {
+	api.Channel
+	swIfIndexes *[]interface_types.InterfaceIndex
+}
+
+func (ch *recordAclListCh) SendRequest(msg api.Message) api.RequestCtx {
+	if req, ok := msg.(*acl.ACLInterfaceSetACLList); ok {
+		*ch.swIfIndexes = append(*ch.swIfIndexes, req.SwIfIndex)
+	}
+	return ch.Channel.SendRequest(msg)
+}
@@ -32,6 +32,8 @@ import (
 	"path/filepath"
 
 	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
 
 	"github.com/intel/userspace-cni-network-plugin/cnivpp/bin_api/interface_types"
 	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
@@ -51,6 +53,61 @@ const debugVppDb = false
 type VppSavedData struct {
 	InterfaceSwIfIndex interface_types.InterfaceIndex `json:"swIfIndex"`     // Software Index, used to access the created interface, needed to delete interface.
 	MemifSocketId      uint32                         `json:"memifSocketId"` // Memif SocketId, used to access the created memif Socket File, used for debug only.
+
+	// VLAN sub-interface created when BridgeConf.VlanId is set. It is the
+	// one in the Local Network, and is deleted before the interface.
+	SubIfSwIfIndex interface_types.InterfaceIndex `json:"subIfSwIfIndex,omitempty"`
+
+	// BridgeName and the Bridge Domain ID it was mapped to on ADD. The name is
+	// released from the bridge domain table on DEL.
+	BridgeName   string `json:"bridgeName,omitempty"`
+	BridgeDomain uint32 `json:"bridgeDomain,omitempty"`
+
+	// MAC of the pod interface, and its IP addresses added to the ARP
+	// termination table of the Bridge Domain. The entries are removed on DEL.
+	IfMac      string              `json:"ifMac,omitempty"`
+	ArpTermIPs []*current.IPConfig `json:"arpTermIps,omitempty"`
+
+	// Addresses of the gateway BVI with BridgeConf.Gateway, used to recreate
+	// it after a VPP restart. The BVI is deleted with the Bridge Domain.
+	Gateways []cnitypes.IPNet `json:"gateways,omitempty"`
+
+	// ACLs bound to the interface from the NetConf AclConf, deleted on DEL.
+	// The AclConf is used to recreate them after a VPP restart.
+	AclIndexes []uint32      `json:"aclIndexes,omitempty"`
+	Acl        types.AclConf `json:"acl,omitempty"`
+
+	// IP addresses added with NetType "interface", removed on DEL
+	IPs []*current.IPConfig `json:"ips,omitempty"`
+
+	// FIB table the interface is bound to with NetType "interface", and the
+	// routes added to it. The routes are withdrawn on DEL.
+	Vrf    uint32            `json:"vrf,omitempty"`
+	Routes []*cnitypes.Route `json:"routes,omitempty"`
+
+	// Tag set on the interface, and with a "-vlan" suffix on its VLAN
+	// sub-interface. DEL and CHECK find the interfaces by tag, as their
+	// indexes change when VPP restarts.
+	Tag string `json:"tag,omitempty"`
+
+	// Host configuration of the attachment, used to recreate it after a
+	// VPP restart.
+	HostConf types.UserSpaceConf `json:"hostConf,omitempty"`
+
+	// API socket of the VPP instance the interface was created on. DEL and
+	// CHECK talk to the same instance.
+	ApiSocket string `json:"apiSocket,omitempty"`
+
+	// Pod the attachment belongs to, from the K8S CNI_ARGS. Used to find
+	// the peer of an xconnect.
+	PodNamespace string `json:"podNamespace,omitempty"`
+	PodName      string `json:"podName,omitempty"`
+
+	// Attachment identity, used by cmdGC() to find and delete stale attachments
+	NetName     string `json:"netName"`     // NetConf Name
+	ContainerID string `json:"containerId"` // From args.ContainerID
+	IfName      string `json:"ifName"`      // From args.IfName
+	SharedDir   string `json:"sharedDir"`   // Directory socketfiles were created in
 }
 
 //
@@ -97,14 +154,9 @@ func LoadVppConfig(conf *types.NetConf, args *skel.CmdArgs, data *VppSavedData)
 	path := filepath.Join(localDir, fileName)
 
 	if _, err := os.Stat(path); err == nil {
-		if dataBytes, err := os.ReadFile(path); err == nil {
-			if err = json.Unmarshal(dataBytes, data); err != nil {
-				return fmt.Errorf("ERROR: Failed to parse VPP saved data: %v", err)
-			}
-		} else {
-			return fmt.Errorf("ERROR: Failed to read VPP saved data: %v", err)
+		if err = readVppConfigFile(path, data); err != nil {
+			return err
 		}
-
 	} else {
 		path = ""
 	}
@@ -114,3 +166,82 @@ func LoadVppConfig(conf *types.NetConf, args *skel.CmdArgs, data *VppSavedData)
 
 	return nil
 }
+
+// ReadVppConfig() - Retrieve the data saved by SaveVppConfig() without
+//
+//	deleting it. Used by cmdCheck(), which must leave the saved data in
+//	place. Unlike LoadVppConfig(), a missing file is reported as an error.
+func ReadVppConfig(conf *types.NetConf, args *skel.CmdArgs, data *VppSavedData) error {
+
+	fileName := fmt.Sprintf("local-%s-%s.json", args.ContainerID[:12], args.IfName)
+	path := filepath.Join(annotations.DefaultLocalCNIDir, fileName)
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("ERROR: No VPP saved data found: %v", err)
+	}
+
+	return readVppConfigFile(path, data)
+}
+
+// ListVppConfig() - Retrieve the saved data of every attachment of the given
+//
+//	network. Used by cmdGC() to find attachments that are no longer valid.
+//	Files of other engines, that can't be parsed, or were saved before the
+//	attachment identity was recorded, are skipped.
+func ListVppConfig(conf *types.NetConf) ([]VppSavedData, error) {
+	var dataList []VppSavedData
+
+	allData, err := listAllVppConfig()
+	if err != nil {
+		return dataList, err
+	}
+
+	for _, data := range allData {
+		if data.NetName == conf.Name {
+			dataList = append(dataList, data)
+		}
+	}
+
+	return dataList, nil
+}
+
+//
+// Local Functions
+//
+
+// Retrieve the saved data of every VPP attachment, of all networks. The
+// directory is shared with the OVS engine, so files of other engines are
+// skipped, as are files that can't be parsed or were saved before the
+// attachment identity and host configuration were recorded.
+func listAllVppConfig() ([]VppSavedData, error) {
+	var dataList []VppSavedData
+
+	paths, err := filepath.Glob(filepath.Join(annotations.DefaultLocalCNIDir, "local-*.json"))
+	if err != nil {
+		return dataList, err
+	}
+
+	for _, path := range paths {
+		var data VppSavedData
+		if err = readVppConfigFile(path, &data); err != nil {
+			continue
+		}
+		if data.ContainerID != "" && data.HostConf.Engine == engineName {
+			dataList = append(dataList, data)
+		}
+	}
+
+	return dataList, nil
+}
+
+func readVppConfigFile(path string, data *VppSavedData) error {
+	if dataBytes, err := os.ReadFile(path); err == nil {
+		if err = json.Unmarshal(dataBytes, data); err != nil {
+			return fmt.Errorf("ERROR: Failed to parse VPP saved data: %v", err)
+		}
+	} else {
+		return fmt.Errorf("ERROR: Failed to read VPP saved data: %v", err)
+	}
+
+	return nil
+}
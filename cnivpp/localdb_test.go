@@ -0,0 +1,58 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cnivpp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListVppConfig(t *testing.T) {
+	t.Run("list saved data of a network, skip other engines", func(t *testing.T) {
+		args := testdata.GetTestArgs()
+		otherArgs := testdata.GetTestArgs()
+		ovsArgs := testdata.GetTestArgs()
+		conf := &types.NetConf{Name: "list-" + args.ContainerID[:12]}
+
+		saved := &VppSavedData{InterfaceSwIfIndex: 3, HostConf: types.UserSpaceConf{Engine: "vpp"},
+			NetName: conf.Name, ContainerID: args.ContainerID, IfName: args.IfName}
+		require.NoError(t, SaveVppConfig(conf, args, saved), "Unexpected error")
+		require.NoError(t, SaveVppConfig(conf, otherArgs, &VppSavedData{InterfaceSwIfIndex: 4, HostConf: types.UserSpaceConf{Engine: "vpp"},
+			NetName: "other-network", ContainerID: otherArgs.ContainerID, IfName: otherArgs.IfName}), "Unexpected error")
+
+		// Saved data of the OVS engine, in the same directory
+		ovsPath := filepath.Join(annotations.DefaultLocalCNIDir, fmt.Sprintf("local-%s-%s.json", ovsArgs.ContainerID[:12], ovsArgs.IfName))
+		ovsData := fmt.Sprintf(`{"vhostname":"vhost0","netName":"%s","containerId":"%s","ifName":"%s"}`, conf.Name, ovsArgs.ContainerID, ovsArgs.IfName)
+		require.NoError(t, os.WriteFile(ovsPath, []byte(ovsData), 0600), "Unexpected error")
+		defer func() {
+			var data VppSavedData
+			_ = LoadVppConfig(conf, args, &data)
+			_ = LoadVppConfig(conf, otherArgs, &data)
+			_ = os.Remove(ovsPath)
+		}()
+
+		dataList, err := ListVppConfig(conf)
+		require.NoError(t, err, "Unexpected error")
+		assert.Equal(t, []VppSavedData{*saved}, dataList, "Unexpected data retrieved")
+	})
+}
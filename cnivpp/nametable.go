@@ -0,0 +1,197 @@
+// Copyright (c) 2018 Red Hat.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//
+// This module provides node-local tables mapping names, like the name of
+// a bridge domain, to the numeric IDs VPP uses. Each entry counts the
+// attachments using it and is removed with the last one. IDs are local to
+// a VPP instance, so each API socket has its own tables. A table is json
+// data written to a file in the local CNI directory. Several CNI requests
+// can run in parallel, so a table is only accessed under a file lock.
+//
+
+package cnivpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+)
+
+//
+// Constants
+//
+
+const (
+	bridgeDomainTable = "vpp-bridge-domains"
+	fibTableTable     = "vpp-fib-tables"
+
+	// IDs allocated to names start above the 16 bit range, leaving the
+	// lower IDs to numeric names and to bridge domains configured by hand.
+	firstNamedBridgeDomain = 0x10000
+	maxBridgeDomain        = 0xFFFFFF
+)
+
+//
+// Types
+//
+
+type nameTableEntry struct {
+	Id       uint32 `json:"id"`       // ID allocated to the name
+	RefCount int    `json:"refCount"` // Number of attachments using the ID
+}
+
+type nameTable map[string]*nameTableEntry
+
+//
+// API Functions
+//
+
+// acquireNameId() - Return the ID of a name, allocating it if needed.
+//
+//	A name that is a number maps to that number, other names get the lowest
+//	free ID from first to max. The reference count of the name is increased.
+func acquireNameId(tableName string, name string, first uint32, max uint32) (uint32, error) {
+	var id uint32
+
+	err := updateNameTable(tableName, func(table nameTable) error {
+		if entry, ok := table[name]; ok {
+			entry.RefCount++
+			id = entry.Id
+			return nil
+		}
+
+		if number, err := strconv.ParseUint(name, 10, 32); err == nil {
+			id = uint32(number)
+			if owner := table.owner(id); owner != "" {
+				return fmt.Errorf("ERROR: ID %d of %s already in use by %s", id, name, owner)
+			}
+		} else {
+			for id = first; table.owner(id) != ""; id++ {
+				if id == max {
+					return fmt.Errorf("ERROR: No free ID left for %s", name)
+				}
+			}
+		}
+
+		table[name] = &nameTableEntry{Id: id, RefCount: 1}
+		return nil
+	})
+
+	return id, err
+}
+
+// releaseNameId() - Decrease the reference count of a name.
+//
+//	The entry is removed once no attachment uses it anymore, and true is
+//	returned. Releasing an unknown name is not an error, it was created
+//	before names were tracked.
+func releaseNameId(tableName string, name string) (bool, error) {
+	var removed bool
+
+	err := updateNameTable(tableName, func(table nameTable) error {
+		if entry, ok := table[name]; ok {
+			entry.RefCount--
+			if entry.RefCount <= 0 {
+				delete(table, name)
+				removed = true
+			}
+		}
+		return nil
+	})
+
+	return removed, err
+}
+
+// getNameTable() - Return the name of a table of the VPP instance behind
+//
+//	apiSocket. The default socket keeps the name used before instances
+//	were told apart, so entries saved by then are still released.
+func getNameTable(tableName string, apiSocket string) string {
+	if apiSocket == "" {
+		return tableName
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(apiSocket))
+	return fmt.Sprintf("%s-%08x", tableName, hash.Sum32())
+}
+
+//
+// Local Functions
+//
+
+// Name of the entry the ID is allocated to, empty if the ID is free.
+func (table nameTable) owner(id uint32) string {
+	for name, entry := range table {
+		if entry.Id == id {
+			return name
+		}
+	}
+	return ""
+}
+
+// updateNameTable() - Read, update and write back a table under its lock.
+//
+//	The table is not written if update() returns an error.
+func updateNameTable(tableName string, update func(table nameTable) error) error {
+	localDir := annotations.DefaultLocalCNIDir
+	if err := os.MkdirAll(localDir, 0700); err != nil {
+		return err
+	}
+
+	lockFile, err := os.OpenFile(filepath.Join(localDir, tableName+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// Closing the file releases the lock
+	defer lockFile.Close()
+
+	if err = unix.Flock(int(lockFile.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("ERROR: Failed to lock %s: %v", tableName, err)
+	}
+
+	path := filepath.Join(localDir, tableName+".json")
+	table := nameTable{}
+	if dataBytes, err := os.ReadFile(path); err == nil {
+		if err = json.Unmarshal(dataBytes, &table); err != nil {
+			return fmt.Errorf("ERROR: Failed to parse %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("ERROR: Failed to read %s: %v", path, err)
+	}
+
+	if err = update(table); err != nil {
+		return err
+	}
+
+	dataBytes, err := json.Marshal(table)
+	if err != nil {
+		return fmt.Errorf("ERROR: serializing %s: %v", tableName, err)
+	}
+
+	// Replace the file at once, so a failed write doesn't corrupt the table
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, dataBytes, 0600); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
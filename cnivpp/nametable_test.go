@@ -0,0 +1,126 @@
+package cnivpp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNameTable = "test-name-table"
+
+func removeTestNameTable() {
+	_ = os.Remove(filepath.Join(annotations.DefaultLocalCNIDir, testNameTable+".json"))
+	_ = os.Remove(filepath.Join(annotations.DefaultLocalCNIDir, testNameTable+".lock"))
+}
+
+func TestAcquireNameId(t *testing.T) {
+	testCases := []struct {
+		name   string
+		names  []string
+		expIds []uint32
+		expErr string
+	}{
+		{
+			name:   "map numeric name to its number",
+			names:  []string{"5"},
+			expIds: []uint32{5},
+		},
+		{
+			name:   "allocate ID to named entry",
+			names:  []string{"tenant-a"},
+			expIds: []uint32{100},
+		},
+		{
+			name:   "allocate lowest free ID",
+			names:  []string{"tenant-a", "tenant-b", "tenant-a"},
+			expIds: []uint32{100, 101, 100},
+		},
+		{
+			name:   "skip ID in use by numeric name",
+			names:  []string{"100", "tenant-a"},
+			expIds: []uint32{100, 101},
+		},
+		{
+			name:   "fail with numeric name already allocated",
+			names:  []string{"tenant-a", "100"},
+			expIds: []uint32{100},
+			expErr: "ERROR: ID 100 of 100 already in use by tenant-a",
+		},
+		{
+			name:   "fail with no free ID left",
+			names:  []string{"tenant-a", "tenant-b", "tenant-c"},
+			expIds: []uint32{100, 101},
+			expErr: "ERROR: No free ID left for tenant-c",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			removeTestNameTable()
+			defer removeTestNameTable()
+
+			for i, name := range tc.names {
+				id, err := acquireNameId(testNameTable, name, 100, 101)
+				if i < len(tc.expIds) {
+					require.NoError(t, err, "Unexpected error")
+					assert.Equal(t, tc.expIds[i], id, "Unexpected ID")
+				} else {
+					require.Error(t, err, "Error was expected")
+					assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseNameId(t *testing.T) {
+	t.Run("release ID with last reference", func(t *testing.T) {
+		removeTestNameTable()
+		defer removeTestNameTable()
+
+		for _, name := range []string{"tenant-a", "tenant-a", "tenant-b"} {
+			_, err := acquireNameId(testNameTable, name, firstNamedBridgeDomain, maxBridgeDomain)
+			require.NoError(t, err, "Can't acquire ID")
+		}
+
+		// tenant-a is still referenced once, tenant-b is gone
+		removed, err := releaseNameId(testNameTable, "tenant-a")
+		require.NoError(t, err, "Can't release ID")
+		assert.False(t, removed, "Referenced ID removed")
+		removed, err = releaseNameId(testNameTable, "tenant-b")
+		require.NoError(t, err, "Can't release ID")
+		assert.True(t, removed, "Last reference not removed")
+		id, err := acquireNameId(testNameTable, "tenant-c", firstNamedBridgeDomain, maxBridgeDomain)
+		require.NoError(t, err, "Can't acquire ID")
+		assert.Equal(t, uint32(firstNamedBridgeDomain+1), id, "Released ID not reused")
+
+		removed, err = releaseNameId(testNameTable, "tenant-a")
+		require.NoError(t, err, "Can't release ID")
+		assert.True(t, removed, "Last reference not removed")
+		id, err = acquireNameId(testNameTable, "tenant-d", firstNamedBridgeDomain, maxBridgeDomain)
+		require.NoError(t, err, "Can't acquire ID")
+		assert.Equal(t, uint32(firstNamedBridgeDomain), id, "Released ID not reused")
+
+		// unknown names were never tracked
+		removed, err = releaseNameId(testNameTable, "tenant-e")
+		assert.NoError(t, err, "Unexpected error")
+		assert.False(t, removed, "Unknown name removed")
+	})
+}
+
+func TestGetNameTable(t *testing.T) {
+	t.Run("keep table of default socket", func(t *testing.T) {
+		assert.Equal(t, bridgeDomainTable, getNameTable(bridgeDomainTable, ""), "Unexpected table")
+	})
+
+	t.Run("separate tables per socket", func(t *testing.T) {
+		table1 := getNameTable(bridgeDomainTable, "/run/vpp/api-numa0.sock")
+		table2 := getNameTable(bridgeDomainTable, "/run/vpp/api-numa1.sock")
+		assert.NotEqual(t, table1, table2, "Sockets share a table")
+		assert.NotEqual(t, bridgeDomainTable, table1, "Socket shares the default table")
+		assert.Equal(t, table1, getNameTable(bridgeDomainTable, "/run/vpp/api-numa0.sock"), "Table of socket changed")
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018 Red Hat.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//
+// This module recreates the VPP attachments of the local DB after VPP
+// restarted. VPP keeps no state across a restart, so the memif sockets,
+// interfaces, ACLs, bridge domains, FIB tables, IP addresses, routes and
+// cross-connects of each attachment are programmed again from its saved data.
+//
+
+package cnivpp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	current "github.com/containernetworking/cni/pkg/types/100"
+
+	vppbridge "github.com/intel/userspace-cni-network-plugin/cnivpp/api/bridge"
+	vppinfra "github.com/intel/userspace-cni-network-plugin/cnivpp/api/infra"
+	vppinterface "github.com/intel/userspace-cni-network-plugin/cnivpp/api/interface"
+	"github.com/intel/userspace-cni-network-plugin/logging"
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+)
+
+//
+// API Functions
+//
+
+// Reconcile() - Recreate the attachments of the local DB missing in VPP.
+//
+//	Attachments whose tagged interface still exists are only updated with
+//	the current interface indexes. All attachments are attempted, the
+//	returned error lists each one that failed.
+func Reconcile() error {
+	var errs []error
+
+	dataList, err := listAllVppConfig()
+	if err != nil {
+		return err
+	}
+
+	for i := range dataList {
+		data := &dataList[i]
+		if err = reconcileAttachment(data); err != nil {
+			errs = append(errs, fmt.Errorf("ERROR: Container %s Iface %s: %v", data.ContainerID[:12], data.IfName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+//
+// Local Functions
+//
+
+// Network configuration of an attachment, as far as needed to recreate it.
+func getSavedNetConf(data *VppSavedData) *types.NetConf {
+	return &types.NetConf{
+		Name:     data.NetName,
+		HostConf: data.HostConf,
+		VppConf:  types.VppConf{Vrf: data.Vrf, ApiSocket: data.ApiSocket},
+		AclConf:  data.Acl,
+	}
+}
+
+// reconcileAttachment() - Recreate an attachment if VPP lost its interface.
+//
+//	Data saved before interfaces were tagged can't be matched with VPP and
+//	is skipped.
+func reconcileAttachment(data *VppSavedData) error {
+	if data.Tag == "" || data.HostConf.IfType == "" {
+		logging.Infof("VPP Reconcile: Container %s Iface %s saved without tag, skipped", data.ContainerID[:12], data.IfName)
+		return nil
+	}
+
+	conf := getSavedNetConf(data)
+	args := &skel.CmdArgs{ContainerID: data.ContainerID, IfName: data.IfName}
+
+	// Create Channel to pass requests to VPP
+	vppCh, err := vppinfra.VppOpenCh(data.ApiSocket)
+	if err != nil {
+		return err
+	}
+	defer vppinfra.VppCloseCh(vppCh)
+
+	hasSubif := data.SubIfSwIfIndex != 0
+	found, err := resolveLocalDevice(vppCh, data)
+	if err != nil {
+		return err
+	}
+	if found && hasSubif && data.SubIfSwIfIndex == 0 {
+		// Only the VLAN sub-interface is gone, recreate the attachment as a
+		// whole rather than patching up the bridge and ACLs of the interface
+		logging.Infof("VPP Reconcile: Sub-interface of %s lost, deleting interface", data.Tag)
+		if err = removeLocalDevice(vppCh, conf, args, data.SharedDir, data); err != nil {
+			return err
+		}
+		found = false
+	}
+	if !found {
+		logging.Infof("VPP Reconcile: Recreating interface %s", data.Tag)
+		if err = addLocalAttachment(vppCh, conf, args, data); err != nil {
+			return err
+		}
+	}
+
+	return SaveVppConfig(conf, args, data)
+}
+
+// addLocalAttachment() - Program an attachment into VPP from its saved data.
+//
+//	The bridge domain and FIB table IDs are still held in the node-local
+//	tables, so they are reused as is. On failure, the interface is deleted
+//	again and the saved data left unchanged.
+func addLocalAttachment(vppCh vppinfra.ConnectionData, conf *types.NetConf, args *skel.CmdArgs, data *VppSavedData) error {
+	var err error
+
+	rebuilt := *data
+	rebuilt.InterfaceSwIfIndex = 0
+	rebuilt.MemifSocketId = 0
+	rebuilt.SubIfSwIfIndex = 0
+	rebuilt.Routes = nil
+	rebuilt.ArpTermIPs = nil
+	rebuilt.AclIndexes = nil
+
+	// VPP creates the memif socketfile again, remove the stale one
+	if conf.HostConf.IfType == "memif" {
+		cleanupLocalDeviceFiles(conf, args, data.SharedDir)
+	}
+
+	if err = addLocalDevice(vppCh, conf, args, data.SharedDir, &rebuilt); err != nil {
+		return err
+	}
+	netSwIfIndex := getNetworkSwIfIndex(&rebuilt)
+
+	err = addLocalNetworkAcl(vppCh, &conf.AclConf, &rebuilt)
+	if err == nil && conf.HostConf.NetType == "bridge" {
+		var bridgeDomain uint32
+		if bridgeDomain, err = getSavedBridgeDomain(conf, data); err == nil {
+			err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, netSwIfIndex, getBridgeOptions(conf))
+		}
+		if err == nil && len(data.Gateways) != 0 {
+			err = vppbridge.AddBridgeGateway(vppCh.Ch, bridgeDomain, getGatewayNets(data.Gateways))
+		}
+		if err == nil && len(data.ArpTermIPs) != 0 {
+			err = addLocalNetworkArpTermEntries(vppCh, bridgeDomain, data.ArpTermIPs, &rebuilt)
+		}
+	} else if err == nil && conf.HostConf.NetType == "interface" {
+		if rebuilt.Vrf != 0 {
+			err = setLocalNetworkVrf(vppCh, rebuilt.Vrf, netSwIfIndex)
+		}
+		if err == nil && len(rebuilt.IPs) != 0 {
+			err = vppinterface.AddDelIpAddress(vppCh.Ch, netSwIfIndex, true, &current.Result{IPs: rebuilt.IPs})
+		}
+		if err == nil {
+			err = addLocalNetworkRoutes(vppCh, netSwIfIndex, data.Routes, &rebuilt)
+		}
+	} else if err == nil && conf.HostConf.NetType == "xconnect" {
+		err = addLocalNetworkXconnect(vppCh, conf, netSwIfIndex, &rebuilt)
+	}
+
+	if err != nil {
+		if delErr := removeLocalDevice(vppCh, conf, args, data.SharedDir, &rebuilt); delErr != nil {
+			logging.Debugf("addLocalAttachment(vpp): Unable to delete interface %d: %v", rebuilt.InterfaceSwIfIndex, delErr)
+		}
+		return err
+	}
+
+	*data = rebuilt
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Red Hat.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary reconcile recreates the VPP attachments of the Userspace CNI local
+// DB after VPP restarted. It is meant to be run on the node once VPP is up
+// again, for instance from the VPP service or its container.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/intel/userspace-cni-network-plugin/cnivpp"
+	"github.com/intel/userspace-cni-network-plugin/logging"
+)
+
+func main() {
+	logLevel := flag.String("log-level", "info", "Logging level: panic, error, warning, info, debug or verbose")
+	logFile := flag.String("log-file", "", "Log file, in addition to stderr")
+	flag.Parse()
+
+	logging.SetLogLevel(*logLevel)
+	logging.SetLogFile(*logFile)
+
+	if err := cnivpp.Reconcile(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -71,7 +71,7 @@ func main() {
 	fmt.Println("Starting User Space client...")
 
 	// Create Channel to pass requests to VPP
-	vppCh, err = vppinfra.VppOpenCh()
+	vppCh, err = vppinfra.VppOpenCh("")
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -91,7 +91,7 @@ func main() {
 	}
 
 	// Create MemIf Interface
-	swIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, memifSocketId, memifRole, memifMode)
+	swIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, memifSocketId, memifRole, memifMode, vppmemif.MemifOptions{})
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -69,7 +69,7 @@ func main() {
 	fmt.Println("Starting User Space client...")
 
 	// Create Channel to pass requests to VPP
-	vppCh, err = vppinfra.VppOpenCh()
+	vppCh, err = vppinfra.VppOpenCh("")
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -89,7 +89,7 @@ func main() {
 	}
 
 	// Create MemIf Interface
-	swIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, memifSocketId, memifRole, memifMode)
+	swIfIndex, err = vppmemif.CreateMemifInterface(vppCh.Ch, memifSocketId, memifRole, memifMode, vppmemif.MemifOptions{})
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -102,7 +102,7 @@ func main() {
 
 	// Add MemIf to Bridge. If Bridge does not exist, AddBridgeInterface()
 	// will create.
-	err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, swIfIndex)
+	err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, swIfIndex, vppbridge.DefaultBridgeOptions())
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -65,7 +65,7 @@ func main() {
 	fmt.Println("Starting Vhost-User Test client...")
 
 	// Create Channel to pass requests to VPP
-	vppCh, err = vppinfra.VppOpenCh()
+	vppCh, err = vppinfra.VppOpenCh("")
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
@@ -86,7 +86,7 @@ func main() {
 
 	// Add Vhost-User to Bridge. If Bridge does not exist, AddBridgeInterface()
 	// will create.
-	err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, swIfIndex)
+	err = vppbridge.AddBridgeInterface(vppCh.Ch, bridgeDomain, swIfIndex, vppbridge.DefaultBridgeOptions())
 	if err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
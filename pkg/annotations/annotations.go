@@ -37,6 +37,7 @@ const (
 	annotKeyNetworkStatus   = "k8s.v1.cni.cncf.io/networks-status"
 	AnnotKeyUsrspConfigData = "userspace/configuration-data"
 	AnnotKeyUsrspMappedDir  = "userspace/mapped-dir"
+	AnnotKeyUsrspNumaNode   = "userspace/numa-node"
 	volMntKeySharedDir      = "shared-dir"
 
 	DefaultBaseCNIDir  = "/var/lib/cni/usrspcni"
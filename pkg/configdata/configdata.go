@@ -116,6 +116,27 @@ func SaveRemoteConfig(conf *types.NetConf,
 			configData.Config.MemifConf.Mode = conf.HostConf.MemifConf.Mode
 		}
 		configData.Config.MemifConf.Socketfile = conf.HostConf.MemifConf.Socketfile
+
+		// Both ends must agree on the interface settings. What the host
+		// receives on, the container transmits on.
+		if configData.Config.MemifConf.RxQueues == 0 {
+			configData.Config.MemifConf.RxQueues = conf.HostConf.MemifConf.TxQueues
+		}
+		if configData.Config.MemifConf.TxQueues == 0 {
+			configData.Config.MemifConf.TxQueues = conf.HostConf.MemifConf.RxQueues
+		}
+		if configData.Config.MemifConf.RingSize == 0 {
+			configData.Config.MemifConf.RingSize = conf.HostConf.MemifConf.RingSize
+		}
+		if configData.Config.MemifConf.BufferSize == 0 {
+			configData.Config.MemifConf.BufferSize = conf.HostConf.MemifConf.BufferSize
+		}
+		if configData.Config.MemifConf.Id == 0 {
+			configData.Config.MemifConf.Id = conf.HostConf.MemifConf.Id
+		}
+		if configData.Config.MemifConf.Secret == "" {
+			configData.Config.MemifConf.Secret = conf.HostConf.MemifConf.Secret
+		}
 	} else if configData.Config.IfType == "vhostuser" {
 		if configData.Config.VhostConf.Mode == "" {
 			if conf.HostConf.VhostConf.Mode == "client" {
@@ -153,12 +174,15 @@ func SaveRemoteConfig(conf *types.NetConf,
 			}
 		}
 
-		fileName := fmt.Sprintf("configData-%s-%s.json", args.ContainerID[:12], args.IfName)
-		path := filepath.Join(sharedDir, fileName)
+		path := filepath.Join(sharedDir, GetRemoteConfigFileName(args))
 
 		dataBytes, jsonErr := json.Marshal(configData)
 		if jsonErr == nil {
 			err = os.WriteFile(path, dataBytes, 0644)
+			if err != nil {
+				// Don't leave a truncated file for the container to consume
+				_ = os.Remove(path)
+			}
 		} else {
 			return pod, fmt.Errorf("ERROR: serializing REMOTE NetConf data: %v", err)
 		}
@@ -167,6 +191,13 @@ func SaveRemoteConfig(conf *types.NetConf,
 	return pod, err
 }
 
+// GetRemoteConfigFileName() - Name of the file SaveRemoteConfig() writes
+//
+//	the configuration data to when no kubeClient is available.
+func GetRemoteConfigFileName(args *skel.CmdArgs) string {
+	return fmt.Sprintf("configData-%s-%s.json", args.ContainerID[:12], args.IfName)
+}
+
 // CleanupRemoteConfig() - This function cleans up any remaining files
 //
 //	in the passed in directory. Some of these files were used to squirrel
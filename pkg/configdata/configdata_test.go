@@ -16,6 +16,7 @@ package configdata
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -252,6 +253,55 @@ func TestSaveRemoteConfig(t *testing.T) {
 	}
 }
 
+func TestSaveRemoteConfigMemif(t *testing.T) {
+	testCases := []struct {
+		name          string
+		hostConf      types.MemifConf
+		containerConf types.MemifConf
+		expConf       types.MemifConf
+	}{
+		{
+			name:     "pass host memif settings with queues swapped",
+			hostConf: types.MemifConf{Role: "master", RxQueues: 2, TxQueues: 4, RingSize: 4096, BufferSize: 4096, Id: 3, Secret: "s3cr3t"},
+			expConf:  types.MemifConf{Role: "slave", RxQueues: 4, TxQueues: 2, RingSize: 4096, BufferSize: 4096, Id: 3, Secret: "s3cr3t"},
+		},
+		{
+			name:          "keep ContainerConf memif settings",
+			hostConf:      types.MemifConf{Role: "master", RxQueues: 2, TxQueues: 4, RingSize: 4096, Secret: "s3cr3t"},
+			containerConf: types.MemifConf{RxQueues: 1, RingSize: 2048, Secret: "other"},
+			expConf:       types.MemifConf{Role: "slave", RxQueues: 1, TxQueues: 2, RingSize: 2048, Secret: "other"},
+		},
+		{
+			name:     "leave unset memif settings to defaults",
+			hostConf: types.MemifConf{Role: "master"},
+			expConf:  types.MemifConf{Role: "slave"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-configdata-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			defer os.RemoveAll(sharedDir)
+
+			args := testdata.GetTestArgs()
+			pod := testdata.GetTestPod(sharedDir)
+			netConf := &types.NetConf{
+				HostConf:      types.UserSpaceConf{Engine: "vpp", IfType: "memif", MemifConf: tc.hostConf},
+				ContainerConf: types.UserSpaceConf{MemifConf: tc.containerConf},
+			}
+
+			_, err := SaveRemoteConfig(netConf, args, nil, sharedDir, pod, nil)
+			require.NoError(t, err, "Unexpected error")
+
+			data, err := os.ReadFile(filepath.Join(sharedDir, GetRemoteConfigFileName(args)))
+			require.NoError(t, err, "Can't read saved container data")
+			var configData types.ConfigurationData
+			require.NoError(t, json.Unmarshal(data, &configData), "Can't parse saved container data")
+			assert.Equal(t, tc.expConf, configData.Config.MemifConf, "Unexpected memif settings")
+		})
+	}
+}
+
 func TestCleanupRemoteConfig(t *testing.T) {
 	testCases := []struct {
 		name   string
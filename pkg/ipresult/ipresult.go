@@ -0,0 +1,82 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//
+// This module provides the lookups in the IPAM result of an attachment
+// shared by the host engines.
+//
+
+package ipresult
+
+import (
+	"slices"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+//
+// API Functions
+//
+
+// GetInterfaceIPs() - Return the result index of the named interface and
+//
+//	its IPv4 and IPv6 addresses. The index is -1 if the interface is not found.
+func GetInterfaceIPs(ipResult *current.Result, ifName string) (int, []*current.IPConfig) {
+	var ips []*current.IPConfig
+
+	if ipResult == nil {
+		return -1, ips
+	}
+
+	ifIndex := -1
+	for i, iface := range ipResult.Interfaces {
+		if iface.Name == ifName {
+			ifIndex = i
+		}
+	}
+	if ifIndex < 0 {
+		return ifIndex, ips
+	}
+
+	for _, ipConfig := range ipResult.IPs {
+		if ipConfig.Interface != nil && *ipConfig.Interface != ifIndex {
+			continue
+		}
+		ips = append(ips, ipConfig)
+	}
+
+	return ifIndex, ips
+}
+
+// GetGateways() - Return the IPAM gateways of the named interface, each with
+//
+//	the prefix length of its pod address. A gateway shared by several
+//	addresses is only returned once.
+func GetGateways(ipResult *current.Result, ifName string) []cnitypes.IPNet {
+	var gateways []cnitypes.IPNet
+
+	_, ips := GetInterfaceIPs(ipResult, ifName)
+	for _, ip := range ips {
+		if ip.Gateway == nil {
+			continue
+		}
+		gateway := cnitypes.IPNet{IP: ip.Gateway, Mask: ip.Address.Mask}
+		if !slices.ContainsFunc(gateways, func(g cnitypes.IPNet) bool { return g.IP.Equal(gateway.IP) }) {
+			gateways = append(gateways, gateway)
+		}
+	}
+
+	return gateways
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipresult
+
+import (
+	"net"
+	"testing"
+
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInterfaceIPs(t *testing.T) {
+	ip4 := &current.IPConfig{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}}
+	ip6 := &current.IPConfig{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)}}
+	ipAny := &current.IPConfig{Address: net.IPNet{IP: net.ParseIP("10.0.0.10"), Mask: net.CIDRMask(8, 32)}}
+	ipOther := &current.IPConfig{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("172.16.0.10"), Mask: net.CIDRMask(16, 32)}}
+	ipResult := &current.Result{
+		Interfaces: []*current.Interface{{Name: "eth0"}, {Name: "net1"}},
+		IPs:        []*current.IPConfig{ip4, ip6, ipAny, ipOther},
+	}
+
+	testCases := []struct {
+		name       string
+		ipResult   *current.Result
+		ifName     string
+		expIfIndex int
+		expIPs     []*current.IPConfig
+	}{
+		{
+			name:       "addresses of interface and without interface",
+			ipResult:   ipResult,
+			ifName:     "net1",
+			expIfIndex: 1,
+			expIPs:     []*current.IPConfig{ip4, ip6, ipAny},
+		},
+		{
+			name:       "unknown interface",
+			ipResult:   ipResult,
+			ifName:     "net2",
+			expIfIndex: -1,
+		},
+		{
+			name:       "no result",
+			ifName:     "net1",
+			expIfIndex: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ifIndex, ips := GetInterfaceIPs(tc.ipResult, tc.ifName)
+			assert.Equal(t, tc.expIfIndex, ifIndex, "Unexpected interface index")
+			assert.Equal(t, tc.expIPs, ips, "Unexpected addresses")
+		})
+	}
+}
+
+func TestGetGateways(t *testing.T) {
+	gw4 := net.ParseIP("192.168.1.1")
+	gw6 := net.ParseIP("fd00::1")
+	ipResult := &current.Result{
+		Interfaces: []*current.Interface{{Name: "eth0"}, {Name: "net1"}},
+		IPs: []*current.IPConfig{
+			{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)}, Gateway: gw4},
+			{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("192.168.1.11"), Mask: net.CIDRMask(24, 32)}, Gateway: gw4},
+			{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("fd00::10"), Mask: net.CIDRMask(64, 128)}, Gateway: gw6},
+			{Interface: current.Int(1), Address: net.IPNet{IP: net.ParseIP("10.0.0.10"), Mask: net.CIDRMask(8, 32)}},
+			{Interface: current.Int(0), Address: net.IPNet{IP: net.ParseIP("172.16.0.10"), Mask: net.CIDRMask(16, 32)}, Gateway: net.ParseIP("172.16.0.1")},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		ifName      string
+		expGateways []cnitypes.IPNet
+	}{
+		{
+			name:   "gateways of interface, once each",
+			ifName: "net1",
+			expGateways: []cnitypes.IPNet{
+				{IP: gw4, Mask: net.CIDRMask(24, 32)},
+				{IP: gw6, Mask: net.CIDRMask(64, 128)},
+			},
+		},
+		{
+			name:   "unknown interface",
+			ifName: "net2",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expGateways, GetGateways(ipResult, tc.ifName), "Unexpected gateways")
+		})
+	}
+}
@@ -134,6 +134,17 @@ func GetPod(args *skel.CmdArgs,
 	return pod, kubeClient, err
 }
 
+// GetPodRef returns the namespace and name of the pod from the K8S CNI_ARGS,
+// without querying the API server. Both are empty outside of Kubernetes.
+func GetPodRef(args *skel.CmdArgs) (string, string, error) {
+	k8sArgs, err := getK8sArgs(args)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME), nil
+}
+
 func WritePodAnnotation(kubeClient kubernetes.Interface, pod *v1.Pod) (*v1.Pod, error) {
 	var err error
 
@@ -90,6 +90,46 @@ func TestGetK8sArgs(t *testing.T) {
 	}
 }
 
+func TestGetPodRef(t *testing.T) {
+	testCases := []struct {
+		name         string
+		args         *skel.CmdArgs
+		expNamespace string
+		expName      string
+		expErr       error
+	}{
+		{
+			name:         "pod set in args",
+			args:         &skel.CmdArgs{Args: "IgnoreUnknown=true;K8S_POD_NAME=testpod;K8S_POD_NAMESPACE=testspace"},
+			expNamespace: "testspace",
+			expName:      "testpod",
+		},
+		{
+			name: "args set to empty string",
+			args: &skel.CmdArgs{Args: ""},
+		},
+		{
+			name:   "fail with unknown arg",
+			args:   &skel.CmdArgs{Args: "s0mEArG=anyValue"},
+			expErr: errors.New("ARGS: unknown args"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace, name, err := GetPodRef(tc.args)
+
+			if tc.expErr != nil {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected error")
+			} else {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, tc.expNamespace, namespace, "Unexpected namespace")
+				assert.Equal(t, tc.expName, name, "Unexpected name")
+			}
+		})
+	}
+}
+
 func TestGetK8sClient(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -0,0 +1,145 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//
+// This module provides the handling of the directory the socketfiles of an
+// attachment are created in, shared by the host engines. A long shared
+// directory of a pod is replaced by a short one it is bind mounted to, so
+// the socketfile paths fit in a unix domain socket address.
+//
+
+package shareddir
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/intel/userspace-cni-network-plugin/logging"
+)
+
+//
+// Constants
+//
+
+const (
+	DefaultHostVhostuserBaseDir = "/var/lib/vhost_sockets/"
+)
+
+//
+// API Functions
+//
+
+func GetShortSharedDir(sharedDir string) string {
+	// sun_path for unix domain socket has an array size of 108
+	// When the sharedDir path length is greater than 89 (108 - 19)
+	// 19 is the possible vhostuser socket file name length "/abcdefghijkl-net99" (1 + 12 + 1 + 3 + 2)
+	// FIXME: why shareddir is shortened only in case that it contains "empty-dir"?
+	if len(sharedDir) >= 89 && strings.Contains(sharedDir, "empty-dir") {
+		// Format - /var/lib/kubelet/pods/<podID>/volumes/kubernetes.io~empty-dir/shared-dir
+		parts := strings.Split(sharedDir, "/")
+		// FIXME: it's not safe; can we assure that shareDir with "empty-dir" will always have at least 5 dirs?
+		podID := parts[5]
+		newSharedDir := filepath.Join(DefaultHostVhostuserBaseDir, podID)
+		logging.Infof("GetShortSharedDir: Short shared directory: %s", newSharedDir)
+		return newSharedDir
+	}
+	return sharedDir
+
+}
+
+// Determine if the shared directory is a short one, bind mounted from the
+// shared directory of the pod.
+func IsShortSharedDir(sharedDir string) bool {
+	return strings.Contains(sharedDir, DefaultHostVhostuserBaseDir)
+}
+
+func CreateSharedDir(sharedDir, oldSharedDir string) error {
+	var err error
+
+	_, err = os.Stat(sharedDir)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(sharedDir, 0750)
+		if err != nil {
+			_ = logging.Errorf("CreateSharedDir: Failed to create dir (%s): %v", sharedDir, err)
+			return err
+		}
+
+		if IsShortSharedDir(sharedDir) {
+			logging.Debugf("CreateSharedDir: Mount from %s to %s", oldSharedDir, sharedDir)
+			err = unix.Mount(oldSharedDir, sharedDir, "", unix.MS_BIND, "")
+			if err != nil {
+				_ = logging.Errorf("CreateSharedDir: Failed to bind mount: %s", err)
+				return err
+			}
+		}
+		return nil
+
+	}
+	return err
+}
+
+// Unmount and remove a short shared directory created by CreateSharedDir().
+// A directory already gone is not an error.
+func RemoveShortSharedDir(sharedDir string) error {
+	logging.Debugf("RemoveShortSharedDir: Unmount shared directory: %v", sharedDir)
+	_, err := os.Stat(sharedDir)
+	if os.IsNotExist(err) {
+		_ = logging.Errorf("RemoveShortSharedDir: shared directory %s does not exist to unmount", sharedDir)
+		return nil
+	}
+	err = unix.Unmount(sharedDir, 0)
+	if err != nil {
+		_ = logging.Errorf("Failed to unmount dir: %v", err)
+		return err
+	}
+	err = os.Remove(sharedDir)
+	if err != nil {
+		_ = logging.Errorf("Failed to remove dir: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Give the group access to the shared directory, and to the directory of
+// the short shared directories if it is one.
+func SetSharedDirGroup(sharedDir string, group string) error {
+	groupInfo, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+
+	logging.Debugf("SetSharedDirGroup: group %s's gid is %s", group, groupInfo.Gid)
+	gid, err := strconv.Atoi(groupInfo.Gid)
+	if err != nil {
+		return err
+	}
+
+	if IsShortSharedDir(sharedDir) {
+		err = os.Chown(DefaultHostVhostuserBaseDir, -1, gid)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Chown(sharedDir, -1, gid)
+	if err != nil {
+		return err
+	}
+	return nil
+}
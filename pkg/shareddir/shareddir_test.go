@@ -0,0 +1,199 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shareddir
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+func TestGetShortSharedDir(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sharedDir string
+		expDir    string
+	}{
+		{
+			name:      "return shared dir",
+			sharedDir: "shared-dir",
+			expDir:    "shared-dir",
+		},
+		{
+			name:      "return shared dir with path",
+			sharedDir: "/tmp/var/log/shared-dir",
+			expDir:    "/tmp/var/log/shared-dir",
+		},
+		{
+			name:      "return shared dir with length 102",
+			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/backup-dir/shared-dir",
+			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/backup-dir/shared-dir",
+		},
+		{
+			name:      "return shared dir with empty_dir and length 102",
+			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/empty_dir/shared-dir",
+			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io/empty_dir/shared-dir",
+		},
+		{
+			name:      "return shared dir with empty-dir and length 88",
+			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.~empty-dir",
+			expDir:    "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.~empty-dir",
+		},
+		{
+			name:      "shorten shared dir with empty-dir and length 89",
+			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.i~empty-dir",
+			expDir:    "/var/lib/vhost_sockets/#UUID#",
+		},
+		{
+			name:      "shorten shared dir with empty-dir and length 101",
+			sharedDir: "/var/lib/kubelet/pods/#UUID#/volumes/kubernetes.io~empty-dir/shared-dir",
+			expDir:    "/var/lib/vhost_sockets/#UUID#",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := string(uuid.NewUUID())
+			tc.sharedDir = strings.Replace(tc.sharedDir, "#UUID#", id, -1)
+			tc.expDir = strings.Replace(tc.expDir, "#UUID#", id, -1)
+			shortDir := GetShortSharedDir(tc.sharedDir)
+			assert.Equal(t, tc.expDir, shortDir, "Unexpected result")
+		})
+	}
+}
+
+func TestCreateSharedDir(t *testing.T) {
+	testCases := []struct {
+		name         string
+		sharedDir    string
+		oldSharedDir string
+		expErr       error
+	}{
+		{
+			name:         "shared dir exists",
+			sharedDir:    "#sharedDir#",
+			oldSharedDir: "#sharedDir#",
+			expErr:       nil,
+		},
+		{
+			name:         "fail to create shared dir",
+			sharedDir:    "/proc/broken-shared-dir",
+			oldSharedDir: "/proc/broken-shared-dir",
+			expErr:       errors.New("mkdir "),
+		},
+		{
+			name:         "shared dir in socket dir",
+			sharedDir:    "/var/lib/vhost_sockets/#sharedDirNoPath#",
+			oldSharedDir: "#sharedDir#",
+			expErr:       nil,
+		},
+		{
+			name:         "fail to mount old shared dir to socket dir",
+			sharedDir:    "/var/lib/vhost_sockets/#sharedDirNoPath#",
+			oldSharedDir: "/proc/broken-shared-dir",
+			expErr:       errors.New("no such file"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-shareddir-")
+			sharedDirNoPath := strings.Split(sharedDir, "/")[2]
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDir#", sharedDir, -1)
+			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDirNoPath#", sharedDirNoPath, -1)
+			tc.oldSharedDir = strings.Replace(tc.oldSharedDir, "#sharedDir#", sharedDir, -1)
+			defer os.RemoveAll(sharedDir)
+
+			err := CreateSharedDir(tc.sharedDir, tc.oldSharedDir)
+			if tc.expErr == nil {
+				assert.Equal(t, tc.expErr, err, "Unexpected result")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected result")
+			}
+
+			// cleanup
+			_ = unix.Unmount(tc.sharedDir, 0)
+			os.RemoveAll(tc.sharedDir)
+			os.RemoveAll(tc.oldSharedDir)
+
+		})
+	}
+}
+
+func TestSetSharedDirGroup(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sharedDir string
+		group     string
+		expErr    string
+	}{
+		{
+			name:      "set group",
+			sharedDir: "#sharedDir#",
+			group:     "#group#",
+			expErr:    "",
+		},
+		{
+			name:      "fail to set bad group",
+			sharedDir: "#sharedDir#",
+			group:     "B@DGrO0P!",
+			expErr:    "^(group: unknown group|user: lookup groupname)",
+		},
+		{
+			name:      "fail to set group of broken shared dir",
+			sharedDir: "/proc/broken_shared_dir",
+			group:     "root",
+			expErr:    "^chown /proc/broken_shared_dir",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-shareddir-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			tc.sharedDir = strings.Replace(tc.sharedDir, "#sharedDir#", sharedDir, -1)
+			defer os.RemoveAll(sharedDir)
+
+			// read sharedDir original group to avoid system changes
+			if tc.group == "#group#" {
+				dirInfo, _ := os.Stat(sharedDir)
+				dirSys := dirInfo.Sys().(*syscall.Stat_t)
+				group, _ := user.LookupGroupId(string(rune(48 + dirSys.Gid)))
+				tc.group = group.Name
+			}
+
+			// create default socket dir if needed, so its group can be set
+			if _, err := os.Stat(DefaultHostVhostuserBaseDir); os.IsNotExist(err) {
+				require.NoError(t, os.MkdirAll(DefaultHostVhostuserBaseDir, 0700), "Can't create default socket dir")
+				defer os.RemoveAll(DefaultHostVhostuserBaseDir)
+			}
+
+			err := SetSharedDirGroup(tc.sharedDir, tc.group)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected result")
+			} else {
+				require.Error(t, err, "Unexpected result")
+				assert.Regexp(t, tc.expErr, err.Error(), "Unexpected result")
+			}
+		})
+	}
+}
@@ -27,6 +27,14 @@ type MemifConf struct {
 	// Autogenerated as memif-<ContainerID[:12]>-<IfName>.sock i.e. memif-0958c8871b32-net1.sock
 	// Filename only, no path. Will use if populated, but used to passed filename to container.
 	Socketfile string `json:"socketfile,omitempty"`
+
+	// Optional interface settings. Unset values use the defaults in brackets.
+	RxQueues   int    `json:"rxQueues,omitempty"`   // Number of receive queues (1)
+	TxQueues   int    `json:"txQueues,omitempty"`   // Number of transmit queues (1)
+	RingSize   int    `json:"ringSize,omitempty"`   // Entries per ring, a power of 2 (1024)
+	BufferSize int    `json:"bufferSize,omitempty"` // Size of each buffer in bytes (2048)
+	Id         int    `json:"id,omitempty"`         // Id of the interface on the socket (0)
+	Secret     string `json:"secret,omitempty"`     // Secret the slave must present, up to 24 characters
 }
 
 type VhostConf struct {
@@ -47,6 +55,81 @@ type BridgeConf struct {
 	BridgeName string `json:"bridgeName,omitempty"` // Bridge Name
 	BridgeId   int    `json:"bridgeId,omitempty"`   // Bridge Id - Deprecated in favor of BridgeName
 	VlanId     int    `json:"vlanId,omitempty"`     // Optional VLAN Id
+	// ovs-dpdk specific note:
+	//   By default, 'VlanId' makes the interface an access port of that VLAN.
+	//   If 'Trunks' is set, the interface is a trunk port carrying those VLANs
+	//   tagged, and 'VlanId' (if set) is its native, untagged, VLAN.
+	Trunks []int `json:"trunks,omitempty"` // Optional VLAN Ids of a trunk port
+	// vpp specific note:
+	//   Bridge Domain options, applied when the first interface creates the
+	//   Bridge Domain. Unset options keep the VPP defaults: learning, flooding,
+	//   unknown-unicast flooding and forwarding on, ARP termination and MAC
+	//   aging off. 'MacAge' is in minutes, 0 disables aging. With 'ArpTerm'
+	//   set, the IP/MAC of each pod is added to the ARP termination table.
+	Learn   *bool `json:"learn,omitempty"`   // Optional MAC learning
+	Flood   *bool `json:"flood,omitempty"`   // Optional broadcast flooding
+	UuFlood *bool `json:"uuFlood,omitempty"` // Optional unknown-unicast flooding
+	Forward *bool `json:"forward,omitempty"` // Optional L2 forwarding
+	ArpTerm *bool `json:"arpTerm,omitempty"` // Optional ARP termination
+	MacAge  int   `json:"macAge,omitempty"`  // Optional MAC age in minutes
+	// With NetType "bridge", 'Gateway' gives the bridge the IPAM gateway
+	// addresses, shared by its pods and deleted with the bridge:
+	//   vpp - On a loopback BVI routing the Bridge Domain.
+	//   ovs-dpdk - On an internal port in the host namespace.
+	Gateway bool `json:"gateway,omitempty"` // Optional gateway
+}
+
+type XconnectConf struct {
+	// Peer the interface is cross-connected to with NetType "xconnect", one of:
+	//   Interface - Name of a VPP interface, like an uplink.
+	//   Pod, IfName - Interface IfName of another pod attached to the same
+	//     VPP. Pod is "namespace/name", or "name" in the namespace of the pod.
+	Interface string `json:"interface,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	IfName    string `json:"ifName,omitempty"`
+}
+
+type OvsConf struct {
+	// Backend used to configure OvS:
+	//   "vsctl" - (default) Fork ovs-vsctl for each request.
+	//   "ovsdb" - Talk to ovsdb-server directly over its unix socket (RFC 7047).
+	Backend     string `json:"backend,omitempty"`
+	OvsdbSocket string `json:"ovsdbSocket,omitempty"` // ovsdb-server socket, defaults to /usr/local/var/run/openvswitch/db.sock
+	// Only let the vhost port send from the MAC and IPAM addresses of the
+	// pod, plus ARP/ND for them. The MAC is returned in the result.
+	AntiSpoof bool `json:"antiSpoof,omitempty"`
+}
+
+type VppConf struct {
+	// FIB table (VRF) the interface is bound to with NetType "interface".
+	// Created when the first interface joins and deleted with the last one.
+	// Defaults to VPP's default table 0.
+	Vrf uint32 `json:"vrf,omitempty"`
+	// Path of the VPP binary API socket. Defaults to govpp's default,
+	// "/run/vpp/api.sock".
+	ApiSocket string `json:"apiSocket,omitempty"`
+	// API socket of the VPP instance serving each NUMA node, keyed by node.
+	// When set, the socket is chosen from the "userspace/numa-node"
+	// annotation of the pod, falling back to ApiSocket without it.
+	NumaApiSockets map[string]string `json:"numaApiSockets,omitempty"`
+}
+
+type AclConf struct {
+	// Rules filtering the traffic of the pod interface, seen from the pod:
+	// Ingress is the traffic sent to the pod, Egress the traffic sent by the
+	// pod. The rules of a direction are matched in order and the first match
+	// wins. Once a direction has rules, traffic matching none is denied.
+	Ingress []AclRule `json:"ingress,omitempty"`
+	Egress  []AclRule `json:"egress,omitempty"`
+}
+
+type AclRule struct {
+	Action   string `json:"action"`             // Action of a matching packet: allow|deny
+	Src      string `json:"src,omitempty"`      // Optional source prefix, any address by default
+	Dst      string `json:"dst,omitempty"`      // Optional destination prefix, any address by default
+	Proto    string `json:"proto,omitempty"`    // Optional protocol: tcp|udp|icmp|icmpv6|<number>, any by default
+	SrcPorts string `json:"srcPorts,omitempty"` // Optional tcp|udp source port "<port>" or range "<first>-<last>"
+	DstPorts string `json:"dstPorts,omitempty"` // Optional tcp|udp destination port "<port>" or range "<first>-<last>"
 }
 
 type UserSpaceConf struct {
@@ -54,22 +137,21 @@ type UserSpaceConf struct {
 	// is not provided. However, they are not required to be the same and a Container
 	// attribute can be provided to override. All values are listed as 'omitempty' to
 	// allow the Container struct to be empty where desired.
-	Engine     string     `json:"engine,omitempty"`  // CNI Implementation {vpp|ovs-dpdk}
-	IfType     string     `json:"iftype,omitempty"`  // Type of interface {memif|vhostuser}
-	NetType    string     `json:"netType,omitempty"` // Interface network type {none|bridge|interface}
-	MemifConf  MemifConf  `json:"memif,omitempty"`
-	VhostConf  VhostConf  `json:"vhost,omitempty"`
-	BridgeConf BridgeConf `json:"bridge,omitempty"`
+	Engine       string        `json:"engine,omitempty"`  // CNI Implementation {vpp|ovs-dpdk}
+	IfType       string        `json:"iftype,omitempty"`  // Type of interface {memif|vhostuser}
+	NetType      string        `json:"netType,omitempty"` // Interface network type {none|bridge|interface|xconnect}
+	MemifConf    MemifConf     `json:"memif,omitempty"`
+	VhostConf    VhostConf     `json:"vhost,omitempty"`
+	BridgeConf   BridgeConf    `json:"bridge,omitempty"`
+	XconnectConf *XconnectConf `json:"xconnect,omitempty"`
 }
 
 type NetConf struct {
 	types.NetConf
 
-	/*
-		// Support chaining
-		RawPrevResult *map[string]interface{} `json:"prevResult"`
-		PrevResult    *current.Result         `json:"-"`
-	*/
+	// Support chaining. The raw "prevResult" is parsed into the embedded
+	// types.NetConf.RawPrevResult, and converted by LoadNetConf().
+	PrevResult *current.Result `json:"-"`
 
 	// One of the following two must be provided: KubeConfig or SharedDir
 	//
@@ -110,6 +192,13 @@ type NetConf struct {
 	Name          string        `json:"name"`
 	HostConf      UserSpaceConf `json:"host,omitempty"`
 	ContainerConf UserSpaceConf `json:"container,omitempty"`
+
+	// Engine specific settings for the host
+	OvsConf OvsConf `json:"ovs,omitempty"`
+	VppConf VppConf `json:"vpp,omitempty"`
+
+	// Traffic filter of the host interface, only supported by vpp
+	AclConf AclConf `json:"acl,omitempty"`
 }
 
 // Defines the JSON data written to container. It is either written to:
@@ -125,3 +214,9 @@ type ConfigurationData struct {
 }
 
 const DefaultSwIfIndex = 4294967295 // vpp default interface id, used when querying bridges
+
+// CNI STATUS error codes (CNI Spec 1.1), not yet defined in the CNI library.
+const (
+	ErrPluginNotAvailable  uint = 50 // Plugin cannot service ADD requests
+	ErrLimitedConnectivity uint = 51 // Plugin can service ADD requests, but with limited connectivity
+)
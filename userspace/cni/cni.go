@@ -15,6 +15,7 @@
 package cni
 
 import (
+	"context"
 	"encoding/json"
 	_ "flag"
 	"fmt"
@@ -27,17 +28,21 @@ import (
 	"github.com/containernetworking/cni/pkg/skel"
 	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
+	cniSpecVersion "github.com/containernetworking/cni/pkg/version"
 	"github.com/containernetworking/plugins/pkg/ip"
 	"github.com/containernetworking/plugins/pkg/ipam"
 	"github.com/containernetworking/plugins/pkg/ns"
 
-	"github.com/intel/userspace-cni-network-plugin/cniovs"
-	"github.com/intel/userspace-cni-network-plugin/cnivpp"
 	"github.com/intel/userspace-cni-network-plugin/logging"
 	"github.com/intel/userspace-cni-network-plugin/pkg/annotations"
 	"github.com/intel/userspace-cni-network-plugin/pkg/configdata"
 	"github.com/intel/userspace-cni-network-plugin/pkg/k8sclient"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/intel/userspace-cni-network-plugin/usrspcni"
+
+	// Engines register themselves with usrspcni on init()
+	_ "github.com/intel/userspace-cni-network-plugin/cniovs"
+	_ "github.com/intel/userspace-cni-network-plugin/cnivpp"
 
 	_ "github.com/vishvananda/netlink"
 )
@@ -69,27 +74,42 @@ func LoadNetConf(bytes []byte) (*types.NetConf, error) {
 	//
 	// Parse previous result
 	//
-	/*
-		if netconf.RawPrevResult != nil {
-			resultBytes, err := json.Marshal(netconf.RawPrevResult)
-			if err != nil {
-				return nil, logging.Errorf("could not serialize prevResult: %v", err)
-			}
-			res, err := cniSpecVersion.NewResult(netconf.CNIVersion, resultBytes)
-			if err != nil {
-				return nil, logging.Errorf("could not parse prevResult: %v", err)
-			}
-			netconf.RawPrevResult = nil
-			netconf.PrevResult, err = current.NewResultFromResult(res)
-			if err != nil {
-				return nil, logging.Errorf("could not convert result to current version: %v", err)
-			}
+	if netconf.RawPrevResult != nil {
+		resultBytes, err := json.Marshal(netconf.RawPrevResult)
+		if err != nil {
+			return nil, logging.Errorf("could not serialize prevResult: %v", err)
 		}
-	*/
+		res, err := cniSpecVersion.NewResult(netconf.CNIVersion, resultBytes)
+		if err != nil {
+			return nil, logging.Errorf("could not parse prevResult: %v", err)
+		}
+		netconf.RawPrevResult = nil
+		netconf.PrevResult, err = current.NewResultFromResult(res)
+		if err != nil {
+			return nil, logging.Errorf("could not convert result to current version: %v", err)
+		}
+	}
 
 	return netconf, nil
 }
 
+// initResult() - Return the Result to build on.
+//
+//	When chained behind other plugins, their result is copied so it is
+//	returned as well.
+func initResult(prevResult *current.Result) *current.Result {
+	result := &current.Result{}
+
+	if prevResult != nil {
+		result.Interfaces = append(result.Interfaces, prevResult.Interfaces...)
+		result.IPs = append(result.IPs, prevResult.IPs...)
+		result.Routes = append(result.Routes, prevResult.Routes...)
+		result.DNS = prevResult.DNS
+	}
+
+	return result
+}
+
 func GetPodAndSharedDir(netConf *types.NetConf,
 	args *skel.CmdArgs,
 	kubeClient kubernetes.Interface) (kubernetes.Interface, *v1.Pod, string, error) {
@@ -147,10 +167,7 @@ func GetPodAndSharedDir(netConf *types.NetConf,
 
 func CmdAdd(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
 	var netConf *types.NetConf
-	var containerEngine string
-
-	vpp := cnivpp.CniVpp{}
-	ovs := cniovs.CniOvs{}
+	var containerEngineName string
 
 	// Convert the input bytestream into local NetConf structure
 	netConf, err := LoadNetConf(args.StdinData)
@@ -174,11 +191,21 @@ func CmdAdd(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interfac
 	}
 	defer netns.Close()
 
-	result := &current.Result{}
-	result.Interfaces = []*current.Interface{{
+	// When chained behind other plugins, build on the result they returned.
+	// The interface created here is appended to theirs.
+	result := initResult(netConf.PrevResult)
+	ifIndex := len(result.Interfaces)
+	result.Interfaces = append(result.Interfaces, &current.Interface{
 		Name:    args.IfName,
 		Sandbox: netns.Path(),
-	}}
+	})
+
+	// IPs an earlier plugin assigned without an interface belong to this one
+	for _, ipConfig := range result.IPs {
+		if ipConfig.Interface == nil {
+			ipConfig.Interface = current.Int(ifIndex)
+		}
+	}
 
 	// Retrieve the "SharedDir", directory to create the socketfile in.
 	// Save off kubeClient and pod for later use if needed.
@@ -188,28 +215,26 @@ func CmdAdd(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interfac
 		return err
 	}
 
-	//
-	// HOST:
-	//
-
-	// Add the requested interface and network
-	if netConf.HostConf.Engine == "vpp" {
-		err = vpp.AddOnHost(netConf, args, kubeClient, sharedDir, result)
-	} else if netConf.HostConf.Engine == "ovs-dpdk" {
-		err = ovs.AddOnHost(netConf, args, kubeClient, sharedDir, result)
-	} else {
-		err = fmt.Errorf("ERROR: Unknown Host Engine:" + netConf.HostConf.Engine)
-	}
-	if err != nil {
-		_ = logging.Errorf("cmdAdd: Host ERROR - %v", err)
-		return err
-	}
+	// Each completed step pushes its undo. If a later step fails, they are
+	// run in reverse order so a failed ADD leaves the node as it was. Steps
+	// clean up their own partial work before returning an error.
+	var undo []func()
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}()
 
 	//
-	// CONTAINER:
+	// IPAM:
 	//
 
-	// Get IPAM data for Container Interface, if provided.
+	// Get IPAM data for Container Interface, if provided. Done before the
+	// HOST, so the host interface can be configured with the addresses.
 	if netConf.IPAM.Type != "" {
 
 		// run the IPAM plugin and get back the config to apply
@@ -218,82 +243,236 @@ func CmdAdd(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interfac
 			_ = logging.Errorf("cmdAdd: IPAM ERROR - %v", err)
 			return err
 		}
+		undo = append(undo, func() {
+			if err := ipam.ExecDel(netConf.IPAM.Type, args.StdinData); err != nil {
+				_ = logging.Errorf("cmdAdd: IPAM rollback ERROR - %v", err)
+			}
+		})
 
 		// Convert whatever the IPAM result was into the current Result type
 		newResult, err := current.NewResultFromResult(ipamResult)
 		if err != nil {
-			// TBD: CLEAN-UP
 			_ = logging.Errorf("cmdAdd: IPAM Result ERROR - %v", err)
 			return err
 		}
 
 		if len(newResult.IPs) == 0 {
-			// TBD: CLEAN-UP
 			err = fmt.Errorf("ERROR: Unable to get IP Address")
 			_ = logging.Errorf("cmdAdd: IPAM ERROR - %v", err)
 			return err
 		}
 
-		newResult.Interfaces = result.Interfaces
-		//newResult.Interfaces[0].Mac = macAddr
+		// Keep the Gateway set by IPAM, engines route through it
+		for _, ipConfig := range newResult.IPs {
+			ipConfig.Interface = current.Int(ifIndex)
+		}
 
-		// Clear out the Gateway if set by IPAM, not being used.
-		for _, ip := range newResult.IPs {
-			ip.Gateway = nil
+		// Merge with the IPs and routes of any previous plugin
+		result.IPs = append(result.IPs, newResult.IPs...)
+		result.Routes = append(result.Routes, newResult.Routes...)
+		if len(newResult.DNS.Nameservers) != 0 || newResult.DNS.Domain != "" ||
+			len(newResult.DNS.Search) != 0 || len(newResult.DNS.Options) != 0 {
+			result.DNS = newResult.DNS
 		}
+	}
+
+	//
+	// HOST:
+	//
 
-		result = newResult
+	// Add the requested interface and network
+	hostEngine, err := usrspcni.GetEngine("Host", netConf.HostConf.Engine)
+	if err == nil {
+		err = hostEngine.AddOnHost(netConf, args, kubeClient, sharedDir, result)
 	}
+	if err != nil {
+		_ = logging.Errorf("cmdAdd: Host ERROR - %v", err)
+		return err
+	}
+	undo = append(undo, func() {
+		if err := hostEngine.DelFromHost(netConf, args, sharedDir); err != nil {
+			_ = logging.Errorf("cmdAdd: Host rollback ERROR - %v", err)
+		}
+	})
+
+	//
+	// CONTAINER:
+	//
 
 	// Determine the Engine that will process the request. Default to host
 	// if not provided.
 	if netConf.ContainerConf.Engine != "" {
-		containerEngine = netConf.ContainerConf.Engine
+		containerEngineName = netConf.ContainerConf.Engine
 	} else {
-		containerEngine = netConf.HostConf.Engine
+		containerEngineName = netConf.HostConf.Engine
 	}
 
 	// Add the requested interface and network
-	if containerEngine == "vpp" {
-		_, err = vpp.AddOnContainer(netConf, args, kubeClient, sharedDir, pod, result)
-	} else if containerEngine == "ovs-dpdk" {
-		_, err = ovs.AddOnContainer(netConf, args, kubeClient, sharedDir, pod, result)
-	} else {
-		err = fmt.Errorf("ERROR: Unknown Container Engine:" + containerEngine)
+	containerEngine, err := usrspcni.GetEngine("Container", containerEngineName)
+	if err == nil {
+		_, err = containerEngine.AddOnContainer(netConf, args, kubeClient, sharedDir, pod, result)
 	}
 	if err != nil {
 		_ = logging.Errorf("cmdAdd: Container ERROR - %v", err)
 		return err
 	}
 
-	return cnitypes.PrintResult(result, current.ImplementedSpecVersion)
+	err = cnitypes.PrintResult(result, current.ImplementedSpecVersion)
+	if err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
 }
 
-func CmdGet(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
-	/*
-		netConf, err := LoadNetConf(args.StdinData)
+func CmdCheck(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
+	var netConf *types.NetConf
+
+	// Convert the input bytestream into local NetConf structure
+	netConf, err := LoadNetConf(args.StdinData)
+
+	logging.Infof("cmdCheck: ENTER (AFTER LOAD) - Container %s Iface %s", args.ContainerID[:12], args.IfName)
+	logging.Verbosef("   Args=%v netConf=%v, exec=%v, kubeClient%v",
+		args, netConf, exec, kubeClient)
+
+	if err != nil {
+		_ = logging.Errorf("cmdCheck: Parse NetConf - %v", err)
+		return err
+	}
+
+	// Retrieve the "SharedDir", directory the socketfile was created in.
+	_, _, sharedDir, err := GetPodAndSharedDir(netConf, args, kubeClient)
+	if err != nil {
+		_ = logging.Errorf("cmdCheck: Unable to determine \"SharedDir\" - %v", err)
+		return err
+	}
 
-		logging.Infof("cmdGet: (AFTER LOAD) - Container %s Iface %s", args.ContainerID[:12], args.IfName)
-		logging.Verbosef("   Args=%v netConf=%v, exec=%v, kubeClient%v",
-			args, netConf, exec, kubeClient)
+	//
+	// HOST:
+	//
 
+	// Verify the interface and network created by cmdAdd() are still intact
+	hostEngine, err := usrspcni.GetEngine("Host", netConf.HostConf.Engine)
+	if err == nil {
+		err = hostEngine.CheckOnHost(netConf, args, sharedDir)
+	} else {
+		err = cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, err.Error(), "")
+	}
+	if err != nil {
+		_ = logging.Errorf("cmdCheck: Host ERROR - %v", err)
+		return err
+	}
+
+	//
+	// Check IPAM data, if provided.
+	//
+	if netConf.IPAM.Type != "" {
+		err = ipam.ExecCheck(netConf.IPAM.Type, args.StdinData)
 		if err != nil {
+			_ = logging.Errorf("cmdCheck: IPAM ERROR - %v", err)
 			return err
 		}
+	}
 
-		// FIXME: call all delegates
+	return nil
+}
+
+func CmdGC(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
+	var netConf *types.NetConf
+
+	// Convert the input bytestream into local NetConf structure
+	netConf, err := LoadNetConf(args.StdinData)
+
+	// GC is not tied to a container, so there is no ContainerID or IfName
+	logging.Infof("cmdGC: ENTER (AFTER LOAD)")
+	logging.Verbosef("   Args=%v netConf=%v, exec=%v, kubeClient%v",
+		args, netConf, exec, kubeClient)
+
+	if err != nil {
+		_ = logging.Errorf("cmdGC: Parse NetConf - %v", err)
+		return err
+	}
+
+	//
+	// HOST and CONTAINER:
+	//
+
+	// Delete every attachment of this network not in the valid list
+	hostEngine, err := usrspcni.GetEngine("Host", netConf.HostConf.Engine)
+	if err == nil {
+		err = hostEngine.GarbageCollect(netConf, netConf.ValidAttachments)
+	} else {
+		err = cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, err.Error(), "")
+	}
+	if err != nil {
+		_ = logging.Errorf("cmdGC: Host ERROR - %v", err)
+		return err
+	}
+
+	//
+	// Garbage collect IPAM data, if provided.
+	//
+	if netConf.IPAM.Type != "" {
+		err = invoke.DelegateGC(context.TODO(), netConf.IPAM.Type, args.StdinData, nil)
+		if err != nil {
+			_ = logging.Errorf("cmdGC: IPAM ERROR - %v", err)
+			return err
+		}
+	}
 
-		return cnitypes.PrintResult(netConf.PrevResult, netConf.CNIVersion)
-	*/
 	return nil
 }
 
-func CmdDel(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
+func CmdStatus(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
 	var netConf *types.NetConf
-	var containerEngine string
 
-	vpp := cnivpp.CniVpp{}
-	ovs := cniovs.CniOvs{}
+	// Convert the input bytestream into local NetConf structure
+	netConf, err := LoadNetConf(args.StdinData)
+
+	// STATUS is not tied to a container, so there is no ContainerID or IfName
+	logging.Infof("cmdStatus: ENTER (AFTER LOAD)")
+	logging.Verbosef("   Args=%v netConf=%v, exec=%v, kubeClient%v",
+		args, netConf, exec, kubeClient)
+
+	if err != nil {
+		_ = logging.Errorf("cmdStatus: Parse NetConf - %v", err)
+		return err
+	}
+
+	//
+	// HOST:
+	//
+
+	// Verify the data plane is usable
+	hostEngine, err := usrspcni.GetEngine("Host", netConf.HostConf.Engine)
+	if err == nil {
+		err = hostEngine.Status(netConf)
+	} else {
+		err = cnitypes.NewError(cnitypes.ErrInvalidNetworkConfig, err.Error(), "")
+	}
+	if err != nil {
+		_ = logging.Errorf("cmdStatus: Host ERROR - %v", err)
+		return err
+	}
+
+	//
+	// Check IPAM status, if provided.
+	//
+	if netConf.IPAM.Type != "" {
+		err = invoke.DelegateStatus(context.TODO(), netConf.IPAM.Type, args.StdinData, nil)
+		if err != nil {
+			_ = logging.Errorf("cmdStatus: IPAM ERROR - %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func CmdDel(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interface) error {
+	var netConf *types.NetConf
+	var containerEngineName string
 
 	// Convert the input bytestream into local NetConf structure
 	netConf, err := LoadNetConf(args.StdinData)
@@ -320,12 +499,9 @@ func CmdDel(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interfac
 	//
 
 	// Delete the requested interface
-	if netConf.HostConf.Engine == "vpp" {
-		err = vpp.DelFromHost(netConf, args, sharedDir)
-	} else if netConf.HostConf.Engine == "ovs-dpdk" {
-		err = ovs.DelFromHost(netConf, args, sharedDir)
-	} else {
-		err = fmt.Errorf("ERROR: Unknown Host Engine:" + netConf.HostConf.Engine)
+	hostEngine, err := usrspcni.GetEngine("Host", netConf.HostConf.Engine)
+	if err == nil {
+		err = hostEngine.DelFromHost(netConf, args, sharedDir)
 	}
 	if err != nil {
 		_ = logging.Errorf("cmdDel: Host ERROR - %v", err)
@@ -339,18 +515,15 @@ func CmdDel(args *skel.CmdArgs, exec invoke.Exec, kubeClient kubernetes.Interfac
 	// Determine the Engine that will process the request. Default to host
 	// if not provided.
 	if netConf.ContainerConf.Engine != "" {
-		containerEngine = netConf.ContainerConf.Engine
+		containerEngineName = netConf.ContainerConf.Engine
 	} else {
-		containerEngine = netConf.HostConf.Engine
+		containerEngineName = netConf.HostConf.Engine
 	}
 
 	// Delete the requested interface
-	if containerEngine == "vpp" {
-		err = vpp.DelFromContainer(netConf, args, sharedDir, pod)
-	} else if containerEngine == "ovs-dpdk" {
-		err = ovs.DelFromContainer(netConf, args, sharedDir, pod)
-	} else {
-		err = fmt.Errorf("ERROR: Unknown Container Engine:" + containerEngine)
+	containerEngine, err := usrspcni.GetEngine("Container", containerEngineName)
+	if err == nil {
+		err = containerEngine.DelFromContainer(netConf, args, sharedDir, pod)
 	}
 	if err != nil {
 		_ = logging.Errorf("cmdDel: Container ERROR - %v", err)
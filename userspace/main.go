@@ -36,9 +36,13 @@ func main() {
 			Del: func(args *skel.CmdArgs) error { return cni.CmdDel(args, nil, nil) },
 
 			Check: func(args *skel.CmdArgs) error {
-				return cni.CmdGet(args, nil, nil)
+				return cni.CmdCheck(args, nil, nil)
 			},
-			GC:     nil,
-			Status: nil},
+			GC: func(args *skel.CmdArgs) error {
+				return cni.CmdGC(args, nil, nil)
+			},
+			Status: func(args *skel.CmdArgs) error {
+				return cni.CmdStatus(args, nil, nil)
+			}},
 		cniversion.All, "USERSPACE CNI Plugin")
 }
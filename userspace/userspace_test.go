@@ -26,17 +26,28 @@ import (
 	"testing"
 
 	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/testutils"
 	"github.com/intel/userspace-cni-network-plugin/cniovs"
+	vppinfra "github.com/intel/userspace-cni-network-plugin/cnivpp/api/infra"
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
 	"github.com/intel/userspace-cni-network-plugin/userspace/cni"
 	"github.com/intel/userspace-cni-network-plugin/userspace/testdata"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.fd.io/govpp"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
+func TestMain(m *testing.M) {
+	// Without VPP, fail at once instead of waiting for it to come back
+	vppinfra.SetConnect(govpp.Connect, 0)
+	os.Exit(m.Run())
+}
+
 func TestLoadNetConf(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -133,6 +144,65 @@ func TestLoadNetConf(t *testing.T) {
 	}
 }
 
+func TestLoadNetConfPrevResult(t *testing.T) {
+	testCases := []struct {
+		name       string
+		netConfStr string
+		expIPs     []string
+		expRoutes  []string
+		expErr     string
+	}{
+		{
+			name:       "load netConf without prevResult",
+			netConfStr: `{"cniVersion":"1.0.0","name":"net1"}`,
+		},
+		{
+			name:       "load netConf with prevResult",
+			netConfStr: `{"cniVersion":"1.0.0","name":"net1","prevResult":{"cniVersion":"1.0.0","ips":[{"address":"10.1.1.2/24"},{"address":"fd00::2/64"}],"routes":[{"dst":"10.2.0.0/16"}]}}`,
+			expIPs:     []string{"10.1.1.2/24", "fd00::2/64"},
+			expRoutes:  []string{"10.2.0.0/16"},
+		},
+		{
+			name:       "convert prevResult of older version",
+			netConfStr: `{"cniVersion":"0.3.1","name":"net1","prevResult":{"cniVersion":"0.3.1","ips":[{"version":"4","address":"10.1.1.2/24"}]}}`,
+			expIPs:     []string{"10.1.1.2/24"},
+		},
+		{
+			name:       "fail to parse prevResult",
+			netConfStr: `{"cniVersion":"1.0.0","name":"net1","prevResult":{"ips":[{"address":"bad-address"}]}}`,
+			expErr:     "could not parse prevResult:",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			netConf, err := cni.LoadNetConf([]byte(tc.netConfStr))
+
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error returned")
+				return
+			}
+			require.NoError(t, err, "Unexpected error returned")
+			assert.Nil(t, netConf.RawPrevResult, "Raw prevResult not consumed")
+			if tc.expIPs == nil {
+				assert.Nil(t, netConf.PrevResult, "Unexpected prevResult")
+				return
+			}
+			require.NotNil(t, netConf.PrevResult, "prevResult not parsed")
+
+			var ips, routes []string
+			for _, ipConfig := range netConf.PrevResult.IPs {
+				ips = append(ips, ipConfig.Address.String())
+			}
+			for _, route := range netConf.PrevResult.Routes {
+				routes = append(routes, route.Dst.String())
+			}
+			assert.Equal(t, tc.expIPs, ips, "Unexpected prevResult IPs")
+			assert.Equal(t, tc.expRoutes, routes, "Unexpected prevResult routes")
+		})
+	}
+}
+
 func TestGetPodAndSharedDir(t *testing.T) {
 	args := testdata.GetTestArgs()
 	pod := testdata.GetTestPod("")
@@ -219,7 +289,9 @@ func TestCmdAdd(t *testing.T) {
 		netConfStr string
 		netNS      string
 		expError   string
-		expJSONKey string // a mandatory key in valid JSON output
+		expJSONKey string   // a mandatory key in valid JSON output
+		expIPs     []string // <address>@<interface index> in the JSON output
+		expRoutes  []string // route destinations in the JSON output
 		fakeExec   bool
 		fakeErr    error
 	}{
@@ -236,9 +308,15 @@ func TestCmdAdd(t *testing.T) {
 			expError:   "failed to open netns",
 		},
 		{
-			name:       "fail to connect to vpp",
+			name:       "fail vpp without netType",
 			netConfStr: `{"host":{"engine":"vpp"},"sharedDir":"#sharedDir#"}`,
 			netNS:      "generate",
+			expError:   "ERROR: NetType must be provided",
+		},
+		{
+			name:       "fail to connect to vpp",
+			netConfStr: `{"host":{"engine":"vpp","netType":"bridge"},"sharedDir":"#sharedDir#"}`,
+			netNS:      "generate",
 			expError:   "VPP API socket file /run/vpp/api.sock does not exist",
 		},
 		{
@@ -285,6 +363,15 @@ func TestCmdAdd(t *testing.T) {
 			expJSONKey: "cniVersion",
 			fakeExec:   true,
 		},
+		{
+			name:       "merge prevResult into result",
+			netConfStr: `{"cniVersion":"1.0.0","prevResult":{"cniVersion":"1.0.0","interfaces":[{"name":"eth0"}],"ips":[{"address":"10.1.1.2/24","interface":0},{"address":"10.3.3.3/24"}],"routes":[{"dst":"10.2.0.0/16"}]},"host":{"engine":"ovs-dpdk","iftype":"vhostuser","vhost":{"mode":"client"}},"sharedDir":"#sharedDir#"}`,
+			netNS:      "generate",
+			expJSONKey: "cniVersion",
+			expIPs:     []string{"10.1.1.2/24@0", "10.3.3.3/24@1"},
+			expRoutes:  []string{"10.2.0.0/16"},
+			fakeExec:   true,
+		},
 		{
 			name:       "fail when CNI command is not set",
 			netConfStr: `{"ipam":{"type":"host-local"},"host":{"engine":"ovs-dpdk","iftype":"vhostuser","vhost":{"mode":"client"}},"sharedDir":"#sharedDir#"}`,
@@ -358,6 +445,28 @@ func TestCmdAdd(t *testing.T) {
 				require.NoError(t, json.Unmarshal([]byte(stdOut), &jsonOut), "Invalid JSON in output")
 				assert.Contains(t, jsonOut, tc.expJSONKey)
 			}
+			if tc.expIPs != nil {
+				var result current.Result
+				var ips, routes []string
+				require.NoError(t, json.Unmarshal([]byte(stdOut), &result), "Invalid result in output")
+				for _, ipConfig := range result.IPs {
+					ips = append(ips, fmt.Sprintf("%s@%d", ipConfig.Address.String(), *ipConfig.Interface))
+				}
+				for _, route := range result.Routes {
+					routes = append(routes, route.Dst.String())
+				}
+				assert.Equal(t, tc.expIPs, ips, "Unexpected IPs in result")
+				assert.Equal(t, tc.expRoutes, routes, "Unexpected routes in result")
+				assert.Equal(t, args.IfName, result.Interfaces[len(result.Interfaces)-1].Name, "Interface not appended to result")
+			}
+
+			// a failed ADD must not leave saved data behind
+			readErr := cniovs.ReadConfig(&types.NetConf{}, args, &cniovs.OvsSavedData{})
+			if tc.expError == "" {
+				assert.NoError(t, readErr, "Saved data not found")
+			} else {
+				assert.Error(t, readErr, "Saved data not rolled back")
+			}
 
 			// remove termporary files by reading saved data
 			var data cniovs.OvsSavedData
@@ -366,13 +475,233 @@ func TestCmdAdd(t *testing.T) {
 	}
 }
 
-func TestCmdGet(t *testing.T) {
-	t.Run("test placeholder until GetCmd will be implemented", func(t *testing.T) {
-		var exec invoke.Exec
-		args := testdata.GetTestArgs()
-		kubeClient := fake.NewSimpleClientset()
-		assert.NoError(t, cni.CmdGet(args, exec, kubeClient), "Unexpected error")
-	})
+func TestCmdCheck(t *testing.T) {
+	testCases := []struct {
+		name       string
+		netConfStr string
+		savedData  string
+		fakeOut    []byte
+		expError   string
+		expCode    uint
+	}{
+		{
+			name:       "fail to parse netConf",
+			netConfStr: "{",
+			expError:   "failed to load netconf:",
+		},
+		{
+			name:       "fail with unknown host engine",
+			netConfStr: `{"host":{"engine":"nonsense"},"sharedDir":"#sharedDir#"}`,
+			expError:   "ERROR: Unknown Host Engine:nonsense",
+			expCode:    cnitypes.ErrInvalidNetworkConfig,
+		},
+		{
+			name:       "fail without saved data",
+			netConfStr: `{"host":{"engine":"ovs-dpdk","iftype":"vhostuser","vhost":{"mode":"client"}},"sharedDir":"#sharedDir#"}`,
+			expError:   "OVS attachment not found",
+		},
+		{
+			name:       "fail when port moved to another bridge",
+			netConfStr: `{"host":{"engine":"ovs-dpdk","iftype":"vhostuser","vhost":{"mode":"client"}},"sharedDir":"#sharedDir#"}`,
+			savedData:  `{"vhostname":"vhost0"}`,
+			fakeOut:    []byte("br1\n"),
+			expError:   "OVS port vhost0 is on bridge br1, expected br0",
+		},
+		{
+			name:       "check ovs-dpdk attachment",
+			netConfStr: `{"host":{"engine":"ovs-dpdk","iftype":"vhostuser","vhost":{"mode":"client"}},"sharedDir":"#sharedDir#"}`,
+			savedData:  `{"vhostname":"vhost0"}`,
+			fakeOut:    []byte("br0\n"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exec invoke.Exec
+			args := testdata.GetTestArgs()
+
+			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-userspace-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			tc.netConfStr = strings.Replace(tc.netConfStr, "#sharedDir#", sharedDir, -1)
+			defer os.RemoveAll(sharedDir)
+
+			if tc.savedData != "" {
+				var data cniovs.OvsSavedData
+				require.NoError(t, json.Unmarshal([]byte(tc.savedData), &data), "Invalid saved data")
+				require.NoError(t, cniovs.SaveConfig(nil, args, &data), "Can't save test data")
+				defer func() {
+					// CmdCheck() must leave saved data in place, remove it here
+					var data cniovs.OvsSavedData
+					assert.NoError(t, cniovs.ReadConfig(nil, args, &data), "Saved data removed by check")
+					assert.NoError(t, cniovs.LoadConfig(nil, args, &data))
+				}()
+			}
+
+			kubeClient := fake.NewSimpleClientset()
+			args.StdinData = []byte(tc.netConfStr)
+
+			cniovs.SetExecCommand(&cniovs.FakeExecCommand{Out: tc.fakeOut})
+			defer cniovs.SetDefaultExecCommand()
+
+			err := cni.CmdCheck(args, exec, kubeClient)
+
+			if tc.expError == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected error")
+				assert.Contains(t, err.Error(), tc.expError, "Unexpected error")
+				if tc.expCode != 0 {
+					var cniErr *cnitypes.Error
+					require.ErrorAs(t, err, &cniErr, "Unexpected error type")
+					assert.Equal(t, tc.expCode, cniErr.Code, "Unexpected error code")
+				}
+			}
+		})
+	}
+}
+
+func TestCmdGC(t *testing.T) {
+	testCases := []struct {
+		name       string
+		netConfStr string
+		expError   string
+		expCode    uint
+		expRemoved bool
+	}{
+		{
+			name:       "fail to parse netConf",
+			netConfStr: "{",
+			expError:   "failed to load netconf:",
+		},
+		{
+			name:       "fail with unknown host engine",
+			netConfStr: `{"name":"#netName#","host":{"engine":"nonsense"}}`,
+			expError:   "ERROR: Unknown Host Engine:nonsense",
+			expCode:    cnitypes.ErrInvalidNetworkConfig,
+		},
+		{
+			name:       "keep valid attachment",
+			netConfStr: `{"name":"#netName#","host":{"engine":"ovs-dpdk","iftype":"vhostuser"},"cni.dev/valid-attachments":[{"containerID":"#containerID#","ifname":"#ifName#"}]}`,
+			expRemoved: false,
+		},
+		{
+			name:       "keep attachment of another network",
+			netConfStr: `{"name":"other-network","host":{"engine":"ovs-dpdk","iftype":"vhostuser"}}`,
+			expRemoved: false,
+		},
+		{
+			name:       "remove stale attachment",
+			netConfStr: `{"name":"#netName#","host":{"engine":"ovs-dpdk","iftype":"vhostuser"},"cni.dev/valid-attachments":[{"containerID":"#containerID#","ifname":"other"}]}`,
+			expRemoved: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exec invoke.Exec
+			args := testdata.GetTestArgs()
+			netName := "gc-" + args.ContainerID[:12]
+
+			sharedDir, dirErr := os.MkdirTemp("/tmp", "test-userspace-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			defer os.RemoveAll(sharedDir)
+
+			// stale attachment with a socket file in its shared directory
+			data := cniovs.OvsSavedData{Vhostname: "vhost0", NetName: netName,
+				ContainerID: args.ContainerID, IfName: args.IfName, SharedDir: sharedDir}
+			socketFile := filepath.Join(sharedDir, fmt.Sprintf("%s-%s", args.ContainerID[:12], args.IfName))
+			require.NoError(t, os.WriteFile(socketFile, []byte(""), 0644), "Can't create test file")
+			require.NoError(t, cniovs.SaveConfig(nil, args, &data), "Can't save test data")
+			defer func() {
+				var data cniovs.OvsSavedData
+				_ = cniovs.LoadConfig(nil, args, &data)
+			}()
+
+			tc.netConfStr = strings.Replace(tc.netConfStr, "#netName#", netName, -1)
+			tc.netConfStr = strings.Replace(tc.netConfStr, "#containerID#", args.ContainerID, -1)
+			tc.netConfStr = strings.Replace(tc.netConfStr, "#ifName#", args.IfName, -1)
+			gcArgs := &skel.CmdArgs{StdinData: []byte(tc.netConfStr)}
+
+			cniovs.SetExecCommand(&cniovs.FakeExecCommand{})
+			defer cniovs.SetDefaultExecCommand()
+
+			err := cni.CmdGC(gcArgs, exec, nil)
+
+			if tc.expError == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected error")
+				assert.Contains(t, err.Error(), tc.expError, "Unexpected error")
+				if tc.expCode != 0 {
+					var cniErr *cnitypes.Error
+					require.ErrorAs(t, err, &cniErr, "Unexpected error type")
+					assert.Equal(t, tc.expCode, cniErr.Code, "Unexpected error code")
+				}
+			}
+
+			readErr := cniovs.ReadConfig(nil, args, &cniovs.OvsSavedData{})
+			if tc.expRemoved {
+				assert.Error(t, readErr, "Stale saved data was not removed")
+				assert.NoFileExists(t, socketFile, "Stale socket file was not removed")
+			} else {
+				assert.NoError(t, readErr, "Saved data was removed")
+				assert.FileExists(t, socketFile, "Socket file was removed")
+			}
+		})
+	}
+}
+
+func TestCmdStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		netConfStr string
+		fakeErr    error
+		expError   string
+		expCode    uint
+	}{
+		{
+			name:       "fail to parse netConf",
+			netConfStr: "{",
+			expError:   "failed to load netconf:",
+		},
+		{
+			name:       "fail with unknown host engine",
+			netConfStr: `{"name":"status","host":{"engine":"nonsense"}}`,
+			expError:   "ERROR: Unknown Host Engine:nonsense",
+			expCode:    cnitypes.ErrInvalidNetworkConfig,
+		},
+		{
+			name:       "ovs available",
+			netConfStr: `{"name":"status","host":{"engine":"ovs-dpdk","iftype":"vhostuser"}}`,
+		},
+		{
+			name:       "ovs not available",
+			netConfStr: `{"name":"status","host":{"engine":"ovs-dpdk","iftype":"vhostuser"}}`,
+			fakeErr:    errors.New("database connection failed"),
+			expError:   "Unable to connect to OVS database",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var exec invoke.Exec
+			args := &skel.CmdArgs{StdinData: []byte(tc.netConfStr)}
+
+			cniovs.SetExecCommand(&cniovs.FakeExecCommand{Err: tc.fakeErr})
+			defer cniovs.SetDefaultExecCommand()
+
+			err := cni.CmdStatus(args, exec, nil)
+
+			if tc.expError == "" {
+				assert.NoError(t, err, "Unexpected error")
+			} else {
+				require.Error(t, err, "Unexpected error")
+				assert.Contains(t, err.Error(), tc.expError, "Unexpected error")
+				if tc.expCode != 0 {
+					var cniErr *cnitypes.Error
+					require.ErrorAs(t, err, &cniErr, "Unexpected error type")
+					assert.Equal(t, tc.expCode, cniErr.Code, "Unexpected error code")
+				}
+			}
+		})
+	}
 }
 
 func TestCmdDel(t *testing.T) {
@@ -392,6 +721,11 @@ func TestCmdDel(t *testing.T) {
 			netConfStr: `{"host":{"engine":"vpp"},"sharedDir":"#sharedDir#"}`,
 			expError:   "VPP API socket file /run/vpp/api.sock does not exist",
 		},
+		{
+			name:       "fail to connect to vpp with bridge",
+			netConfStr: `{"host":{"engine":"vpp","netType":"bridge"},"sharedDir":"#sharedDir#"}`,
+			expError:   "VPP API socket file /run/vpp/api.sock does not exist",
+		},
 		{
 			name:       "fail to connect to ovs-dpdk",
 			netConfStr: `{"host":{"engine":"ovs-dpdk","iftype":"vhostuser"},"sharedDir":"#sharedDir#"}`,
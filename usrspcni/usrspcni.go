@@ -15,10 +15,16 @@
 package usrspcni
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 
 	"github.com/intel/userspace-cni-network-plugin/pkg/types"
@@ -44,4 +50,81 @@ type UsrSpCni interface {
 		args *skel.CmdArgs,
 		sharedDir string,
 		pod *v1.Pod) error
+	CheckOnHost(conf *types.NetConf,
+		args *skel.CmdArgs,
+		sharedDir string) error
+	GarbageCollect(conf *types.NetConf,
+		validAttachments []cnitypes.GCAttachment) error
+	Status(conf *types.NetConf) error
+}
+
+//
+// Engine Registry
+//
+// Each engine registers itself under the name used in the "engine" field
+// of the HostConf and ContainerConf (i.e. "vpp", "ovs-dpdk") from init(),
+// so the core CNI flow can dispatch without knowing the engines.
+//
+
+var (
+	enginesMu sync.RWMutex
+	engines   = make(map[string]UsrSpCni)
+)
+
+// Register() - Make an engine available under the provided name.
+//
+//	Panics if the name is already in use or engine is nil, since that is a
+//	programming error detected at init() time.
+func Register(name string, engine UsrSpCni) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+
+	if engine == nil {
+		panic("usrspcni: Register engine is nil")
+	}
+	if _, dup := engines[name]; dup {
+		panic("usrspcni: Register called twice for engine " + name)
+	}
+	engines[name] = engine
+}
+
+// GetEngine() - Return the engine registered under the provided name.
+//
+//	The location ("Host" or "Container") is only used to build the error.
+func GetEngine(location string, name string) (UsrSpCni, error) {
+	enginesMu.RLock()
+	engine, ok := engines[name]
+	enginesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("ERROR: Unknown %s Engine:%s, available engines: %s",
+			location, name, strings.Join(Engines(), ", "))
+	}
+	return engine, nil
+}
+
+// Engines() - Return the sorted list of registered engine names.
+func Engines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsValidAttachment() - Report whether an attachment is still valid.
+//
+//	The attachment of the provided container and interface is valid if it
+//	is in the list passed to GarbageCollect() by the runtime.
+func IsValidAttachment(validAttachments []cnitypes.GCAttachment, containerID string, ifName string) bool {
+	for _, attachment := range validAttachments {
+		if attachment.ContainerID == containerID && attachment.IfName == ifName {
+			return true
+		}
+	}
+	return false
 }
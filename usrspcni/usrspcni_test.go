@@ -0,0 +1,150 @@
+// Copyright 2020 Intel Corp.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usrspcni
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+
+	"github.com/intel/userspace-cni-network-plugin/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEngine struct{}
+
+func (fakeEngine) AddOnHost(conf *types.NetConf, args *skel.CmdArgs, kubeClient kubernetes.Interface,
+	sharedDir string, ipResult *current.Result) error {
+	return nil
+}
+func (fakeEngine) AddOnContainer(conf *types.NetConf, args *skel.CmdArgs, kubeClient kubernetes.Interface,
+	sharedDir string, pod *v1.Pod, ipResult *current.Result) (*v1.Pod, error) {
+	return pod, nil
+}
+func (fakeEngine) DelFromHost(conf *types.NetConf, args *skel.CmdArgs, sharedDir string) error {
+	return nil
+}
+func (fakeEngine) DelFromContainer(conf *types.NetConf, args *skel.CmdArgs, sharedDir string, pod *v1.Pod) error {
+	return nil
+}
+func (fakeEngine) CheckOnHost(conf *types.NetConf, args *skel.CmdArgs, sharedDir string) error {
+	return nil
+}
+func (fakeEngine) GarbageCollect(conf *types.NetConf, validAttachments []cnitypes.GCAttachment) error {
+	return nil
+}
+func (fakeEngine) Status(conf *types.NetConf) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("fake-b", fakeEngine{})
+	Register("fake-a", fakeEngine{})
+	defer func() {
+		delete(engines, "fake-a")
+		delete(engines, "fake-b")
+	}()
+
+	assert.Equal(t, []string{"fake-a", "fake-b"}, Engines(), "Unexpected engine list")
+	assert.Panics(t, func() { Register("fake-a", fakeEngine{}) }, "Duplicate engine not rejected")
+	assert.Panics(t, func() { Register("fake-c", nil) }, "Nil engine not rejected")
+}
+
+func TestGetEngine(t *testing.T) {
+	Register("fake", fakeEngine{})
+	defer delete(engines, "fake")
+
+	testCases := []struct {
+		name     string
+		location string
+		engine   string
+		expError string
+	}{
+		{
+			name:     "get registered engine",
+			location: "Host",
+			engine:   "fake",
+		},
+		{
+			name:     "fail with unknown host engine",
+			location: "Host",
+			engine:   "nonsense",
+			expError: "ERROR: Unknown Host Engine:nonsense, available engines: fake",
+		},
+		{
+			name:     "fail with unknown container engine",
+			location: "Container",
+			engine:   "",
+			expError: "ERROR: Unknown Container Engine:, available engines: fake",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine, err := GetEngine(tc.location, tc.engine)
+			if tc.expError == "" {
+				require.NoError(t, err, "Unexpected error")
+				assert.Equal(t, fakeEngine{}, engine, "Unexpected engine")
+			} else {
+				require.Error(t, err, "Error was expected")
+				assert.Equal(t, tc.expError, err.Error(), "Unexpected error")
+				assert.Nil(t, engine, "Unexpected engine")
+			}
+		})
+	}
+}
+
+func TestIsValidAttachment(t *testing.T) {
+	validAttachments := []cnitypes.GCAttachment{
+		{ContainerID: "container-a", IfName: "eth0"},
+		{ContainerID: "container-b", IfName: "net1"},
+	}
+	testCases := []struct {
+		name        string
+		containerID string
+		ifName      string
+		expValid    bool
+	}{
+		{
+			name:        "find valid attachment",
+			containerID: "container-b",
+			ifName:      "net1",
+			expValid:    true,
+		},
+		{
+			name:        "miss attachment of other interface",
+			containerID: "container-a",
+			ifName:      "net1",
+			expValid:    false,
+		},
+		{
+			name:        "miss attachment of other container",
+			containerID: "container-c",
+			ifName:      "eth0",
+			expValid:    false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expValid, IsValidAttachment(validAttachments, tc.containerID, tc.ifName), "Unexpected result")
+		})
+	}
+	assert.False(t, IsValidAttachment(nil, "container-a", "eth0"), "Unexpected result for empty list")
+}